@@ -0,0 +1,46 @@
+import { createSharedClients } from "k6/x/gasper/loadtest";
+import { compareClients } from "k6/x/gasper/integrity";
+import { validateResult } from "../../utils.js";
+
+const env = {
+  CONFIG_PATH: "./examples/integrity/compare/config.yml",
+  UID: "compare",
+};
+
+export function setup() {
+  createSharedClients(env.CONFIG_PATH, env.UID);
+}
+
+export const options = {
+  setupTimeout: "10m",
+  vus: 1,
+  iterations: 1,
+  thresholds: {
+    gasper_rpc_mismatch: ["rate==0"], // every client must agree with the first one
+  },
+};
+
+export default function () {
+  const calls = [
+    // blocks, receipts, logs, miner balances and gas estimates of the last 16 blocks
+    { blocks: 16 },
+    { method: "eth_getBlockByNumber", params: ["$block", false] },
+    { method: "eth_feeHistory", params: ["0x4", "$block", [25, 75]] },
+  ];
+  for (const params of calls) {
+    const res = compareClients(env.UID, params);
+    if (!validateResult(res, "successful_comparison")) {
+      continue;
+    }
+    for (const r of res.data.results) {
+      for (const [uid, diffs] of Object.entries(r.diffs || {})) {
+        for (const d of diffs) {
+          console.warn(`${uid}: ${r.method} ${JSON.stringify(r.params)}: ${d.path}: ${r.reference}=${JSON.stringify(d.reference)} ${uid}=${JSON.stringify(d.value)}`);
+        }
+      }
+      for (const [uid, err] of Object.entries(r.errors || {})) {
+        console.warn(`${uid}: ${r.method} ${JSON.stringify(r.params)}: ${err}`);
+      }
+    }
+  }
+}
@@ -0,0 +1,41 @@
+import { check } from "k6";
+import { createSharedClients } from "k6/x/gasper/loadtest";
+import { checkConformance } from "k6/x/gasper/integrity";
+import { validateResult } from "../../utils.js";
+
+const env = {
+  CONFIG_PATH: "./examples/integrity/conformance/config.yml",
+  UID: "conformance",
+};
+
+export function setup() {
+  createSharedClients(env.CONFIG_PATH, env.UID);
+}
+
+export const options = {
+  setupTimeout: "10m",
+  vus: 1,
+  iterations: 1,
+  thresholds: {
+    gasper_conformance: ["rate==1"], // every check must pass
+  },
+};
+
+export default function () {
+  const result = checkConformance(env.UID);
+  for (const [uid, res] of Object.entries(result)) {
+    if (!validateResult(res, "successful_conformance")) {
+      continue;
+    }
+    for (const c of res.data.checks) {
+      if (c.skipped) {
+        continue;
+      }
+      const name = [c.method, c.check, c.case].filter((s) => s).join(" ");
+      check(c, { [name]: (c) => c.passed });
+      if (!c.passed) {
+        console.warn(`${uid}: ${name}: ${c.error}`);
+      }
+    }
+  }
+}
@@ -0,0 +1,70 @@
+import { sleep } from "k6";
+import {
+  createSharedClients,
+  sendTransaction,
+  sendERC20Transaction,
+} from "k6/x/gasper/loadtest";
+import { checkLedger } from "k6/x/gasper/integrity";
+import { validateResult } from "../../utils.js";
+
+const env = {
+  CONFIG_PATH: "./examples/integrity/ledger/config.yml",
+  UID: "ledger",
+};
+
+export function setup() {
+  createSharedClients(env.CONFIG_PATH, env.UID);
+}
+
+export const options = {
+  setupTimeout: "10m",
+  scenarios: {
+    load: {
+      executor: "constant-vus",
+      exec: "load",
+      duration: "2m",
+      vus: 20,
+      gracefulStop: "0s",
+    },
+    // check the balances once the load is over and the last transfers are mined
+    ledger: {
+      executor: "shared-iterations",
+      exec: "ledger",
+      startTime: "2m30s",
+      iterations: 1,
+      vus: 1,
+    },
+  },
+  thresholds: {
+    gasper_integrity_violation: ["rate==0"], // every balance must match the transfers
+  },
+};
+
+export function load() {
+  const sends = {
+    successful_transaction: sendTransaction,
+    successful_erc20_transaction: sendERC20Transaction,
+  };
+  for (const [msg, send] of Object.entries(sends)) {
+    for (const [_, res] of Object.entries(send(env.UID, { tx_count: 10 }))) {
+      validateResult(res, msg);
+    }
+  }
+  sleep(0.1);
+}
+
+export function ledger() {
+  const result = checkLedger(env.UID);
+  for (const [uid, res] of Object.entries(result)) {
+    if (!validateResult(res, "successful_ledger_check")) {
+      continue;
+    }
+    console.log(`${uid}: checked ${res.data.accounts} balances at block ${res.data.block}`);
+    for (const d of res.data.discrepancies) {
+      console.warn(`${uid}: ${d.asset} balance of ${d.address} is ${d.actual}, expected ${d.expected} (${d.pending} pending)`);
+    }
+    for (const e of res.data.errors) {
+      console.warn(`${uid}: ${e.address} not checked: ${e.error}`);
+    }
+  }
+}
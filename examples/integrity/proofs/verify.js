@@ -0,0 +1,87 @@
+import { fail, sleep } from "k6";
+import {
+  createSharedClients,
+  deployContract,
+  sendTransaction,
+  sendERC20Transaction,
+  sendERC721Transaction,
+} from "k6/x/gasper/loadtest";
+import { verifyProofs } from "k6/x/gasper/integrity";
+import { validateResult } from "../../utils.js";
+
+const env = {
+  CONFIG_PATH: "./examples/integrity/proofs/config.yml",
+  UID: "proofs",
+};
+
+export function setup() {
+  createSharedClients(env.CONFIG_PATH, env.UID);
+
+  // the first slots of the deployed contracts are proven as well
+  const data = deployContract(env.UID, {
+    gas_limit: 5995000,
+    abi_path: "./bindings/DummyStorage.abi",
+    bin_path: "./bindings/DummyStorage.bin",
+    args: [{ type: "uint256", value: "10" }],
+  });
+  for (const [_, res] of Object.entries(data)) {
+    if (res.err) {
+      fail(res.err);
+    }
+  }
+}
+
+export const options = {
+  setupTimeout: "10m",
+  scenarios: {
+    load: {
+      executor: "constant-vus",
+      exec: "load",
+      duration: "2m",
+      vus: 20,
+      gracefulStop: "0s",
+    },
+    // prove the state while and after it is changed by the load
+    proofs: {
+      executor: "constant-arrival-rate",
+      exec: "proofs",
+      duration: "3m",
+      rate: 1,
+      timeUnit: "30s",
+      preAllocatedVUs: 1,
+    },
+  },
+  thresholds: {
+    gasper_integrity_violation: ["rate==0"], // every proof must be valid and agree with the state
+  },
+};
+
+export function load() {
+  const sends = {
+    successful_transaction: sendTransaction,
+    successful_erc20_transaction: sendERC20Transaction,
+    successful_erc721_transaction: sendERC721Transaction,
+  };
+  for (const [msg, send] of Object.entries(sends)) {
+    for (const [_, res] of Object.entries(send(env.UID, { tx_count: 10 }))) {
+      validateResult(res, msg);
+    }
+  }
+  sleep(0.1);
+}
+
+export function proofs() {
+  const result = verifyProofs(env.UID, {});
+  for (const [uid, res] of Object.entries(result)) {
+    if (!validateResult(res, "successful_proof_verification")) {
+      continue;
+    }
+    console.log(`${uid}: proved ${res.data.accounts} accounts and ${res.data.slots} slots at block ${res.data.block}`);
+    for (const v of res.data.violations) {
+      console.warn(`${uid}: ${v.name} ${v.address} ${v.slot || ""}: ${v.check}: ${v.error}`);
+    }
+    for (const e of res.data.errors) {
+      console.warn(`${uid}: ${e.address} not proven: ${e.error}`);
+    }
+  }
+}
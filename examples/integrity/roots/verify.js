@@ -0,0 +1,38 @@
+import { createSharedClients } from "k6/x/gasper/loadtest";
+import { verifyRoots } from "k6/x/gasper/integrity";
+import { validateResult } from "../../utils.js";
+
+const env = {
+  CONFIG_PATH: "./examples/integrity/roots/config.yml",
+  UID: "roots",
+};
+
+export function setup() {
+  createSharedClients(env.CONFIG_PATH, env.UID);
+}
+
+export const options = {
+  setupTimeout: "10m",
+  vus: 1,
+  iterations: 1,
+  thresholds: {
+    gasper_integrity_violation: ["rate==0"], // every recomputed root must match its header
+  },
+};
+
+export default function () {
+  // the last 256 blocks, or a range with { from: 100, to: 200 }
+  const result = verifyRoots(env.UID, { blocks: 256 });
+  for (const [uid, res] of Object.entries(result)) {
+    if (!validateResult(res, "successful_root_verification")) {
+      continue;
+    }
+    console.log(`${uid}: verified ${res.data.verified} blocks from ${res.data.from} to ${res.data.to}`);
+    for (const v of res.data.violations) {
+      console.warn(`${uid}: block ${v.block} ${v.hash}: ${v.root} is ${v.header} in the header, computed ${v.computed}`);
+    }
+    for (const e of res.data.errors) {
+      console.warn(`${uid}: block ${e.block} not verified: ${e.error}`);
+    }
+  }
+}
@@ -0,0 +1,41 @@
+import { fail } from "k6";
+import { pastRuns, analyzeRun, diffRuns } from "k6/x/gasper/loadtest";
+
+// analyzes the last run of DB_PATH, and diffs it with the previous one
+// (or BASE_RUN when set) to show the regressions between node versions
+const dbPath = __ENV.DB_PATH || ".scratch/tx_load_test.db";
+
+export const options = {
+  vus: 1,
+  iterations: 1,
+};
+
+export default function () {
+  const runs = pastRuns(dbPath);
+  if (runs.err) {
+    fail(runs.err);
+  }
+  if (runs.data.length == 0) {
+    fail(`no past runs of ${dbPath}`);
+  }
+
+  const last = runs.data[runs.data.length - 1];
+  const analysis = analyzeRun(last);
+  if (analysis.err) {
+    fail(analysis.err);
+  }
+  console.log(JSON.stringify(analysis.data, null, 2));
+
+  const base = __ENV.BASE_RUN || (runs.data.length > 1 ? runs.data[runs.data.length - 2] : "");
+  if (base == "") {
+    return;
+  }
+  const diff = diffRuns(base, last, parseFloat(__ENV.THRESHOLD || "0.1"));
+  if (diff.err) {
+    fail(diff.err);
+  }
+  console.log(JSON.stringify(diff.data, null, 2));
+  if (diff.data.regressions.length > 0) {
+    console.warn(`regressions: ${diff.data.regressions.join(", ")}`);
+  }
+}
@@ -0,0 +1,127 @@
+package eth
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	MaxBlobsPerTransaction = 6
+	BlobGasPerBlob         = params.BlobTxBlobGasPerBlob
+
+	blobFieldElementSize = 32
+)
+
+// RandomBlob returns a blob filled with random field elements.
+func RandomBlob() (*kzg4844.Blob, error) {
+	var blob kzg4844.Blob
+	if _, err := rand.Read(blob[:]); err != nil {
+		return nil, err
+	}
+	canonicalizeBlob(&blob)
+	return &blob, nil
+}
+
+// DeterministicBlob returns the same blob for the same index, across runs.
+func DeterministicBlob(index int) *kzg4844.Blob {
+	var blob kzg4844.Blob
+	seed := crypto.Keccak256([]byte("gasper_blob_" + strconv.Itoa(index)))
+	for i := 0; i < len(blob); i += len(seed) {
+		copy(blob[i:], seed)
+		seed = crypto.Keccak256(seed)
+	}
+	canonicalizeBlob(&blob)
+	return &blob
+}
+
+// canonicalizeBlob clears the top byte of every field element, so each one
+// is below the BLS12-381 modulus.
+func canonicalizeBlob(blob *kzg4844.Blob) {
+	for i := 0; i < len(blob); i += blobFieldElementSize {
+		blob[i] = 0
+	}
+}
+
+// NewBlobSidecar builds a sidecar with count blobs and computes their KZG
+// commitments and proofs.
+func NewBlobSidecar(count int, random bool) (*types.BlobTxSidecar, error) {
+	if count < 1 || count > MaxBlobsPerTransaction {
+		return nil, fmt.Errorf("blob count should be between 1 and %d: %d", MaxBlobsPerTransaction, count)
+	}
+
+	sidecar := &types.BlobTxSidecar{
+		Blobs:       make([]kzg4844.Blob, 0, count),
+		Commitments: make([]kzg4844.Commitment, 0, count),
+		Proofs:      make([]kzg4844.Proof, 0, count),
+	}
+	for i := 0; i < count; i++ {
+		var (
+			blob *kzg4844.Blob
+			err  error
+		)
+		if random {
+			blob, err = RandomBlob()
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate blob: %w", err)
+			}
+		} else {
+			blob = DeterministicBlob(i)
+		}
+
+		commitment, err := kzg4844.BlobToCommitment(blob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute blob commitment: %w", err)
+		}
+		proof, err := kzg4844.ComputeBlobProof(blob, commitment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute blob proof: %w", err)
+		}
+
+		sidecar.Blobs = append(sidecar.Blobs, *blob)
+		sidecar.Commitments = append(sidecar.Commitments, commitment)
+		sidecar.Proofs = append(sidecar.Proofs, proof)
+	}
+
+	return sidecar, nil
+}
+
+// BlobSidecarCache keeps deterministic sidecars, so the KZG commitments and
+// proofs are computed only once per blob count.
+type BlobSidecarCache struct {
+	sidecars map[int]*types.BlobTxSidecar
+	mu       *sync.Mutex
+}
+
+func NewBlobSidecarCache() *BlobSidecarCache {
+	return &BlobSidecarCache{
+		sidecars: make(map[int]*types.BlobTxSidecar),
+		mu:       &sync.Mutex{},
+	}
+}
+
+// Get returns a sidecar with count blobs, random sidecars are never cached.
+func (bc *BlobSidecarCache) Get(count int, random bool) (*types.BlobTxSidecar, error) {
+	if random {
+		return NewBlobSidecar(count, true)
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if sidecar, ok := bc.sidecars[count]; ok {
+		return sidecar, nil
+	}
+	sidecar, err := NewBlobSidecar(count, false)
+	if err != nil {
+		return nil, err
+	}
+	bc.sidecars[count] = sidecar
+	return sidecar, nil
+}
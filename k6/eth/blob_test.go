@@ -0,0 +1,47 @@
+package eth
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeterministicBlob(t *testing.T) {
+	assert.Equal(t, DeterministicBlob(0), DeterministicBlob(0))
+	assert.NotEqual(t, DeterministicBlob(0), DeterministicBlob(1))
+}
+
+func TestNewBlobSidecar(t *testing.T) {
+	t.Run("valid commitments and proofs", func(t *testing.T) {
+		sidecar, err := NewBlobSidecar(2, true)
+		require.NoError(t, err)
+		require.Len(t, sidecar.Blobs, 2)
+		require.Len(t, sidecar.BlobHashes(), 2)
+		for i := range sidecar.Blobs {
+			require.NoError(t, kzg4844.VerifyBlobProof(&sidecar.Blobs[i], sidecar.Commitments[i], sidecar.Proofs[i]))
+		}
+	})
+
+	t.Run("invalid blob count", func(t *testing.T) {
+		_, err := NewBlobSidecar(0, false)
+		assert.Error(t, err)
+		_, err = NewBlobSidecar(MaxBlobsPerTransaction+1, false)
+		assert.Error(t, err)
+	})
+}
+
+func TestBlobSidecarCache(t *testing.T) {
+	cache := NewBlobSidecarCache()
+
+	a, err := cache.Get(1, false)
+	require.NoError(t, err)
+	b, err := cache.Get(1, false)
+	require.NoError(t, err)
+	assert.Same(t, a, b)
+
+	c, err := cache.Get(1, true)
+	require.NoError(t, err)
+	assert.NotSame(t, a, c)
+}
@@ -2,10 +2,14 @@ package eth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -32,15 +36,79 @@ func NewClient(ctx context.Context, uri string) (*Client, error) {
 	return c, nil
 }
 
+// SupportsSubscriptions reports whether the client transport can serve
+// eth_subscribe.
+func (c *Client) SupportsSubscriptions() bool {
+	return SupportsSubscriptions(c.uri)
+}
+
 func (c *Client) Close() {
 	c.Ec.Close()
 	c.Rc.Close()
 }
 
 func (c *Client) SlimBlockByNumber(ctx context.Context, number *big.Int) (*SlimBlock, error) {
-	var result SlimBlock
+	var result *SlimBlock
 	if err := c.Rc.CallContext(ctx, &result, "eth_getBlockByNumber", hexutil.EncodeBig(number), false); err != nil {
 		return nil, err
 	}
-	return &result, nil
+	if result == nil {
+		return nil, ethereum.NotFound
+	}
+	return result, nil
+}
+
+func (c *Client) SlimBlockByHash(ctx context.Context, hash common.Hash) (*SlimBlock, error) {
+	var result *SlimBlock
+	if err := c.Rc.CallContext(ctx, &result, "eth_getBlockByHash", hash, false); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, ethereum.NotFound
+	}
+	return result, nil
+}
+
+type accessListResult struct {
+	AccessList *types.AccessList `json:"accessList"`
+	Error      string            `json:"error,omitempty"`
+	GasUsed    hexutil.Uint64    `json:"gasUsed"`
+}
+
+// CreateAccessList generates the access list of msg with eth_createAccessList.
+func (c *Client) CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (types.AccessList, uint64, error) {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["input"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	if msg.GasFeeCap != nil {
+		arg["maxFeePerGas"] = (*hexutil.Big)(msg.GasFeeCap)
+	}
+	if msg.GasTipCap != nil {
+		arg["maxPriorityFeePerGas"] = (*hexutil.Big)(msg.GasTipCap)
+	}
+
+	var result accessListResult
+	if err := c.Rc.CallContext(ctx, &result, "eth_createAccessList", arg, "pending"); err != nil {
+		return nil, 0, err
+	}
+	if result.Error != "" {
+		return nil, 0, errors.New(result.Error)
+	}
+	if result.AccessList == nil {
+		return types.AccessList{}, uint64(result.GasUsed), nil
+	}
+	return *result.AccessList, uint64(result.GasUsed), nil
 }
@@ -197,3 +197,11 @@ func (e *ERC20) Balance(ctx context.Context, addr common.Address) (*big.Int, err
 	}
 	return balance, nil
 }
+
+func (e *ERC20) TransferData(to common.Address, amount *big.Int) ([]byte, error) {
+	parsed, err := bindings.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ERC20 abi: %w", err)
+	}
+	return parsed.Pack("transfer", to, amount)
+}
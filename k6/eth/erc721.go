@@ -170,3 +170,11 @@ func (e *ERC721) Balance(ctx context.Context, wallet *Wallet) error {
 	}
 	return nil
 }
+
+func (e *ERC721) MintBatchData(to common.Address, amount *big.Int) ([]byte, error) {
+	parsed, err := bindings.ERC721MetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ERC721 abi: %w", err)
+	}
+	return parsed.Pack("mintBatch", to, amount)
+}
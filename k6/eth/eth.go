@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
@@ -22,9 +23,14 @@ const (
 type TransactionType string
 
 const (
-	TransactionTypeETH    TransactionType = "EIP155"
-	TransactionTypeERC20  TransactionType = "ERC20"
-	TransactionTypeERC721 TransactionType = "ERC721"
+	TransactionTypeETH       TransactionType = "EIP155"
+	TransactionTypeERC20     TransactionType = "ERC20"
+	TransactionTypeERC721    TransactionType = "ERC721"
+	TransactionTypeBlob      TransactionType = "EIP4844"
+	TransactionTypeSetCode   TransactionType = "EIP7702"
+	TransactionTypeDelegated TransactionType = "EIP7702_CALL"
+	TransactionTypeContract  TransactionType = "CONTRACT"
+	TransactionTypeMix       TransactionType = "MIX"
 )
 
 type TransactionInfo struct {
@@ -34,24 +40,33 @@ type TransactionInfo struct {
 }
 
 type SlimBlock struct {
-	Number       *big.Int `json:"number"`
-	Timestamp    uint64   `json:"timestamp"`
-	GasUsed      uint64   `json:"gasUsed"`
-	Transactions []string `json:"transactions"` // hashes only, since we set 'false'
+	Number       *big.Int    `json:"number"`
+	Hash         common.Hash `json:"hash"`
+	ParentHash   common.Hash `json:"parentHash"`
+	Timestamp    uint64      `json:"timestamp"`
+	GasUsed      uint64      `json:"gasUsed"`
+	BlobGasUsed  *uint64     `json:"blobGasUsed"`  // nil before Cancun
+	Transactions []string    `json:"transactions"` // hashes only, since we set 'false'
 }
 
 func (b *SlimBlock) MarshalJSON() ([]byte, error) {
 	type SlimBlock struct {
-		Number       *hexutil.Big   `json:"number"`
-		Timestamp    hexutil.Uint64 `json:"timestamp"`
-		GasUsed      hexutil.Uint64 `json:"gasUsed"`
-		Transactions []string       `json:"transactions"`
+		Number       *hexutil.Big    `json:"number"`
+		Hash         common.Hash     `json:"hash"`
+		ParentHash   common.Hash     `json:"parentHash"`
+		Timestamp    hexutil.Uint64  `json:"timestamp"`
+		GasUsed      hexutil.Uint64  `json:"gasUsed"`
+		BlobGasUsed  *hexutil.Uint64 `json:"blobGasUsed,omitempty"`
+		Transactions []string        `json:"transactions"`
 	}
 
 	return json.Marshal(SlimBlock{
 		Number:       (*hexutil.Big)(b.Number),
+		Hash:         b.Hash,
+		ParentHash:   b.ParentHash,
 		Timestamp:    hexutil.Uint64(b.Timestamp),
 		GasUsed:      hexutil.Uint64(b.GasUsed),
+		BlobGasUsed:  (*hexutil.Uint64)(b.BlobGasUsed),
 		Transactions: b.Transactions,
 	})
 }
@@ -59,8 +74,11 @@ func (b *SlimBlock) MarshalJSON() ([]byte, error) {
 func (b *SlimBlock) UnmarshalJSON(input []byte) error {
 	type SlimBlock struct {
 		Number       *hexutil.Big    `json:"number"`
+		Hash         *common.Hash    `json:"hash"`
+		ParentHash   *common.Hash    `json:"parentHash"`
 		Timestamp    *hexutil.Uint64 `json:"timestamp"`
 		GasUsed      *hexutil.Uint64 `json:"gasUsed"`
+		BlobGasUsed  *hexutil.Uint64 `json:"blobGasUsed"`
 		Transactions []string        `json:"transactions"`
 	}
 
@@ -74,6 +92,12 @@ func (b *SlimBlock) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'number' for Header")
 	}
 	b.Number = (*big.Int)(dec.Number)
+	if dec.Hash != nil {
+		b.Hash = *dec.Hash
+	}
+	if dec.ParentHash != nil {
+		b.ParentHash = *dec.ParentHash
+	}
 	if dec.GasUsed == nil {
 		return errors.New("missing required field 'gasUsed' for Header")
 	}
@@ -82,6 +106,17 @@ func (b *SlimBlock) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'timestamp' for Header")
 	}
 	b.Timestamp = uint64(*dec.Timestamp)
+	if dec.BlobGasUsed != nil {
+		b.BlobGasUsed = (*uint64)(dec.BlobGasUsed)
+	}
 
 	return nil
 }
+
+// BlobCount returns the number of blobs included in the block.
+func (b *SlimBlock) BlobCount() uint64 {
+	if b.BlobGasUsed == nil {
+		return 0
+	}
+	return *b.BlobGasUsed / BlobGasPerBlob
+}
@@ -0,0 +1,163 @@
+package eth
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultDerivationPath is the BIP-44 path of Ethereum accounts, wallets are
+// derived at its children.
+const DefaultDerivationPath = "m/44'/60'/0'/0"
+
+// extendedKey is a BIP-32 extended private key.
+type extendedKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// MnemonicToSeed returns the BIP-39 seed of mnemonic. The words must be in
+// the English wordlist and match the checksum, so a mistyped mnemonic does not
+// derive other wallets.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, fmt.Errorf("invalid mnemonic: expected 12, 15, 18, 21 or 24 words, got %d", len(words))
+	}
+	// the passphrase is normalized as BIP-39 requires, the English words are
+	// already in NFKD form
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(words, " "), norm.NFKD.String(passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	return seed, nil
+}
+
+func newMasterKey(seed []byte) (*extendedKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed) // nolint:errcheck
+	sum := mac.Sum(nil)
+
+	if _, err := crypto.ToECDSA(sum[:32]); err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	return &extendedKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// child derives the BIP-32 private child key at index, indexes from 2^31 are
+// hardened.
+func (k *extendedKey) child(index uint32) (*extendedKey, error) {
+	data := make([]byte, 0, 37)
+	if index >= 0x80000000 {
+		data = append(data, 0)
+		data = append(data, k.key...)
+	} else {
+		pk, err := crypto.ToECDSA(k.key)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, crypto.CompressPubkey(&pk.PublicKey)...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data) // nolint:errcheck
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+	key := il.Add(il, new(big.Int).SetBytes(k.key))
+	key.Mod(key, n)
+	if key.Sign() == 0 {
+		return nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+	return &extendedKey{key: key.FillBytes(make([]byte, 32)), chainCode: sum[32:]}, nil
+}
+
+func (k *extendedKey) derive(path accounts.DerivationPath) (*extendedKey, error) {
+	var err error
+	for _, index := range path {
+		if k, err = k.child(index); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// DeriveKey returns the private key of the mnemonic at the full derivation
+// path, e.g. m/44'/60'/0'/0/0.
+func DeriveKey(mnemonic, passphrase, path string) (*ecdsa.PrivateKey, error) {
+	dp, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse derivation path: %w", err)
+	}
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	k, err := master.derive(dp)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.ToECDSA(k.key)
+}
+
+// DeriveWallets returns count wallets of the mnemonic derived at the children
+// start to start+count-1 of basePath, DefaultDerivationPath when empty.
+func DeriveWallets(mnemonic, passphrase, basePath string, start, count uint32) ([]*Wallet, error) {
+	if basePath == "" {
+		basePath = DefaultDerivationPath
+	}
+	dp, err := accounts.ParseDerivationPath(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse derivation path: %w", err)
+	}
+	if uint64(start)+uint64(count) > 0x80000000 {
+		return nil, fmt.Errorf("index range %d+%d exceeds non-hardened indexes", start, count)
+	}
+
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	base, err := master.derive(dp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive %s: %w", basePath, err)
+	}
+
+	wallets := make([]*Wallet, 0, count)
+	for i := start; i < start+count; i++ {
+		k, err := base.child(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive %s/%d: %w", basePath, i, err)
+		}
+		pk, err := crypto.ToECDSA(k.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive %s/%d: %w", basePath, i, err)
+		}
+		wallets = append(wallets, &Wallet{Address: crypto.PubkeyToAddress(pk.PublicKey), PrivateKey: pk})
+	}
+	return wallets, nil
+}
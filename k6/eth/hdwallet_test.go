@@ -0,0 +1,76 @@
+package eth
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// default mnemonic of anvil and hardhat
+const testMnemonic = "test test test test test test test test test test test junk"
+
+func TestMnemonicToSeed(t *testing.T) {
+	t.Run("bip39 vector", func(t *testing.T) {
+		seed, err := MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "TREZOR")
+		require.NoError(t, err)
+		assert.Equal(t, "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04", hex.EncodeToString(seed))
+	})
+
+	t.Run("invalid word count", func(t *testing.T) {
+		_, err := MnemonicToSeed("test test test", "")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid mnemonic")
+	})
+
+	t.Run("invalid checksum", func(t *testing.T) {
+		_, err := MnemonicToSeed("test test test test test test test test test test test test", "")
+		assert.ErrorContains(t, err, "invalid mnemonic")
+	})
+
+	t.Run("unknown word", func(t *testing.T) {
+		_, err := MnemonicToSeed("test test test test test test test test test test test jumk", "")
+		assert.ErrorContains(t, err, "invalid mnemonic")
+	})
+}
+
+func TestDeriveKey(t *testing.T) {
+	pk, err := DeriveKey(testMnemonic, "", "m/44'/60'/0'/0/0")
+	require.NoError(t, err)
+	assert.Equal(t, "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80", hex.EncodeToString(crypto.FromECDSA(pk)))
+
+	_, err = DeriveKey(testMnemonic, "", "n/0")
+	assert.Error(t, err)
+}
+
+func TestDeriveWallets(t *testing.T) {
+	t.Run("default path", func(t *testing.T) {
+		wallets, err := DeriveWallets(testMnemonic, "", "", 0, 3)
+		require.NoError(t, err)
+		require.Len(t, wallets, 3)
+		assert.Equal(t, common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), wallets[0].Address)
+		assert.Equal(t, common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), wallets[1].Address)
+		assert.Equal(t, common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC"), wallets[2].Address)
+	})
+
+	t.Run("index range", func(t *testing.T) {
+		wallets, err := DeriveWallets(testMnemonic, "", DefaultDerivationPath, 1, 1)
+		require.NoError(t, err)
+		require.Len(t, wallets, 1)
+		assert.Equal(t, common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), wallets[0].Address)
+	})
+
+	t.Run("passphrase changes wallets", func(t *testing.T) {
+		wallets, err := DeriveWallets(testMnemonic, "secret", "", 0, 1)
+		require.NoError(t, err)
+		assert.NotEqual(t, common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), wallets[0].Address)
+	})
+
+	t.Run("hardened range", func(t *testing.T) {
+		_, err := DeriveWallets(testMnemonic, "", "", 0x7fffffff, 2)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,263 @@
+package eth
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// JournalFormat is the file format of an exported journal.
+type JournalFormat string
+
+const (
+	JournalFormatCSV   JournalFormat = "csv"
+	JournalFormatJSONL JournalFormat = "jsonl"
+)
+
+// sentRecord is a transaction sent by a client, stored as journal_<hash>.
+type sentRecord struct {
+	Client string          `json:"client"`
+	Wallet common.Address  `json:"wallet"`
+	Nonce  uint64          `json:"nonce"`
+	Type   TransactionType `json:"type"`
+	SentAt int64           `json:"sentAt"` // unix millis
+}
+
+// inclusionRecord is the block a sent transaction was seen in, stored as
+// included_<hash>. It is overwritten when the transaction is included again
+// after a reorg.
+type inclusionRecord struct {
+	Block      uint64 `json:"block"`
+	IncludedAt int64  `json:"includedAt"` // unix millis of the block arrival
+}
+
+// ReceiptRecord is the outcome of a sent transaction, stored as
+// receipt_<hash>.
+type ReceiptRecord struct {
+	Status            uint64 `json:"status"`
+	GasUsed           uint64 `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	BlockNumber       uint64 `json:"blockNumber,omitempty"`
+	Dropped           bool   `json:"dropped,omitempty"` // not included after drop_after blocks
+}
+
+// JournalEntry is everything known about a sent transaction.
+type JournalEntry struct {
+	Hash              string          `json:"hash"`
+	Client            string          `json:"client"`
+	Wallet            string          `json:"wallet"`
+	Nonce             uint64          `json:"nonce"`
+	Type              TransactionType `json:"type"`
+	SentAt            int64           `json:"sentAt"`                      // unix millis
+	IncludedBlock     uint64          `json:"includedBlock,omitempty"`     // zero until seen in a block
+	IncludedAt        int64           `json:"includedAt,omitempty"`        // unix millis of the block arrival
+	TimeToMine        int64           `json:"timeToMine,omitempty"`        // millis between sent and included
+	Status            *uint64         `json:"status,omitempty"`            // nil until the receipt is known
+	GasUsed           uint64          `json:"gasUsed,omitempty"`           // from the receipt
+	EffectiveGasPrice string          `json:"effectiveGasPrice,omitempty"` // from the receipt
+	Dropped           bool            `json:"dropped,omitempty"`           // not included after drop_after blocks
+}
+
+var journalCSVHeader = []string{
+	"hash", "client", "wallet", "nonce", "type", "sentAt", "includedBlock", "includedAt",
+	"timeToMine", "status", "gasUsed", "effectiveGasPrice", "dropped",
+}
+
+// Journal records the transactions sent during a test in the database, one
+// record per stage of their life, joined when read.
+type Journal struct {
+	db *PebbleDb
+}
+
+func NewJournal(db *PebbleDb) *Journal {
+	return &Journal{db: db}
+}
+
+func (j *Journal) set(key []byte, record interface{}) error {
+	val, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return j.db.Db().Set(key, val, pebble.NoSync)
+}
+
+func (j *Journal) get(key []byte, record interface{}) (bool, error) {
+	val, closer, err := j.db.Db().Get(key)
+	if err == pebble.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer closer.Close() // nolint:errcheck
+	return true, json.Unmarshal(val, record)
+}
+
+// Sent records a transaction sent by client.
+func (j *Journal) Sent(client string, hash common.Hash, wallet common.Address, nonce uint64, txType TransactionType, t time.Time) error {
+	return j.set(j.db.GenKey("journal", hash.Hex()), &sentRecord{
+		Client: client,
+		Wallet: wallet,
+		Nonce:  nonce,
+		Type:   txType,
+		SentAt: t.UnixMilli(),
+	})
+}
+
+// Included records the block a transaction was seen in.
+func (j *Journal) Included(hash string, block uint64, t time.Time) error {
+	return j.set(j.db.GenKey("included", hash), &inclusionRecord{Block: block, IncludedAt: t.UnixMilli()})
+}
+
+// Receipt records the outcome of a transaction.
+func (j *Journal) Receipt(hash common.Hash, record *ReceiptRecord) error {
+	return j.set(j.db.GenKey("receipt", hash.Hex()), record)
+}
+
+// Entries calls fn with the transactions sent by client in hash order, every
+// client when it is empty.
+func (j *Journal) Entries(client string, fn func(*JournalEntry) error) error {
+	prefix := j.db.GenKey("journal", "")
+	iter, err := j.db.Db().NewIter(&pebble.IterOptions{LowerBound: prefix, UpperBound: prefixUpperBound(prefix)})
+	if err != nil {
+		return err
+	}
+	defer iter.Close() // nolint:errcheck
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		hash := string(iter.Key()[len(prefix):])
+		var sent sentRecord
+		if err := json.Unmarshal(iter.Value(), &sent); err != nil {
+			return fmt.Errorf("failed to unmarshal journal record %s: %w", hash, err)
+		}
+		if client != "" && sent.Client != client {
+			continue
+		}
+		entry, err := j.entry(hash, &sent)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+func (j *Journal) entry(hash string, sent *sentRecord) (*JournalEntry, error) {
+	entry := &JournalEntry{
+		Hash:   hash,
+		Client: sent.Client,
+		Wallet: sent.Wallet.Hex(),
+		Nonce:  sent.Nonce,
+		Type:   sent.Type,
+		SentAt: sent.SentAt,
+	}
+
+	var inclusion inclusionRecord
+	ok, err := j.get(j.db.GenKey("included", hash), &inclusion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inclusion of %s: %w", hash, err)
+	}
+	if ok {
+		entry.IncludedBlock = inclusion.Block
+		entry.IncludedAt = inclusion.IncludedAt
+		entry.TimeToMine = inclusion.IncludedAt - sent.SentAt
+	}
+
+	var receipt ReceiptRecord
+	ok, err = j.get(j.db.GenKey("receipt", hash), &receipt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt of %s: %w", hash, err)
+	}
+	if ok {
+		entry.Dropped = receipt.Dropped
+		if !receipt.Dropped {
+			entry.Status = &receipt.Status
+			entry.GasUsed = receipt.GasUsed
+			entry.EffectiveGasPrice = receipt.EffectiveGasPrice
+			if entry.IncludedBlock == 0 {
+				entry.IncludedBlock = receipt.BlockNumber
+			}
+		}
+	}
+	return entry, nil
+}
+
+// Export writes the transactions sent by client to w, every client when it
+// is empty, and returns the number of transactions written.
+func (j *Journal) Export(w io.Writer, client string, format JournalFormat) (int, error) {
+	var write func(*JournalEntry) error
+	flush := func() error { return nil }
+	switch format {
+	case JournalFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(journalCSVHeader); err != nil {
+			return 0, err
+		}
+		write = func(entry *JournalEntry) error { return cw.Write(entry.csvRecord()) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case JournalFormatJSONL:
+		enc := json.NewEncoder(w)
+		write = func(entry *JournalEntry) error { return enc.Encode(entry) }
+	default:
+		return 0, fmt.Errorf("unknown journal format: %s", format)
+	}
+
+	n := 0
+	err := j.Entries(client, func(entry *JournalEntry) error {
+		n++
+		return write(entry)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+// csvRecord returns the entry in the order of journalCSVHeader, unknown
+// values are left empty.
+func (e *JournalEntry) csvRecord() []string {
+	formatUint := func(v uint64, known bool) string {
+		if !known {
+			return ""
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	formatInt := func(v int64, known bool) string {
+		if !known {
+			return ""
+		}
+		return strconv.FormatInt(v, 10)
+	}
+	included, mined := e.IncludedAt != 0, e.Status != nil
+
+	var status uint64
+	if mined {
+		status = *e.Status
+	}
+	return []string{
+		e.Hash,
+		e.Client,
+		e.Wallet,
+		strconv.FormatUint(e.Nonce, 10),
+		string(e.Type),
+		strconv.FormatInt(e.SentAt, 10),
+		formatUint(e.IncludedBlock, e.IncludedBlock != 0),
+		formatInt(e.IncludedAt, included),
+		formatInt(e.TimeToMine, included),
+		formatUint(status, mined),
+		formatUint(e.GasUsed, mined),
+		e.EffectiveGasPrice,
+		strconv.FormatBool(e.Dropped),
+	}
+}
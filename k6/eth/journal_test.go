@@ -0,0 +1,105 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	db, err := NewPebbleDb(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() }) // nolint:errcheck
+
+	j := NewJournal(db)
+	wallet := common.HexToAddress("0x01")
+	mined, pending, dropped, other := common.HexToHash("0x0a"), common.HexToHash("0x0b"), common.HexToHash("0x0c"), common.HexToHash("0x0d")
+	sentAt := time.UnixMilli(1_000)
+
+	require.NoError(t, j.Sent("client", mined, wallet, 1, TransactionTypeETH, sentAt))
+	require.NoError(t, j.Sent("client", pending, wallet, 2, TransactionTypeERC20, sentAt))
+	require.NoError(t, j.Sent("client", dropped, wallet, 3, TransactionTypeETH, sentAt))
+	require.NoError(t, j.Sent("other", other, wallet, 4, TransactionTypeETH, sentAt))
+	require.NoError(t, j.Included(mined.Hex(), 10, time.UnixMilli(1_250)))
+	require.NoError(t, j.Receipt(mined, &ReceiptRecord{Status: 1, GasUsed: 21000, EffectiveGasPrice: "7", BlockNumber: 10}))
+	require.NoError(t, j.Receipt(dropped, &ReceiptRecord{Dropped: true}))
+
+	t.Run("entries", func(t *testing.T) {
+		entries := make([]*JournalEntry, 0)
+		require.NoError(t, j.Entries("client", func(entry *JournalEntry) error {
+			entries = append(entries, entry)
+			return nil
+		}))
+		require.Len(t, entries, 3)
+
+		status := uint64(1)
+		assert.Equal(t, &JournalEntry{
+			Hash:              mined.Hex(),
+			Client:            "client",
+			Wallet:            wallet.Hex(),
+			Nonce:             1,
+			Type:              TransactionTypeETH,
+			SentAt:            1_000,
+			IncludedBlock:     10,
+			IncludedAt:        1_250,
+			TimeToMine:        250,
+			Status:            &status,
+			GasUsed:           21000,
+			EffectiveGasPrice: "7",
+		}, entries[0])
+		assert.Zero(t, entries[1].IncludedBlock)
+		assert.Nil(t, entries[1].Status)
+		assert.True(t, entries[2].Dropped)
+		assert.Nil(t, entries[2].Status)
+	})
+
+	t.Run("every client", func(t *testing.T) {
+		n := 0
+		require.NoError(t, j.Entries("", func(*JournalEntry) error {
+			n++
+			return nil
+		}))
+		assert.Equal(t, 4, n)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		n, err := j.Export(buf, "client", JournalFormatCSV)
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+
+		records, err := csv.NewReader(buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 4)
+		assert.Equal(t, journalCSVHeader, records[0])
+		assert.Equal(t, []string{mined.Hex(), "client", wallet.Hex(), "1", "EIP155", "1000", "10", "1250", "250", "1", "21000", "7", "false"}, records[1])
+		assert.Equal(t, []string{pending.Hex(), "client", wallet.Hex(), "2", "ERC20", "1000", "", "", "", "", "", "", "false"}, records[2])
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		n, err := j.Export(buf, "client", JournalFormatJSONL)
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 3)
+		var entry JournalEntry
+		require.NoError(t, json.Unmarshal([]byte(lines[2]), &entry))
+		assert.Equal(t, dropped.Hex(), entry.Hash)
+		assert.True(t, entry.Dropped)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := j.Export(&bytes.Buffer{}, "client", "parquet")
+		assert.Error(t, err)
+	})
+}
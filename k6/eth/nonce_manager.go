@@ -0,0 +1,375 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	DefaultNonceCheckInterval = 10 * time.Second
+	maxNonceChecksAtOnce      = 16
+	maxPendingGapFills        = 1024
+)
+
+// NonceReader returns the nonces of an account, *ethclient.Client implements
+// it.
+type NonceReader interface {
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// GapFiller sends a replacement transaction from wallet at nonce.
+type GapFiller func(ctx context.Context, wallet *Wallet, nonce uint64) error
+
+// NonceState is the nonce bookkeeping of a wallet, for debugging.
+type NonceState struct {
+	Address   string    `json:"address"`
+	Next      uint64    `json:"next"`      // next nonce handed out
+	Offset    uint64    `json:"offset"`    // next offset nonce handed out
+	Latest    uint64    `json:"latest"`    // nonce of the latest block at the last check
+	Pending   uint64    `json:"pending"`   // pending nonce at the last check
+	Reserved  []uint64  `json:"reserved"`  // nonces handed out and not sent yet
+	InFlight  []uint64  `json:"inFlight"`  // nonces sent and not mined at the last check
+	Released  []uint64  `json:"released"`  // nonces not consumed, handed out again first
+	Gaps      []uint64  `json:"gaps"`      // missing nonces seen at the last check
+	Filled    uint64    `json:"filled"`    // gaps filled with a replacement transaction
+	LastCheck time.Time `json:"lastCheck"` // zero until the wallet is checked
+}
+
+type GapFillEvent struct {
+	Address common.Address
+	Nonce   uint64
+	Failed  bool
+	Time    time.Time
+}
+
+type nonceTracker struct {
+	wallet    *Wallet
+	reserved  map[uint64]bool // nonce -> whether it is an offset nonce
+	inFlight  map[uint64]struct{}
+	released  []uint64 // sorted
+	gaps      map[uint64]struct{}
+	latest    uint64
+	pending   uint64
+	filled    uint64
+	lastCheck time.Time
+}
+
+// NonceManager hands out the nonces of the wallets and keeps them in line
+// with the chain. Nonces which were not consumed by the node are handed out
+// again, and nonces missing from the pool (pending nonce below the next one
+// for two checks in a row) are filled with a replacement transaction, so a
+// dropped transaction does not leave the wallet stuck.
+type NonceManager struct {
+	reader   NonceReader
+	fill     GapFiller
+	trackers map[common.Address]*nonceTracker
+	events   []*GapFillEvent
+	mu       *sync.Mutex
+}
+
+func NewNonceManager(reader NonceReader, fill GapFiller) *NonceManager {
+	return &NonceManager{
+		reader:   reader,
+		fill:     fill,
+		trackers: make(map[common.Address]*nonceTracker),
+		events:   make([]*GapFillEvent, 0),
+		mu:       &sync.Mutex{},
+	}
+}
+
+func (nm *NonceManager) tracker(w *Wallet) *nonceTracker {
+	t, ok := nm.trackers[w.Address]
+	if !ok {
+		t = &nonceTracker{
+			wallet:   w,
+			reserved: make(map[uint64]bool),
+			inFlight: make(map[uint64]struct{}),
+			released: make([]uint64, 0),
+			gaps:     make(map[uint64]struct{}),
+		}
+		nm.trackers[w.Address] = t
+	}
+	return t
+}
+
+// Next reserves the next nonce of the wallet, honouring the nonce offset.
+// Released nonces are handed out first. The nonce must be given back with
+// Done.
+func (nm *NonceManager) Next(w *Wallet, offset uint64) uint64 {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	t := nm.tracker(w)
+
+	w.Lock()
+	defer w.Unlock()
+
+	if offset == 0 && len(t.released) > 0 {
+		nonce := t.released[0]
+		t.released = t.released[1:]
+		t.reserved[nonce] = false
+		return nonce
+	}
+
+	nonce := w.Nonce
+	if offset > 0 {
+		if w.OffsetNonce <= w.Nonce {
+			w.OffsetNonce = w.Nonce + offset
+		}
+		nonce = w.OffsetNonce
+	} else {
+		w.Nonce++
+	}
+	w.OffsetNonce++
+	t.reserved[nonce] = offset > 0
+	return nonce
+}
+
+// Done gives back a nonce reserved with Next. sent reports whether the
+// transaction reached the node, err is the error of the send. A nonce which
+// was not consumed is released, and handed out again.
+func (nm *NonceManager) Done(w *Wallet, nonce uint64, sent bool, err error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	t := nm.tracker(w)
+
+	offset, ok := t.reserved[nonce]
+	if !ok {
+		return
+	}
+	delete(t.reserved, nonce)
+
+	switch {
+	case sent:
+		t.inFlight[nonce] = struct{}{}
+	case err != nil && NonceConsumed(err):
+		// taken by a transaction known to the node, gaps are caught by Check
+	default:
+		nm.release(t, nonce, offset)
+	}
+}
+
+func (nm *NonceManager) release(t *nonceTracker, nonce uint64, offset bool) {
+	w := t.wallet
+	w.Lock()
+	defer w.Unlock()
+
+	if offset {
+		if w.OffsetNonce == nonce+1 {
+			w.OffsetNonce--
+		}
+		return
+	}
+	if w.Nonce == nonce+1 {
+		w.Nonce--
+		w.OffsetNonce--
+		return
+	}
+	if nonce < w.Nonce && !slices.Contains(t.released, nonce) {
+		t.released = append(t.released, nonce)
+		slices.Sort(t.released)
+	}
+}
+
+// Run checks the nonces of the wallets every interval until ctx is done.
+func (nm *NonceManager) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultNonceCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			nm.Check(ctx)
+		}
+	}
+}
+
+// Check compares the nonces of the wallets with work outstanding to the
+// latest and pending nonces of the chain. Mined nonces are dropped from the
+// in-flight ones, the wallet catches up when the chain is ahead of it, and
+// gaps seen at the previous check too are filled.
+func (nm *NonceManager) Check(ctx context.Context) {
+	nm.mu.Lock()
+	trackers := make([]*nonceTracker, 0, len(nm.trackers))
+	for _, t := range nm.trackers {
+		t.wallet.mu.RLock()
+		next := t.wallet.Nonce
+		t.wallet.mu.RUnlock()
+		if len(t.inFlight) > 0 || len(t.released) > 0 || len(t.gaps) > 0 || t.pending < next {
+			trackers = append(trackers, t)
+		}
+	}
+	nm.mu.Unlock()
+
+	sem := semaphore.NewWeighted(maxNonceChecksAtOnce)
+	for _, t := range trackers {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return
+		}
+		go func() {
+			defer sem.Release(1)
+			nm.check(ctx, t)
+		}()
+	}
+	_ = sem.Acquire(ctx, maxNonceChecksAtOnce)
+}
+
+func (nm *NonceManager) check(ctx context.Context, t *nonceTracker) {
+	addr := t.wallet.Address
+	latest, err := nm.reader.NonceAt(ctx, addr, nil)
+	if err != nil {
+		return
+	}
+	pending, err := nm.reader.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return
+	}
+
+	nm.mu.Lock()
+	fill := nm.reconcile(t, latest, pending)
+	nm.mu.Unlock()
+
+	for _, nonce := range fill {
+		err := nm.fill(ctx, t.wallet, nonce)
+
+		nm.mu.Lock()
+		if err == nil {
+			t.filled++
+			t.inFlight[nonce] = struct{}{}
+			delete(t.gaps, nonce)
+		} else {
+			nm.release(t, nonce, false)
+		}
+		delete(t.reserved, nonce)
+		if len(nm.events) >= maxPendingGapFills {
+			nm.events = nm.events[1:]
+		}
+		nm.events = append(nm.events, &GapFillEvent{Address: addr, Nonce: nonce, Failed: err != nil, Time: time.Now()})
+		nm.mu.Unlock()
+	}
+}
+
+// reconcile updates the tracker with the nonces of the chain and returns the
+// gaps to fill, reserved for the filler.
+func (nm *NonceManager) reconcile(t *nonceTracker, latest, pending uint64) []uint64 {
+	w := t.wallet
+	t.latest, t.pending, t.lastCheck = latest, pending, time.Now()
+
+	for nonce := range t.inFlight {
+		if nonce < latest {
+			delete(t.inFlight, nonce)
+		}
+	}
+	t.released = slices.DeleteFunc(t.released, func(nonce uint64) bool { return nonce < pending })
+
+	w.Lock()
+	if pending > w.Nonce {
+		// the chain is ahead, nonces were used outside of the manager
+		w.Nonce = pending
+		if w.OffsetNonce < pending {
+			w.OffsetNonce = pending
+		}
+	}
+	next := w.Nonce
+	w.Unlock()
+
+	// the pending nonce is the first one missing from the pool, released
+	// nonces are missing as well until they are handed out again
+	gaps := make(map[uint64]struct{})
+	if pending < next {
+		if _, ok := t.reserved[pending]; !ok {
+			gaps[pending] = struct{}{}
+		}
+	}
+	for _, nonce := range t.released {
+		gaps[nonce] = struct{}{}
+	}
+
+	fill := make([]uint64, 0)
+	for nonce := range gaps {
+		if _, seen := t.gaps[nonce]; seen {
+			fill = append(fill, nonce)
+		}
+	}
+	t.gaps = gaps
+	slices.Sort(fill)
+
+	for _, nonce := range fill {
+		t.released = slices.DeleteFunc(t.released, func(n uint64) bool { return n == nonce })
+		delete(t.inFlight, nonce)
+		t.reserved[nonce] = false
+	}
+	return fill
+}
+
+// State returns the nonce bookkeeping of the wallet.
+func (nm *NonceManager) State(addr common.Address) (*NonceState, bool) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	t, ok := nm.trackers[addr]
+	if !ok {
+		return nil, false
+	}
+	return t.state(), true
+}
+
+// States returns the nonce bookkeeping of every wallet used so far.
+func (nm *NonceManager) States() []*NonceState {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	states := make([]*NonceState, 0, len(nm.trackers))
+	for _, t := range nm.trackers {
+		states = append(states, t.state())
+	}
+	slices.SortFunc(states, func(a, b *NonceState) int { return strings.Compare(a.Address, b.Address) })
+	return states
+}
+
+// DrainGapFills returns the gap fills since the last call.
+func (nm *NonceManager) DrainGapFills() []*GapFillEvent {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	events := nm.events
+	nm.events = make([]*GapFillEvent, 0)
+	return events
+}
+
+func (t *nonceTracker) state() *NonceState {
+	t.wallet.mu.RLock()
+	next, offset := t.wallet.Nonce, t.wallet.OffsetNonce
+	t.wallet.mu.RUnlock()
+
+	return &NonceState{
+		Address:   t.wallet.Address.Hex(),
+		Next:      next,
+		Offset:    offset,
+		Latest:    t.latest,
+		Pending:   t.pending,
+		Reserved:  sortedKeys(t.reserved),
+		InFlight:  sortedKeys(t.inFlight),
+		Released:  slices.Clone(t.released),
+		Gaps:      sortedKeys(t.gaps),
+		Filled:    t.filled,
+		LastCheck: t.lastCheck,
+	}
+}
+
+func sortedKeys[V any](m map[uint64]V) []uint64 {
+	keys := make([]uint64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,211 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNonces is the chain side of the nonce manager.
+type fakeNonces struct {
+	latest  uint64
+	pending uint64
+	filled  []uint64
+	fillErr error
+	mu      *sync.Mutex
+}
+
+func (fn *fakeNonces) NonceAt(_ context.Context, _ common.Address, _ *big.Int) (uint64, error) {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	return fn.latest, nil
+}
+
+func (fn *fakeNonces) PendingNonceAt(_ context.Context, _ common.Address) (uint64, error) {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	return fn.pending, nil
+}
+
+func (fn *fakeNonces) fill(_ context.Context, _ *Wallet, nonce uint64) error {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	if fn.fillErr != nil {
+		return fn.fillErr
+	}
+	fn.filled = append(fn.filled, nonce)
+	return nil
+}
+
+func newTestNonceManager(t *testing.T, nonce uint64) (*NonceManager, *fakeNonces, *Wallet) {
+	fn := &fakeNonces{latest: nonce, pending: nonce, mu: &sync.Mutex{}}
+	w := createTestWallet(t)
+	w.Nonce, w.OffsetNonce = nonce, nonce
+	return NewNonceManager(fn, fn.fill), fn, w
+}
+
+func TestNonceManager_Next(t *testing.T) {
+	t.Run("without offset", func(t *testing.T) {
+		nm, _, w := newTestNonceManager(t, 5)
+		assert.Equal(t, uint64(5), nm.Next(w, 0))
+		assert.Equal(t, uint64(6), nm.Next(w, 0))
+		assert.Equal(t, uint64(7), w.Nonce)
+		assert.Equal(t, uint64(7), w.OffsetNonce)
+	})
+
+	t.Run("with offset", func(t *testing.T) {
+		nm, _, w := newTestNonceManager(t, 5)
+		assert.Equal(t, uint64(8), nm.Next(w, 3))
+		assert.Equal(t, uint64(9), nm.Next(w, 3))
+		assert.Equal(t, uint64(5), w.Nonce)
+		assert.Equal(t, uint64(10), w.OffsetNonce)
+	})
+}
+
+func TestNonceManager_Done(t *testing.T) {
+	t.Run("last reserved nonce", func(t *testing.T) {
+		nm, _, w := newTestNonceManager(t, 5)
+		nonce := nm.Next(w, 0)
+		nm.Done(w, nonce, false, errors.New("connection refused"))
+		assert.Equal(t, uint64(5), w.Nonce)
+		assert.Equal(t, uint64(5), nm.Next(w, 0))
+	})
+
+	t.Run("nonce reserved after is handed out again first", func(t *testing.T) {
+		nm, _, w := newTestNonceManager(t, 5)
+		nonce := nm.Next(w, 0)
+		nm.Next(w, 0)
+		nm.Done(w, nonce, false, errors.New("fee cap less than block base fee"))
+		assert.Equal(t, uint64(7), w.Nonce)
+		assert.Equal(t, uint64(5), nm.Next(w, 0))
+		assert.Equal(t, uint64(7), nm.Next(w, 0))
+	})
+
+	t.Run("with offset", func(t *testing.T) {
+		nm, _, w := newTestNonceManager(t, 5)
+		nonce := nm.Next(w, 3)
+		nm.Done(w, nonce, false, nil)
+		assert.Equal(t, uint64(8), w.OffsetNonce)
+		assert.Equal(t, uint64(5), w.Nonce)
+	})
+
+	t.Run("consumed by the node", func(t *testing.T) {
+		for _, err := range []error{errors.New("nonce too low"), errors.New("already known")} {
+			nm, _, w := newTestNonceManager(t, 5)
+			nonce := nm.Next(w, 0)
+			nm.Done(w, nonce, false, err)
+			assert.Equal(t, uint64(6), w.Nonce, err.Error())
+		}
+	})
+
+	t.Run("sent", func(t *testing.T) {
+		nm, _, w := newTestNonceManager(t, 5)
+		nonce := nm.Next(w, 0)
+		nm.Done(w, nonce, true, errors.New("confirmation timeout"))
+
+		state, ok := nm.State(w.Address)
+		require.True(t, ok)
+		assert.Equal(t, []uint64{5}, state.InFlight)
+		assert.Empty(t, state.Reserved)
+		assert.Equal(t, uint64(6), state.Next)
+	})
+}
+
+func TestNonceManager_Check(t *testing.T) {
+	t.Run("drops mined nonces", func(t *testing.T) {
+		nm, fn, w := newTestNonceManager(t, 5)
+		for range 3 {
+			nm.Done(w, nm.Next(w, 0), true, nil)
+		}
+		fn.latest, fn.pending = 7, 8
+
+		nm.Check(context.Background())
+		state, _ := nm.State(w.Address)
+		assert.Equal(t, []uint64{7}, state.InFlight)
+		assert.Empty(t, state.Gaps)
+		assert.Empty(t, fn.filled)
+	})
+
+	t.Run("fills a gap seen twice", func(t *testing.T) {
+		nm, fn, w := newTestNonceManager(t, 5)
+		for range 3 {
+			nm.Done(w, nm.Next(w, 0), true, nil)
+		}
+		// 6 was dropped, 7 is queued behind it
+		fn.latest, fn.pending = 6, 6
+
+		nm.Check(context.Background())
+		state, _ := nm.State(w.Address)
+		assert.Equal(t, []uint64{6}, state.Gaps)
+		assert.Empty(t, fn.filled)
+
+		nm.Check(context.Background())
+		assert.Equal(t, []uint64{6}, fn.filled)
+		state, _ = nm.State(w.Address)
+		assert.Equal(t, uint64(1), state.Filled)
+		assert.Equal(t, uint64(8), state.Next)
+
+		events := nm.DrainGapFills()
+		require.Len(t, events, 1)
+		assert.Equal(t, uint64(6), events[0].Nonce)
+		assert.False(t, events[0].Failed)
+		assert.Empty(t, nm.DrainGapFills())
+	})
+
+	t.Run("fills released nonces of idle wallets", func(t *testing.T) {
+		nm, fn, w := newTestNonceManager(t, 5)
+		nonce := nm.Next(w, 0)
+		nm.Done(w, nm.Next(w, 0), true, nil)
+		nm.Done(w, nonce, false, errors.New("connection refused"))
+		fn.pending = 5
+
+		nm.Check(context.Background())
+		nm.Check(context.Background())
+		assert.Equal(t, []uint64{5}, fn.filled)
+		state, _ := nm.State(w.Address)
+		assert.Empty(t, state.Released)
+		assert.Equal(t, uint64(7), nm.Next(w, 0))
+	})
+
+	t.Run("keeps failed fills released", func(t *testing.T) {
+		nm, fn, w := newTestNonceManager(t, 5)
+		nm.Done(w, nm.Next(w, 0), true, nil)
+		nm.Done(w, nm.Next(w, 0), true, nil)
+		fn.fillErr = errors.New("insufficient funds")
+
+		nm.Check(context.Background())
+		nm.Check(context.Background())
+		state, _ := nm.State(w.Address)
+		assert.Equal(t, []uint64{5}, state.Released)
+
+		events := nm.DrainGapFills()
+		require.Len(t, events, 1)
+		assert.True(t, events[0].Failed)
+	})
+
+	t.Run("skips reserved nonces", func(t *testing.T) {
+		nm, fn, w := newTestNonceManager(t, 5)
+		nm.Next(w, 0)
+
+		nm.Check(context.Background())
+		nm.Check(context.Background())
+		assert.Empty(t, fn.filled)
+	})
+
+	t.Run("catches up with the chain", func(t *testing.T) {
+		nm, fn, w := newTestNonceManager(t, 5)
+		nm.Done(w, nm.Next(w, 0), true, nil)
+		fn.latest, fn.pending = 9, 9
+
+		nm.Check(context.Background())
+		assert.Equal(t, uint64(9), w.Nonce)
+		assert.Equal(t, uint64(9), w.OffsetNonce)
+		assert.Equal(t, uint64(9), nm.Next(w, 0))
+	})
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cockroachdb/pebble"
@@ -12,8 +14,12 @@ import (
 )
 
 type PebbleDb struct {
-	pth string
-	db  *pebble.DB
+	pth      string
+	db       *pebble.DB
+	refs     int
+	closed   bool
+	readOnly bool
+	mu       *sync.Mutex
 }
 
 func NewPebbleDb(pth string) (*PebbleDb, error) {
@@ -22,10 +28,11 @@ func NewPebbleDb(pth string) (*PebbleDb, error) {
 		return nil, err
 	}
 
+	var prevPath string
 	if _, err := os.Stat(pth); err == nil {
 		date := time.Now().Format("2006-01-02_15-04-05")
-		newPath := fmt.Sprintf("%s_%s", strings.TrimSuffix(pth, ".db"), date)
-		if err := os.Rename(pth, newPath); err != nil {
+		prevPath = fmt.Sprintf("%s_%s", strings.TrimSuffix(pth, ".db"), date)
+		if err := os.Rename(pth, prevPath); err != nil {
 			return nil, err
 		}
 	}
@@ -35,13 +42,102 @@ func NewPebbleDb(pth string) (*PebbleDb, error) {
 		return nil, err
 	}
 
-	return &PebbleDb{pth: pth, db: db}, nil
+	pdb := &PebbleDb{pth: pth, db: db, mu: &sync.Mutex{}}
+	if prevPath != "" {
+		if err := pdb.carryOver(prevPath, walletKeyPrefixes); err != nil {
+			db.Close() // nolint:errcheck
+			return nil, fmt.Errorf("failed to carry over wallets: %w", err)
+		}
+	}
+	return pdb, nil
+}
+
+// OpenPebbleDbReadOnly opens the database at pth without rotating it, e.g.
+// the database of a previous run.
+func OpenPebbleDbReadOnly(pth string) (*PebbleDb, error) {
+	if _, err := os.Stat(pth); err != nil {
+		return nil, err
+	}
+	db, err := pebble.Open(pth, &pebble.Options{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &PebbleDb{pth: pth, db: db, readOnly: true, mu: &sync.Mutex{}}, nil
+}
+
+// PastRuns returns the databases rotated away from pth by NewPebbleDb, oldest
+// first.
+func PastRuns(pth string) ([]string, error) {
+	matches, err := filepath.Glob(strings.TrimSuffix(pth, ".db") + "_*")
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.IsDir() {
+			runs = append(runs, m)
+		}
+	}
+	// the date suffix sorts in time order
+	slices.Sort(runs)
+	return runs, nil
+}
+
+// carryOver copies the keys with the prefixes from the database at pth, so
+// stored wallets survive the rotation of the database.
+func (pdb *PebbleDb) carryOver(pth string, prefixes []string) error {
+	prev, err := pebble.Open(pth, &pebble.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer prev.Close() // nolint:errcheck
+
+	batch := pdb.db.NewBatch()
+	defer batch.Close() // nolint:errcheck
+	for _, prefix := range prefixes {
+		lower := pdb.GenKey(prefix, "")
+		iter, err := prev.NewIter(&pebble.IterOptions{LowerBound: lower, UpperBound: prefixUpperBound(lower)})
+		if err != nil {
+			return err
+		}
+		for iter.First(); iter.Valid(); iter.Next() {
+			if err := batch.Set(iter.Key(), iter.Value(), nil); err != nil {
+				iter.Close() // nolint:errcheck
+				return err
+			}
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// Retain registers a user of the database, it is closed once every user
+// closed it.
+func (pdb *PebbleDb) Retain() {
+	pdb.mu.Lock()
+	defer pdb.mu.Unlock()
+	pdb.refs++
 }
 
 func (pdb *PebbleDb) Close() error {
 	if pdb == nil || pdb.db == nil {
 		return nil
 	}
+	pdb.mu.Lock()
+	defer pdb.mu.Unlock()
+	if pdb.refs > 0 {
+		pdb.refs--
+	}
+	if pdb.refs > 0 || pdb.closed {
+		return nil
+	}
+	pdb.closed = true
+
+	if pdb.readOnly {
+		return pdb.db.Close()
+	}
 	if err := pdb.db.Flush(); err != nil {
 		return err
 	}
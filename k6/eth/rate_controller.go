@@ -0,0 +1,150 @@
+package eth
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	RateControllerAIMD    = "aimd"
+	RateControllerPID     = "pid"
+	RateControllerLatency = "latency"
+)
+
+// RateFeedback is what the node showed since the last rate update.
+type RateFeedback struct {
+	TxPoolSize uint64        // pending and queued transactions
+	TimeToMine time.Duration // average time to mine, 0 when no transaction was mined
+	Interval   time.Duration // time since the last update
+	MinRate    float64       // lower bound of the rate, set by the limiter
+	MaxRate    float64       // upper bound of the rate, set by the limiter, 0 means unbounded
+}
+
+// RateController computes the next send rate in tx/s from the current rate
+// and the feedback of the node.
+type RateController interface {
+	Next(current float64, feedback RateFeedback) float64
+}
+
+// AIMDController increases the rate additively while the tx pool is below
+// the steady state size and backs off multiplicatively above it.
+type AIMDController struct {
+	SteadyStateTxPoolSize uint64
+	Increase              float64
+	BackoffFactor         float64
+}
+
+func NewAIMDController() *AIMDController {
+	return &AIMDController{
+		SteadyStateTxPoolSize: 1000,
+		Increase:              50,
+		BackoffFactor:         2.0,
+	}
+}
+
+func (c *AIMDController) Next(current float64, feedback RateFeedback) float64 {
+	switch {
+	case feedback.TxPoolSize < c.SteadyStateTxPoolSize:
+		return current + c.Increase
+	case feedback.TxPoolSize > c.SteadyStateTxPoolSize:
+		return current / c.BackoffFactor
+	default:
+		return current
+	}
+}
+
+// PIDController steers the tx pool size to TargetTxPoolSize, the error is
+// counted in transactions. The rate is the rate at the first update plus the
+// PID terms. The integral is frozen while the rate is held at one of the
+// bounds of the limiter, so it does not wind up.
+type PIDController struct {
+	TargetTxPoolSize uint64
+	Kp               float64
+	Ki               float64
+	Kd               float64
+
+	base     *float64
+	integral float64
+	prevErr  *float64
+}
+
+func NewPIDController() *PIDController {
+	return &PIDController{
+		TargetTxPoolSize: 1000,
+		Kp:               0.1,
+		Ki:               0.01,
+		Kd:               0.05,
+	}
+}
+
+func (c *PIDController) Next(current float64, feedback RateFeedback) float64 {
+	dt := feedback.Interval.Seconds()
+	if dt <= 0 {
+		return current
+	}
+	if c.base == nil {
+		c.base = &current
+	}
+
+	err := float64(c.TargetTxPoolSize) - float64(feedback.TxPoolSize)
+	var derivative float64
+	if c.prevErr != nil {
+		derivative = (err - *c.prevErr) / dt
+	}
+	c.prevErr = &err
+
+	integral := c.integral + err*dt
+	next := *c.base + c.Kp*err + c.Ki*integral + c.Kd*derivative
+	switch {
+	case next < feedback.MinRate && err < 0:
+		return feedback.MinRate
+	case feedback.MaxRate > 0 && next > feedback.MaxRate && err > 0:
+		return feedback.MaxRate
+	}
+	c.integral = integral
+	return next
+}
+
+// LatencyController increases the rate additively while transactions are
+// mined faster than TargetTimeToMine and backs off multiplicatively when they
+// are slower. The rate is kept when nothing was mined.
+type LatencyController struct {
+	TargetTimeToMine time.Duration
+	Increase         float64
+	BackoffFactor    float64
+}
+
+func NewLatencyController() *LatencyController {
+	return &LatencyController{
+		TargetTimeToMine: 2 * EthDefaultBlockTime,
+		Increase:         50,
+		BackoffFactor:    2.0,
+	}
+}
+
+func (c *LatencyController) Next(current float64, feedback RateFeedback) float64 {
+	switch {
+	case feedback.TimeToMine == 0:
+		return current
+	case feedback.TimeToMine < c.TargetTimeToMine:
+		return current + c.Increase
+	case feedback.TimeToMine > c.TargetTimeToMine:
+		return current / c.BackoffFactor
+	default:
+		return current
+	}
+}
+
+// NewRateController returns the controller of type with its defaults.
+func NewRateController(typ string) (RateController, error) {
+	switch typ {
+	case "", RateControllerAIMD:
+		return NewAIMDController(), nil
+	case RateControllerPID:
+		return NewPIDController(), nil
+	case RateControllerLatency:
+		return NewLatencyController(), nil
+	default:
+		return nil, fmt.Errorf("unknown rate controller: %s", typ)
+	}
+}
@@ -0,0 +1,86 @@
+package eth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIMDController(t *testing.T) {
+	c := NewAIMDController()
+
+	assert.Equal(t, 150.0, c.Next(100, RateFeedback{TxPoolSize: 10}))
+	assert.Equal(t, 50.0, c.Next(100, RateFeedback{TxPoolSize: 5000}))
+	assert.Equal(t, 100.0, c.Next(100, RateFeedback{TxPoolSize: 1000}))
+}
+
+func TestPIDController(t *testing.T) {
+	t.Run("holds without interval", func(t *testing.T) {
+		c := NewPIDController()
+		assert.Equal(t, 100.0, c.Next(100, RateFeedback{TxPoolSize: 0}))
+	})
+
+	t.Run("steers to target", func(t *testing.T) {
+		c := &PIDController{TargetTxPoolSize: 1000, Kp: 0.1, Ki: 0.01, Kd: 0.05}
+
+		// base 100, err 1000, integral 1000, no derivative on the first update
+		assert.InDelta(t, 210.0, c.Next(100, RateFeedback{TxPoolSize: 0, Interval: time.Second}), 1e-9)
+		// err 0, integral 1000, derivative -1000, the rate is not added up
+		assert.InDelta(t, 60.0, c.Next(210, RateFeedback{TxPoolSize: 1000, Interval: time.Second}), 1e-9)
+	})
+
+	t.Run("saturated output", func(t *testing.T) {
+		c := &PIDController{TargetTxPoolSize: 1000, Kp: 0.1, Ki: 0.01}
+
+		for range 10 {
+			assert.Equal(t, 150.0, c.Next(150, RateFeedback{TxPoolSize: 0, Interval: time.Second, MinRate: 1, MaxRate: 150}))
+		}
+		// the integral did not wind up while the rate was held at the maximum:
+		// base 150, err -200, integral -200
+		assert.InDelta(t, 128.0, c.Next(150, RateFeedback{TxPoolSize: 1200, Interval: time.Second, MinRate: 1, MaxRate: 150}), 1e-9)
+	})
+}
+
+func TestLatencyController(t *testing.T) {
+	c := NewLatencyController()
+
+	assert.Equal(t, 100.0, c.Next(100, RateFeedback{}))
+	assert.Equal(t, 150.0, c.Next(100, RateFeedback{TimeToMine: time.Second}))
+	assert.Equal(t, 50.0, c.Next(100, RateFeedback{TimeToMine: time.Minute}))
+}
+
+func TestNewRateController(t *testing.T) {
+	for typ, expected := range map[string]RateController{
+		"":                    &AIMDController{},
+		RateControllerAIMD:    &AIMDController{},
+		RateControllerPID:     &PIDController{},
+		RateControllerLatency: &LatencyController{},
+	} {
+		c, err := NewRateController(typ)
+		require.NoError(t, err)
+		assert.IsType(t, expected, c, typ)
+	}
+
+	_, err := NewRateController("bbr")
+	assert.Error(t, err)
+}
+
+func TestTxPoolRateLimiter(t *testing.T) {
+	rl := NewControlledRateLimiter(100, NewAIMDController(), 120)
+
+	rl.UpdateTxPoolSize(0)
+	assert.Equal(t, 120.0, rl.Limit())
+
+	for range 10 {
+		rl.UpdateTxPoolSize(5000)
+	}
+	assert.Equal(t, 1.0, rl.Limit())
+
+	history := rl.DrainHistory()
+	require.Len(t, history, 12)
+	assert.Equal(t, 100.0, history[0].Rate)
+	assert.Equal(t, 120.0, history[1].Rate)
+	assert.Empty(t, rl.DrainHistory())
+}
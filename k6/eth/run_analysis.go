@@ -0,0 +1,340 @@
+package eth
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// DefaultRegressionThreshold is the relative change of a metric flagged as a
+// regression when diffing runs.
+const DefaultRegressionThreshold = 0.1
+
+// ErrNoJournal is returned for the databases of runs before the journal, which
+// only recorded the send time of the transactions.
+var ErrNoJournal = errors.New("run has no journal")
+
+// RunAnalysis is the summary of the journal of a run.
+type RunAnalysis struct {
+	Path      string `json:"path"`
+	Sent      int    `json:"sent"`
+	Included  int    `json:"included"`
+	Succeeded int    `json:"succeeded"`
+	Reverted  int    `json:"reverted"`
+	Dropped   int    `json:"dropped"`
+	Pending   int    `json:"pending"` // neither included nor dropped
+
+	TimeToMine *LatencyStats `json:"timeToMine"` // of the included transactions
+	Throughput float64       `json:"throughput"` // included tx/s between the first send and the last inclusion
+
+	// inclusion order fairness: pairs of included transactions mined in the
+	// reverse order they were sent in, and the share of pairs that were not
+	Inversions int64   `json:"inversions"`
+	Fairness   float64 `json:"fairness"`
+
+	Wallets []*WalletAnalysis `json:"wallets"`
+}
+
+// LatencyStats are in milliseconds.
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Min   int64   `json:"min"`
+	Max   int64   `json:"max"`
+	Mean  float64 `json:"mean"`
+	P50   int64   `json:"p50"`
+	P90   int64   `json:"p90"`
+	P95   int64   `json:"p95"`
+	P99   int64   `json:"p99"`
+}
+
+type WalletAnalysis struct {
+	Wallet     string  `json:"wallet"`
+	Sent       int     `json:"sent"`
+	Included   int     `json:"included"`
+	Dropped    int     `json:"dropped"`
+	Throughput float64 `json:"throughput"` // included tx/s between the first send and the last inclusion
+	TimeToMine float64 `json:"timeToMine"` // mean in milliseconds
+}
+
+// AnalyzeJournal summarizes the transactions of the journal, of every client.
+// Databases of runs before the journal are rejected with ErrNoJournal rather
+// than summarized as empty.
+func AnalyzeJournal(j *Journal) (*RunAnalysis, error) {
+	a := &RunAnalysis{Path: j.db.pth, Wallets: make([]*WalletAnalysis, 0)}
+	included := make([]*JournalEntry, 0)
+	timed := make([]*JournalEntry, 0) // included with a known time to mine
+	wallets := make(map[string]*walletSpan)
+
+	var span timeSpan
+	err := j.Entries("", func(entry *JournalEntry) error {
+		a.Sent++
+		ws, ok := wallets[entry.Wallet]
+		if !ok {
+			ws = &walletSpan{analysis: &WalletAnalysis{Wallet: entry.Wallet}}
+			wallets[entry.Wallet] = ws
+		}
+		ws.analysis.Sent++
+		ws.span.add(entry.SentAt)
+		span.add(entry.SentAt)
+
+		switch {
+		case entry.Dropped:
+			a.Dropped++
+			ws.analysis.Dropped++
+		case entry.IncludedBlock == 0:
+			a.Pending++
+		default:
+			a.Included++
+			ws.analysis.Included++
+			included = append(included, entry)
+			if entry.IncludedAt != 0 {
+				ws.timed++
+				ws.timeToMine += entry.TimeToMine
+				ws.span.add(entry.IncludedAt)
+				span.add(entry.IncludedAt)
+				timed = append(timed, entry)
+			}
+		}
+		if entry.Status != nil {
+			if *entry.Status == 1 {
+				a.Succeeded++
+			} else {
+				a.Reverted++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if a.Sent == 0 {
+		n, err := countKeys(j.db, "tx")
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			return nil, fmt.Errorf("%s has %d transactions without their wallet and inclusion: %w", j.db.pth, n, ErrNoJournal)
+		}
+	}
+
+	a.TimeToMine = latencyStats(timed)
+	a.Throughput = span.rate(a.Included)
+	a.Inversions, a.Fairness = inclusionFairness(included)
+	for _, ws := range wallets {
+		ws.analysis.Throughput = ws.span.rate(ws.analysis.Included)
+		if ws.timed > 0 {
+			ws.analysis.TimeToMine = float64(ws.timeToMine) / float64(ws.timed)
+		}
+		a.Wallets = append(a.Wallets, ws.analysis)
+	}
+	slices.SortFunc(a.Wallets, func(x, y *WalletAnalysis) int { return strings.Compare(x.Wallet, y.Wallet) })
+	return a, nil
+}
+
+// countKeys returns the number of keys of the kind.
+func countKeys(db *PebbleDb, kind string) (int, error) {
+	prefix := db.GenKey(kind, "")
+	iter, err := db.Db().NewIter(&pebble.IterOptions{LowerBound: prefix, UpperBound: prefixUpperBound(prefix)})
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close() // nolint:errcheck
+
+	n := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		n++
+	}
+	return n, iter.Error()
+}
+
+type walletSpan struct {
+	analysis   *WalletAnalysis
+	span       timeSpan
+	timed      int
+	timeToMine int64
+}
+
+// timeSpan is the range of unix millis seen.
+type timeSpan struct {
+	first, last int64
+}
+
+func (s *timeSpan) add(t int64) {
+	if s.first == 0 || t < s.first {
+		s.first = t
+	}
+	if t > s.last {
+		s.last = t
+	}
+}
+
+// rate returns n per second over the span.
+func (s *timeSpan) rate(n int) float64 {
+	if s.last <= s.first {
+		return 0
+	}
+	return float64(n) / (float64(s.last-s.first) / 1000)
+}
+
+func latencyStats(entries []*JournalEntry) *LatencyStats {
+	stats := &LatencyStats{Count: len(entries)}
+	if len(entries) == 0 {
+		return stats
+	}
+	latencies := make([]int64, len(entries))
+	var sum int64
+	for i, entry := range entries {
+		latencies[i] = entry.TimeToMine
+		sum += entry.TimeToMine
+	}
+	slices.Sort(latencies)
+
+	// nearest rank
+	percentile := func(p float64) int64 {
+		rank := int(math.Ceil(p*float64(len(latencies)))) - 1
+		return latencies[max(rank, 0)]
+	}
+	stats.Min = latencies[0]
+	stats.Max = latencies[len(latencies)-1]
+	stats.Mean = float64(sum) / float64(len(latencies))
+	stats.P50 = percentile(0.5)
+	stats.P90 = percentile(0.9)
+	stats.P95 = percentile(0.95)
+	stats.P99 = percentile(0.99)
+	return stats
+}
+
+// inclusionFairness counts the pairs of transactions included in an earlier
+// block than a transaction sent before them, and returns the share of pairs
+// included in order. Transactions sent at the same time or included in the
+// same block are in order.
+func inclusionFairness(entries []*JournalEntry) (int64, float64) {
+	if len(entries) < 2 {
+		return 0, 1
+	}
+	sorted := slices.Clone(entries)
+	slices.SortFunc(sorted, func(x, y *JournalEntry) int {
+		return cmp.Or(cmp.Compare(x.SentAt, y.SentAt), cmp.Compare(x.IncludedBlock, y.IncludedBlock))
+	})
+	blocks := make([]uint64, len(sorted))
+	for i, entry := range sorted {
+		blocks[i] = entry.IncludedBlock
+	}
+	inversions := countInversions(blocks, make([]uint64, len(blocks)))
+	n := int64(len(entries))
+	pairs := n * (n - 1) / 2
+	return inversions, 1 - float64(inversions)/float64(pairs)
+}
+
+// countInversions sorts values and returns the number of pairs i < j with
+// values[i] > values[j], with a merge sort.
+func countInversions(values, buf []uint64) int64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mid := len(values) / 2
+	inversions := countInversions(values[:mid], buf[:mid]) + countInversions(values[mid:], buf[mid:])
+
+	i, j, k := 0, mid, 0
+	for i < mid && j < len(values) {
+		if values[i] <= values[j] {
+			buf[k] = values[i]
+			i++
+		} else {
+			buf[k] = values[j]
+			inversions += int64(mid - i)
+			j++
+		}
+		k++
+	}
+	k += copy(buf[k:], values[i:mid])
+	copy(buf[k:], values[j:])
+	copy(values, buf[:len(values)])
+	return inversions
+}
+
+// MetricDiff is the change of a metric between two runs.
+type MetricDiff struct {
+	Name       string  `json:"name"`
+	Base       float64 `json:"base"`
+	Other      float64 `json:"other"`
+	Change     float64 `json:"change"`     // relative to base, 0 when base is 0
+	Regression bool    `json:"regression"` // worse by more than the threshold
+}
+
+type RunDiff struct {
+	Base        string        `json:"base"`
+	Other       string        `json:"other"`
+	Metrics     []*MetricDiff `json:"metrics"`
+	Regressions []string      `json:"regressions"` // names of the regressed metrics
+}
+
+// DiffRuns compares other to base. A metric is a regression when it is worse
+// by more than threshold relative to base, DefaultRegressionThreshold when
+// threshold is not positive. Counts are compared as shares of the sent
+// transactions, so runs of different lengths can be compared.
+func DiffRuns(base, other *RunAnalysis, threshold float64) *RunDiff {
+	if threshold <= 0 {
+		threshold = DefaultRegressionThreshold
+	}
+	share := func(n, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(n) / float64(total)
+	}
+
+	type metric struct {
+		name         string
+		base, other  float64
+		higherBetter bool
+	}
+	metrics := []metric{
+		{"time_to_mine_p50", float64(base.TimeToMine.P50), float64(other.TimeToMine.P50), false},
+		{"time_to_mine_p90", float64(base.TimeToMine.P90), float64(other.TimeToMine.P90), false},
+		{"time_to_mine_p95", float64(base.TimeToMine.P95), float64(other.TimeToMine.P95), false},
+		{"time_to_mine_p99", float64(base.TimeToMine.P99), float64(other.TimeToMine.P99), false},
+		{"time_to_mine_mean", base.TimeToMine.Mean, other.TimeToMine.Mean, false},
+		{"throughput", base.Throughput, other.Throughput, true},
+		{"fairness", base.Fairness, other.Fairness, true},
+		{"included_rate", share(base.Included, base.Sent), share(other.Included, other.Sent), true},
+		{"reverted_rate", share(base.Reverted, base.Sent), share(other.Reverted, other.Sent), false},
+		{"dropped_rate", share(base.Dropped, base.Sent), share(other.Dropped, other.Sent), false},
+	}
+
+	diff := &RunDiff{Base: base.Path, Other: other.Path, Metrics: make([]*MetricDiff, 0, len(metrics)), Regressions: make([]string, 0)}
+	for _, m := range metrics {
+		d := &MetricDiff{Name: m.name, Base: m.base, Other: m.other}
+		if m.base != 0 {
+			d.Change = (m.other - m.base) / math.Abs(m.base)
+		}
+		worse := m.other > m.base
+		if m.higherBetter {
+			worse = m.other < m.base
+		}
+		// a metric appearing from zero, e.g. the first dropped tx, is a
+		// regression as well
+		d.Regression = worse && (m.base == 0 || math.Abs(d.Change) > threshold)
+		if d.Regression {
+			diff.Regressions = append(diff.Regressions, m.name)
+		}
+		diff.Metrics = append(diff.Metrics, d)
+	}
+	return diff
+}
+
+// AnalyzeRun opens the database of a past run read-only and summarizes its
+// journal.
+func AnalyzeRun(pth string) (*RunAnalysis, error) {
+	db, err := OpenPebbleDbReadOnly(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open run %s: %w", pth, err)
+	}
+	defer db.Close() // nolint:errcheck
+	return AnalyzeJournal(NewJournal(db))
+}
@@ -0,0 +1,155 @@
+package eth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountInversions(t *testing.T) {
+	tests := []struct {
+		values []uint64
+		want   int64
+	}{
+		{nil, 0},
+		{[]uint64{1, 2, 3}, 0},
+		{[]uint64{1, 1, 1}, 0},
+		{[]uint64{3, 2, 1}, 3},
+		{[]uint64{2, 4, 1, 3, 5}, 3},
+	}
+	for _, tt := range tests {
+		values := append([]uint64(nil), tt.values...)
+		assert.Equal(t, tt.want, countInversions(values, make([]uint64, len(values))), tt.values)
+	}
+}
+
+func TestAnalyzeRun(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewPebbleDb(pth)
+	require.NoError(t, err)
+
+	j := NewJournal(db)
+	a, b := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	send := func(n byte, wallet common.Address, nonce uint64, sentAt int64) common.Hash {
+		hash := common.BytesToHash([]byte{n})
+		require.NoError(t, j.Sent("client", hash, wallet, nonce, TransactionTypeETH, time.UnixMilli(sentAt)))
+		return hash
+	}
+	include := func(hash common.Hash, block uint64, at int64, status uint64) {
+		require.NoError(t, j.Included(hash.Hex(), block, time.UnixMilli(at)))
+		require.NoError(t, j.Receipt(hash, &ReceiptRecord{Status: status, GasUsed: 21000, BlockNumber: block}))
+	}
+	// b's tx is sent after a's second tx and included before it
+	include(send(1, a, 0, 1_000), 10, 2_000, 1)
+	include(send(2, a, 1, 2_000), 12, 4_000, 0)
+	include(send(3, b, 0, 2_500), 11, 3_000, 1)
+	dropped := send(4, b, 1, 3_000)
+	require.NoError(t, j.Receipt(dropped, &ReceiptRecord{Dropped: true}))
+	send(5, b, 2, 3_500)
+	require.NoError(t, db.Close())
+
+	analysis, err := AnalyzeRun(pth)
+	require.NoError(t, err)
+	assert.Equal(t, pth, analysis.Path)
+	assert.Equal(t, 5, analysis.Sent)
+	assert.Equal(t, 3, analysis.Included)
+	assert.Equal(t, 2, analysis.Succeeded)
+	assert.Equal(t, 1, analysis.Reverted)
+	assert.Equal(t, 1, analysis.Dropped)
+	assert.Equal(t, 1, analysis.Pending)
+	assert.Equal(t, &LatencyStats{Count: 3, Min: 500, Max: 2_000, Mean: 3_500.0 / 3, P50: 1_000, P90: 2_000, P95: 2_000, P99: 2_000}, analysis.TimeToMine)
+	assert.InDelta(t, 1.0, analysis.Throughput, 1e-9) // 3 txs over 3s
+	assert.Equal(t, int64(1), analysis.Inversions)
+	assert.InDelta(t, 2.0/3, analysis.Fairness, 1e-9)
+
+	require.Len(t, analysis.Wallets, 2)
+	assert.Equal(t, &WalletAnalysis{Wallet: a.Hex(), Sent: 2, Included: 2, Throughput: 2.0 / 3, TimeToMine: 1_500}, analysis.Wallets[0])
+	assert.Equal(t, 3, analysis.Wallets[1].Sent)
+	assert.Equal(t, 1, analysis.Wallets[1].Dropped)
+
+	// the database is opened read-only, it is not rotated
+	runs, err := PastRuns(pth)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestAnalyzeRunWithoutJournal(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewPebbleDb(pth)
+	require.NoError(t, err)
+	require.NoError(t, db.Db().Set(db.GenKey("tx", common.HexToHash("0x01").Hex()), make([]byte, 8), nil))
+	require.NoError(t, db.Close())
+
+	_, err = AnalyzeRun(pth)
+	assert.ErrorIs(t, err, ErrNoJournal)
+
+	empty := filepath.Join(t.TempDir(), "empty.db")
+	db, err = NewPebbleDb(empty)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	analysis, err := AnalyzeRun(empty)
+	require.NoError(t, err)
+	assert.Zero(t, analysis.Sent)
+}
+
+func TestDiffRuns(t *testing.T) {
+	base := &RunAnalysis{
+		Path:       "base",
+		Sent:       100,
+		Included:   100,
+		TimeToMine: &LatencyStats{P50: 1_000, P90: 2_000, P95: 2_500, P99: 3_000, Mean: 1_200},
+		Throughput: 50,
+		Fairness:   0.99,
+	}
+	other := &RunAnalysis{
+		Path:       "other",
+		Sent:       200,
+		Included:   198,
+		Dropped:    2,
+		TimeToMine: &LatencyStats{P50: 1_050, P90: 3_000, P95: 2_500, P99: 3_000, Mean: 1_250},
+		Throughput: 60,
+		Fairness:   0.98,
+	}
+
+	diff := DiffRuns(base, other, 0)
+	assert.Equal(t, "base", diff.Base)
+	assert.Equal(t, "other", diff.Other)
+	assert.Equal(t, []string{"time_to_mine_p90", "dropped_rate"}, diff.Regressions)
+
+	for _, m := range diff.Metrics {
+		if m.Name == "throughput" {
+			assert.InDelta(t, 0.2, m.Change, 1e-9)
+			assert.False(t, m.Regression)
+		}
+	}
+
+	// a lower threshold flags smaller changes
+	diff = DiffRuns(base, other, 0.01)
+	assert.Contains(t, diff.Regressions, "time_to_mine_p50")
+	assert.Contains(t, diff.Regressions, "fairness")
+}
+
+func TestPastRuns(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "test.db")
+	for range 2 {
+		db, err := NewPebbleDb(pth)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+		time.Sleep(time.Second) // runs are named by the second
+	}
+	db, err := NewPebbleDb(pth)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	runs, err := PastRuns(pth)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Less(t, runs[0], runs[1])
+
+	_, err = OpenPebbleDbReadOnly(filepath.Join(t.TempDir(), "missing.db"))
+	assert.Error(t, err)
+}
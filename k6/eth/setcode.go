@@ -0,0 +1,50 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// SignAuthorization signs an EIP-7702 authorization delegating the wallet to
+// the delegate address. The authorization consumes nonce, which must be
+// reserved by the caller, e.g. with the NonceManager.
+func (w *Wallet) SignAuthorization(chainID *big.Int, delegate common.Address, nonce uint64) (*types.SetCodeAuthorization, error) {
+	auth, err := types.SignSetCode(w.PrivateKey, types.SetCodeAuthorization{
+		ChainID: *uint256.MustFromBig(chainID),
+		Address: delegate,
+		Nonce:   nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign authorization for wallet %s: %w", w.Address.Hex(), err)
+	}
+	return &auth, nil
+}
+
+// SplitGasUsed splits the gas used by a transaction into the gas charged for
+// processing its authorization list and the gas left for execution. Each of
+// the existing authorities, e.g. funded tester wallets, is refunded the cost
+// of creating the account, leaving the base cost of the authorization. Refunds
+// are assumed not to be capped.
+func SplitGasUsed(tx *types.Transaction, receipt *types.Receipt, existing int) (authGas uint64, execGas uint64, err error) {
+	withAuth, err := core.IntrinsicGas(tx.Data(), tx.AccessList(), tx.SetCodeAuthorizations(), tx.To() == nil, true, true, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	withoutAuth, err := core.IntrinsicGas(tx.Data(), tx.AccessList(), nil, tx.To() == nil, true, true, true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	existing = min(existing, len(tx.SetCodeAuthorizations()))
+	authGas = withAuth - withoutAuth - uint64(existing)*(params.CallNewAccountGas-params.TxAuthTupleGas)
+	if receipt.GasUsed > withoutAuth+authGas {
+		execGas = receipt.GasUsed - withoutAuth - authGas
+	}
+	return authGas, execGas, nil
+}
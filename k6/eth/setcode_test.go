@@ -0,0 +1,66 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWallet_SignAuthorization(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+	wallet.Nonce = 3
+	wallet.OffsetNonce = 3
+
+	delegate := common.HexToAddress("0xc78260046895c358dE4bE97210Efca3900544905")
+	auth, err := wallet.SignAuthorization(big.NewInt(1337), delegate, 3)
+	require.NoError(t, err)
+
+	authority, err := auth.Authority()
+	require.NoError(t, err)
+	assert.Equal(t, wallet.Address, authority)
+	assert.Equal(t, delegate, auth.Address)
+	assert.Equal(t, uint64(3), auth.Nonce)
+	// the nonce is reserved by the caller
+	assert.Equal(t, uint64(3), wallet.Nonce)
+	assert.Equal(t, uint64(3), wallet.OffsetNonce)
+}
+
+func TestSplitGasUsed(t *testing.T) {
+	to := common.HexToAddress("0xc78260046895c358dE4bE97210Efca3900544905")
+	tx := types.NewTx(&types.SetCodeTx{
+		ChainID:   uint256.NewInt(1337),
+		To:        to,
+		Value:     uint256.NewInt(0),
+		GasTipCap: uint256.NewInt(1),
+		GasFeeCap: uint256.NewInt(1),
+		AuthList:  make([]types.SetCodeAuthorization, 2),
+	})
+
+	t.Run("execution gas", func(t *testing.T) {
+		authGas, execGas, err := SplitGasUsed(tx, &types.Receipt{GasUsed: params.TxGas + 2*params.CallNewAccountGas + 100}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2*params.CallNewAccountGas, authGas)
+		assert.Equal(t, uint64(100), execGas)
+	})
+
+	t.Run("existing authorities", func(t *testing.T) {
+		authGas, execGas, err := SplitGasUsed(tx, &types.Receipt{GasUsed: params.TxGas + 2*params.TxAuthTupleGas + 100}, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 2*params.TxAuthTupleGas, authGas)
+		assert.Equal(t, uint64(100), execGas)
+	})
+
+	t.Run("no execution gas", func(t *testing.T) {
+		authGas, execGas, err := SplitGasUsed(tx, &types.Receipt{GasUsed: params.TxGas}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2*params.CallNewAccountGas, authGas)
+		assert.Equal(t, uint64(0), execGas)
+	})
+}
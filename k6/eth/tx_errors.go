@@ -0,0 +1,178 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TxErrorCategory is the kind of error a node returned for a transaction,
+// whatever the client it runs.
+type TxErrorCategory string
+
+const (
+	TxErrNonceTooLow            TxErrorCategory = "nonce_too_low"
+	TxErrNonceTooHigh           TxErrorCategory = "nonce_too_high"
+	TxErrAlreadyKnown           TxErrorCategory = "already_known"
+	TxErrReplacementUnderpriced TxErrorCategory = "replacement_underpriced"
+	TxErrFeeCapBelowBaseFee     TxErrorCategory = "fee_cap_below_base_fee"
+	TxErrUnderpriced            TxErrorCategory = "underpriced"
+	TxErrPoolFull               TxErrorCategory = "pool_full"
+	TxErrInsufficientFunds      TxErrorCategory = "insufficient_funds"
+	TxErrGasLimit               TxErrorCategory = "gas_limit"
+	TxErrExecutionReverted      TxErrorCategory = "execution_reverted"
+	TxErrRateLimited            TxErrorCategory = "rate_limited"
+	TxErrTimeout                TxErrorCategory = "timeout"
+	TxErrCanceled               TxErrorCategory = "canceled"
+	TxErrTransport              TxErrorCategory = "transport"
+	TxErrUnknown                TxErrorCategory = "unknown"
+)
+
+const (
+	rpcErrCodeExecutionReverted = 3      // revert with data, used by most clients
+	rpcErrCodeLimitExceeded     = -32005 // EIP-1474
+)
+
+type txErrorRule struct {
+	category TxErrorCategory
+	messages []string // words, normalized with normalizeErrorMessage
+}
+
+// txErrorRules are the dialects the clients word their transaction pool
+// errors in. Messages are matched on whole words after normalization, so
+// "nonce too low", "NonceTooLow" and "NONCE_TOO_LOW" are the same message while
+// "unknown transaction" is not a "known transaction". Rules are matched in
+// order, more specific messages first.
+var txErrorRules = []txErrorRule{
+	// geth, reth and erigon
+	{TxErrReplacementUnderpriced, []string{"replacement transaction underpriced", "could not replace existing"}},
+	{TxErrAlreadyKnown, []string{"already known"}},
+	{TxErrNonceTooLow, []string{"nonce too low"}},
+	{TxErrNonceTooHigh, []string{"nonce too high"}},
+	{TxErrFeeCapBelowBaseFee, []string{"fee cap less than block base fee", "max fee per gas less than block base fee"}},
+	{TxErrUnderpriced, []string{"transaction underpriced", "tip above fee cap", "max priority fee per gas higher than max fee per gas"}},
+	{TxErrPoolFull, []string{"txpool is full", "txpool full", "tx pool full"}},
+	{TxErrInsufficientFunds, []string{"insufficient funds"}},
+	{TxErrGasLimit, []string{"intrinsic gas too low", "exceeds block gas limit", "gas limit reached"}},
+	{TxErrExecutionReverted, []string{"execution reverted"}},
+
+	// nethermind
+	{TxErrReplacementUnderpriced, []string{"replacement not allowed"}},
+	{TxErrNonceTooLow, []string{"old nonce"}},
+	{TxErrNonceTooHigh, []string{"nonce gap", "nonce too far in the future"}},
+	{TxErrUnderpriced, []string{"fee too low to compete", "fee too low"}},
+
+	// besu
+	{TxErrAlreadyKnown, []string{"known transaction"}},
+	{TxErrNonceTooHigh, []string{"nonce too far in future"}},
+	{TxErrUnderpriced, []string{"gas price below configured minimum gas price", "transaction pricing too low"}},
+	{TxErrPoolFull, []string{"transaction pool is full"}},
+	{TxErrInsufficientFunds, []string{"upfront cost exceeds account balance"}},
+
+	// others and proxies
+	{TxErrAlreadyKnown, []string{"already exists", "already imported"}},
+	{TxErrUnderpriced, []string{"underpriced"}},
+	{TxErrRateLimited, []string{"rate limit", "rate limited", "too many requests", "request limit exceeded"}},
+	{TxErrExecutionReverted, []string{"reverted"}},
+}
+
+// ClassifyTxError returns the category of an error returned while sending a
+// transaction. Transport errors are told apart first, then the JSON-RPC error
+// codes with a single meaning, and the message is matched against the
+// dialects of the clients last.
+func ClassifyTxError(err error) TxErrorCategory {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return TxErrCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return TxErrTimeout
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.StatusCode == http.StatusTooManyRequests {
+			return TxErrRateLimited
+		}
+		return TxErrTransport
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return TxErrTimeout
+		}
+		return TxErrTransport
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return TxErrTransport
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.ErrorCode() {
+		case rpcErrCodeExecutionReverted:
+			return TxErrExecutionReverted
+		case rpcErrCodeLimitExceeded:
+			return TxErrRateLimited
+		}
+	}
+
+	msg := " " + normalizeErrorMessage(err.Error()) + " "
+	for _, rule := range txErrorRules {
+		for _, m := range rule.messages {
+			if strings.Contains(msg, " "+m+" ") {
+				return rule.category
+			}
+		}
+	}
+	return TxErrUnknown
+}
+
+// normalizeErrorMessage splits the message into lower case words separated
+// by a space. Words are split at everything but letters and digits, and at
+// camel case boundaries, e.g. "NonceTooLow" and "NONCE_TOO_LOW" are both
+// "nonce too low".
+func normalizeErrorMessage(msg string) string {
+	runes := []rune(msg)
+	b := strings.Builder{}
+	b.Grow(len(msg))
+	space := true // the previous word ended
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			space = true
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && next) {
+				space = true
+			}
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// NonceConsumed reports whether the nonce of a transaction the node refused
+// is taken anyway, by the transaction or another one known to the node.
+func NonceConsumed(err error) bool {
+	switch ClassifyTxError(err) {
+	case TxErrNonceTooLow, TxErrAlreadyKnown, TxErrReplacementUnderpriced:
+		return true
+	}
+	return false
+}
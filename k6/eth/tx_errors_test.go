@@ -0,0 +1,110 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// jsonError is an error returned by a node, with its JSON-RPC code.
+type jsonError struct {
+	code int
+	msg  string
+}
+
+func (e *jsonError) Error() string  { return e.msg }
+func (e *jsonError) ErrorCode() int { return e.code }
+
+func TestClassifyTxError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected TxErrorCategory
+	}{
+		{"geth nonce too low", &jsonError{-32000, "nonce too low: address 0x01, tx: 1 state: 2"}, TxErrNonceTooLow},
+		{"nethermind nonce too low", &jsonError{-32010, "OldNonce, Current nonce: 2, nonce of rejected tx: 1"}, TxErrNonceTooLow},
+		{"besu nonce too low", &jsonError{-32001, "Nonce too low"}, TxErrNonceTooLow},
+		{"erigon nonce too low", &jsonError{-32000, "NONCE_TOO_LOW"}, TxErrNonceTooLow},
+		{"geth nonce too high", &jsonError{-32000, "nonce too high"}, TxErrNonceTooHigh},
+		{"nethermind nonce gap", &jsonError{-32010, "NonceGap, Future nonce"}, TxErrNonceTooHigh},
+		{"besu nonce too far in future", &jsonError{-32000, "Nonce too far in future"}, TxErrNonceTooHigh},
+		{"geth already known", &jsonError{-32000, "already known"}, TxErrAlreadyKnown},
+		{"nethermind already known", &jsonError{-32010, "AlreadyKnown"}, TxErrAlreadyKnown},
+		{"besu known transaction", &jsonError{-32000, "Known transaction"}, TxErrAlreadyKnown},
+		{"geth replacement underpriced", &jsonError{-32000, "replacement transaction underpriced"}, TxErrReplacementUnderpriced},
+		{"geth could not replace", &jsonError{-32000, "could not replace existing tx"}, TxErrReplacementUnderpriced},
+		{"geth underpriced", &jsonError{-32000, "transaction underpriced: tip needed 1, tip permitted 0"}, TxErrUnderpriced},
+		{"nethermind fee too low", &jsonError{-32010, "FeeTooLow, MaxFeePerGas too low"}, TxErrUnderpriced},
+		{"besu below minimum gas price", &jsonError{-32009, "Gas price below configured minimum gas price"}, TxErrUnderpriced},
+		{"geth fee cap below base fee", &jsonError{-32000, "max fee per gas less than block base fee: address 0x01, maxFeePerGas: 1, baseFee: 7"}, TxErrFeeCapBelowBaseFee},
+		{"fee cap below base fee", errors.New("fee cap less than block base fee"), TxErrFeeCapBelowBaseFee},
+		{"geth pool full", &jsonError{-32000, "txpool is full"}, TxErrPoolFull},
+		{"besu pool full", &jsonError{-32000, "Transaction pool is full"}, TxErrPoolFull},
+		{"geth insufficient funds", &jsonError{-32000, "insufficient funds for gas * price + value: balance 0"}, TxErrInsufficientFunds},
+		{"nethermind insufficient funds", &jsonError{-32010, "InsufficientFunds, Account balance: 0"}, TxErrInsufficientFunds},
+		{"besu upfront cost", &jsonError{-32004, "Upfront cost exceeds account balance"}, TxErrInsufficientFunds},
+		{"intrinsic gas too low", &jsonError{-32000, "intrinsic gas too low"}, TxErrGasLimit},
+		{"execution reverted", &jsonError{3, "execution reverted: not allowed"}, TxErrExecutionReverted},
+		{"revert code", &jsonError{3, "VM execution error."}, TxErrExecutionReverted},
+		{"limit exceeded code", &jsonError{-32005, "limit exceeded"}, TxErrRateLimited},
+		{"rate limited message", errors.New("rate limit reached, retry later"), TxErrRateLimited},
+		{"too many requests", rpc.HTTPError{StatusCode: 429, Status: "429 Too Many Requests"}, TxErrRateLimited},
+		{"bad gateway", rpc.HTTPError{StatusCode: 502, Status: "502 Bad Gateway"}, TxErrTransport},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, TxErrTransport},
+		{"wrapped deadline", fmt.Errorf("failed to send: %w", context.DeadlineExceeded), TxErrTimeout},
+		{"canceled", context.Canceled, TxErrCanceled},
+		{"unknown", &jsonError{-32000, "something else"}, TxErrUnknown},
+		{"unknown transaction", &jsonError{-32000, "unknown transaction"}, TxErrUnknown},
+		{"code before message", &jsonError{3, "execution reverted: nonce too low"}, TxErrExecutionReverted},
+		{"rate limited camel case", errors.New("RateLimited"), TxErrRateLimited},
+		{"nil", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClassifyTxError(tt.err))
+		})
+	}
+}
+
+func TestNormalizeErrorMessage(t *testing.T) {
+	for msg, expected := range map[string]string{
+		"nonce too low: address 0x01": "nonce too low address 0x01",
+		"NonceTooLow":                 "nonce too low",
+		"NONCE_TOO_LOW":               "nonce too low",
+		"OldNonce, Current nonce: 2":  "old nonce current nonce 2",
+		"TXPoolFull":                  "tx pool full",
+		"":                            "",
+	} {
+		assert.Equal(t, expected, normalizeErrorMessage(msg), msg)
+	}
+}
+
+func TestNonceConsumed(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"base fee too low", errors.New("fee cap less than block base fee"), false},
+		{"underpriced", errors.New("transaction underpriced"), false},
+		{"nonce too low", errors.New("nonce too low"), true},
+		{"nethermind old nonce", errors.New("OldNonce"), true},
+		{"already known", errors.New("already known"), true},
+		{"replacement underpriced", errors.New("replacement transaction underpriced"), true},
+		{"connection refused", errors.New("connection refused"), false},
+		{"unknown transaction", errors.New("unknown transaction"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NonceConsumed(tt.err))
+		})
+	}
+}
@@ -3,53 +3,94 @@ package eth
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"golang.org/x/time/rate"
 )
 
+const maxRateHistory = 1024
+
 type PoolStatus struct {
 	BaseFee hexutil.Uint64 `json:"baseFee"`
 	Pending hexutil.Uint64 `json:"pending"`
 	Queued  hexutil.Uint64 `json:"queued"`
 }
 
+// RateSample is the rate limit set by the controller at Time.
+type RateSample struct {
+	Rate float64
+	Time time.Time
+}
+
 type TxPoolRateLimiter struct {
-	steadyStateTxPoolSize      uint64
-	adaptiveRateLimitIncrement uint64
-	mu                         *sync.Mutex
-	limiter                    *rate.Limiter
-	backoffFactor              float64
+	controller RateController
+	minRate    float64
+	maxRate    float64 // 0 means unbounded
+	mu         *sync.Mutex
+	limiter    *rate.Limiter
+	history    []RateSample
 }
 
 func NewTxPoolRateLimiter(initialRate uint64) *TxPoolRateLimiter {
-	limiter := rate.NewLimiter(rate.Limit(initialRate), 1)
-
-	return &TxPoolRateLimiter{
-		steadyStateTxPoolSize:      1000,
-		adaptiveRateLimitIncrement: 50,
-		limiter:                    limiter,
-		backoffFactor:              2.0, // Default backoff factor
-		mu:                         &sync.Mutex{},
+	return NewControlledRateLimiter(initialRate, NewAIMDController(), 0)
+}
+
+// NewControlledRateLimiter returns a limiter whose rate is set by controller
+// on every Update, between 1 and maxRate tx/s.
+func NewControlledRateLimiter(initialRate uint64, controller RateController, maxRate float64) *TxPoolRateLimiter {
+	rl := &TxPoolRateLimiter{
+		controller: controller,
+		minRate:    1,
+		maxRate:    maxRate,
+		limiter:    rate.NewLimiter(rate.Limit(initialRate), 1),
+		mu:         &sync.Mutex{},
 	}
+	rl.record(float64(initialRate))
+	return rl
 }
 
 func (rl *TxPoolRateLimiter) UpdateTxPoolSize(size uint64) {
+	rl.Update(RateFeedback{TxPoolSize: size})
+}
+
+// Update sets the rate computed by the controller from feedback.
+func (rl *TxPoolRateLimiter) Update(feedback RateFeedback) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	currentLimit := rl.limiter.Limit()
-
-	if size < rl.steadyStateTxPoolSize {
-		newLimit := currentLimit + rate.Limit(rl.adaptiveRateLimitIncrement)
-		rl.limiter.SetLimit(newLimit)
-	} else if size > rl.steadyStateTxPoolSize {
-		newLimit := currentLimit / rate.Limit(rl.backoffFactor)
-		if newLimit < 1 {
-			newLimit = 1
-		}
-		rl.limiter.SetLimit(newLimit)
+	feedback.MinRate, feedback.MaxRate = rl.minRate, rl.maxRate
+	newLimit := rl.controller.Next(float64(rl.limiter.Limit()), feedback)
+	if newLimit < rl.minRate {
+		newLimit = rl.minRate
+	}
+	if rl.maxRate > 0 && newLimit > rl.maxRate {
+		newLimit = rl.maxRate
+	}
+	rl.limiter.SetLimit(rate.Limit(newLimit))
+	rl.record(newLimit)
+}
+
+func (rl *TxPoolRateLimiter) record(limit float64) {
+	if len(rl.history) >= maxRateHistory {
+		rl.history = rl.history[1:]
 	}
+	rl.history = append(rl.history, RateSample{Rate: limit, Time: time.Now()})
+}
+
+// Limit returns the current rate in tx/s.
+func (rl *TxPoolRateLimiter) Limit() float64 {
+	return float64(rl.limiter.Limit())
+}
+
+// DrainHistory returns the rates set since the last drain.
+func (rl *TxPoolRateLimiter) DrainHistory() []RateSample {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	history := rl.history
+	rl.history = make([]RateSample, 0)
+	return history
 }
 
 func (rl *TxPoolRateLimiter) Wait(ctx context.Context) error {
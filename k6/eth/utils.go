@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"math/big"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -48,6 +50,28 @@ func InitEthClient(ctx context.Context, uri string) (*rpc.Client, *ethclient.Cli
 	return rc, ec, chainID, nil
 }
 
+// IsIPCPath reports whether uri is an IPC socket path rather than an URL.
+func IsIPCPath(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "" {
+		return false
+	}
+	return filepath.IsAbs(uri) || filepath.Ext(uri) == ".ipc"
+}
+
+// SupportsSubscriptions reports whether the transport of uri can serve
+// eth_subscribe, which is the case for websocket and IPC endpoints.
+func SupportsSubscriptions(uri string) bool {
+	if IsIPCPath(uri) {
+		return true
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "ws" || u.Scheme == "wss"
+}
+
 func SanitizeURL(uri string) string {
 	u, err := url.Parse(uri)
 	if err != nil {
@@ -104,22 +128,30 @@ func WaitUntilMined(ctx context.Context, ec *ethclient.Client, hash common.Hash,
 	return receipt, nil
 }
 
-func DoIncreaseNonceWhenError(err error) bool {
-	if strings.Contains(err.Error(), "replacement transaction underpriced") {
-		return true
-	}
-	if strings.Contains(err.Error(), "transaction underpriced") {
-		return true
-	}
-	if strings.Contains(err.Error(), "nonce too low") {
-		return true
-	}
-	if strings.Contains(err.Error(), "already known") {
-		return true
-	}
-	if strings.Contains(err.Error(), "could not replace existing") {
-		return true
+// WaitForReceipt waits for the receipt of hash, whatever its status. When the
+// wait times out after a failed request for the receipt, e.g. the node was
+// unreachable, the error of the request is returned rather than the timeout.
+func WaitForReceipt(ctx context.Context, ec *ethclient.Client, hash common.Hash, timeout time.Duration, step time.Duration) (*types.Receipt, error) {
+	var (
+		receipt *types.Receipt
+		lastErr error // of the last request, nil when the receipt was not found
+	)
+	if err := RepeatWithTimeout(ctx, timeout, step, func(ctx context.Context) error {
+		r, err := ec.TransactionReceipt(ctx, hash)
+		if err != nil {
+			lastErr = err
+			if errors.Is(err, ethereum.NotFound) {
+				lastErr = nil
+			}
+			return err
+		}
+		receipt = r
+		return nil
+	}); err != nil {
+		if lastErr != nil && errors.Is(err, context.DeadlineExceeded) && !errors.Is(lastErr, context.DeadlineExceeded) {
+			err = lastErr
+		}
+		return nil, fmt.Errorf("%w for hash %s", err, hash.Hex())
 	}
-
-	return false
+	return receipt, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"fmt"
+	"math/big"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -73,6 +74,7 @@ func (w *Wallet) RefreshNonce(ec *ethclient.Client) error {
 type WalletRegistry struct {
 	wallets map[common.Address]*Wallet
 	locks   map[common.Address]bool
+	paused  map[common.Address]bool
 	ln      int
 	mu      sync.RWMutex
 }
@@ -81,6 +83,7 @@ func NewEmptyWalletRegistry() *WalletRegistry {
 	return &WalletRegistry{
 		wallets: make(map[common.Address]*Wallet),
 		locks:   make(map[common.Address]bool),
+		paused:  make(map[common.Address]bool),
 	}
 }
 
@@ -89,12 +92,40 @@ func NewWalletRegistryFromPrivateKeys(ctx context.Context, ec *ethclient.Client,
 		return nil, fmt.Errorf("private keys is empty")
 	}
 
+	wallets := make([]*Wallet, 0, len(privateKeys))
+	for _, pk := range privateKeys {
+		wallet, err := ParsePrivateKey(pk)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, wallet)
+	}
+	return NewWalletRegistryFromWallets(ctx, ec, wallets)
+}
+
+// NewWalletRegistryFromMnemonic registers count wallets derived from the
+// mnemonic at the children start to start+count-1 of basePath.
+func NewWalletRegistryFromMnemonic(ctx context.Context, ec *ethclient.Client, mnemonic, passphrase, basePath string, start, count uint32) (*WalletRegistry, error) {
+	wallets, err := DeriveWallets(mnemonic, passphrase, basePath, start, count)
+	if err != nil {
+		return nil, err
+	}
+	return NewWalletRegistryFromWallets(ctx, ec, wallets)
+}
+
+// NewWalletRegistryFromWallets registers existing wallets with their pending
+// nonce.
+func NewWalletRegistryFromWallets(ctx context.Context, ec *ethclient.Client, wallets []*Wallet) (*WalletRegistry, error) {
+	if len(wallets) == 0 {
+		return nil, fmt.Errorf("wallets is empty")
+	}
+
 	sem := semaphore.NewWeighted(MaxNumberOfCreatingWalletsAtOnce)
-	errCh := make(chan error, len(privateKeys))
+	errCh := make(chan error, len(wallets))
 
 	wr := NewEmptyWalletRegistry()
 
-	for _, pk := range privateKeys {
+	for _, wallet := range wallets {
 		if err := sem.Acquire(ctx, 1); err != nil {
 			return nil, err
 		}
@@ -110,11 +141,6 @@ func NewWalletRegistryFromPrivateKeys(ctx context.Context, ec *ethclient.Client,
 		go func() {
 			defer sem.Release(1)
 
-			wallet, err := ParsePrivateKey(pk)
-			if err != nil {
-				errCh <- err
-				return
-			}
 			if err := wallet.RefreshNonce(ec); err != nil {
 				errCh <- fmt.Errorf("failed to refresh nonce for wallet %s: %w", wallet.Address, err)
 				return
@@ -137,6 +163,47 @@ func NewWalletRegistryFromPrivateKeys(ctx context.Context, ec *ethclient.Client,
 	return wr, nil
 }
 
+// BelowBalance returns a registry of the wallets with a balance below
+// threshold.
+func (wr *WalletRegistry) BelowBalance(ctx context.Context, ec *ethclient.Client, threshold *big.Int) (*WalletRegistry, error) {
+	wallets := wr.All()
+	sem := semaphore.NewWeighted(MaxNumberOfCreatingWalletsAtOnce)
+	errCh := make(chan error, len(wallets))
+
+	low := NewEmptyWalletRegistry()
+	for _, wallet := range wallets {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+
+		go func() {
+			defer sem.Release(1)
+
+			balance, err := ec.BalanceAt(ctx, wallet.Address, nil)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to get balance of wallet %s: %w", wallet.Address, err)
+				return
+			}
+			if balance.Cmp(threshold) < 0 {
+				low.Register(wallet)
+			}
+		}()
+	}
+
+	if err := sem.Acquire(ctx, MaxNumberOfCreatingWalletsAtOnce); err != nil {
+		return nil, err
+	}
+
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return low, nil
+}
+
 func (wr *WalletRegistry) GenerateAndStore(ctx context.Context, numWallets uint64) error {
 	sem := semaphore.NewWeighted(MaxNumberOfCreatingWalletsAtOnce)
 	errCh := make(chan error, int(numWallets))
@@ -195,12 +262,30 @@ func (wr *WalletRegistry) Register(wallet *Wallet) {
 	wr.locks[wallet.Address] = false
 }
 
+// Merge registers the wallets of other that are not registered yet.
+func (wr *WalletRegistry) Merge(other *WalletRegistry) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	for addr, wallet := range other.wallets {
+		if _, exists := wr.wallets[addr]; exists {
+			continue
+		}
+		wr.ln++
+		wr.wallets[addr] = wallet
+		wr.locks[addr] = false
+	}
+}
+
 func (wr *WalletRegistry) Unregister(addr common.Address) {
 	wr.mu.Lock()
 	defer wr.mu.Unlock()
 	wr.ln--
 	delete(wr.wallets, addr)
 	delete(wr.locks, addr)
+	delete(wr.paused, addr)
 }
 
 func (wr *WalletRegistry) Lock(addr common.Address) bool {
@@ -233,7 +318,7 @@ func (wr *WalletRegistry) GetAvailableWallet() *Wallet {
 	defer wr.mu.Unlock()
 
 	for addr, wallet := range wr.wallets {
-		if !wr.locks[addr] {
+		if !wr.locks[addr] && !wr.paused[addr] {
 			wr.locks[addr] = true
 			return wallet
 		}
@@ -241,6 +326,27 @@ func (wr *WalletRegistry) GetAvailableWallet() *Wallet {
 	return nil
 }
 
+// Pause takes the wallet out of GetAvailableWallet rotation until resumed.
+func (wr *WalletRegistry) Pause(addr common.Address) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	if _, exists := wr.wallets[addr]; exists {
+		wr.paused[addr] = true
+	}
+}
+
+func (wr *WalletRegistry) Resume(addr common.Address) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	delete(wr.paused, addr)
+}
+
+func (wr *WalletRegistry) IsPaused(addr common.Address) bool {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+	return wr.paused[addr]
+}
+
 func (wr *WalletRegistry) IsLocked(addr common.Address) bool {
 	wr.mu.RLock()
 	defer wr.mu.RUnlock()
@@ -0,0 +1,214 @@
+package eth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const walletKeyIterations = 600_000
+
+// walletKeyPrefixes are the keys of stored wallets, carried over to the new
+// database when the previous one is rotated.
+var walletKeyPrefixes = []string{"wallet", "walletsalt", "nonce"}
+
+type storedWallet struct {
+	Key       hexutil.Bytes `json:"key"`                 // private key, gcm nonce and sealed key when encrypted
+	Encrypted bool          `json:"encrypted,omitempty"` // whether the key is encrypted with the passphrase
+}
+
+// WalletStore persists generated wallets and their last known nonce in the
+// database, keys are encrypted with AES-GCM when a passphrase is set.
+type WalletStore struct {
+	db         *PebbleDb
+	uid        string
+	passphrase string
+	aead       cipher.AEAD
+}
+
+func NewWalletStore(db *PebbleDb, uid, passphrase string) *WalletStore {
+	return &WalletStore{db: db, uid: uid, passphrase: passphrase}
+}
+
+// cipher returns the AEAD keyed by the passphrase, the salt is generated on
+// first use and stored with the wallets.
+func (ws *WalletStore) cipher() (cipher.AEAD, error) {
+	if ws.aead != nil {
+		return ws.aead, nil
+	}
+
+	saltKey := ws.db.GenKey("walletsalt", ws.uid)
+	salt, closer, err := ws.db.Db().Get(saltKey)
+	switch err {
+	case nil:
+		salt = append([]byte(nil), salt...)
+		closer.Close() // nolint:errcheck
+	case pebble.ErrNotFound:
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		if err := ws.db.Db().Set(saltKey, salt, pebble.Sync); err != nil {
+			return nil, fmt.Errorf("failed to store salt: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to get salt: %w", err)
+	}
+
+	key, err := pbkdf2.Key(sha256.New, ws.passphrase, salt, walletKeyIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	ws.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return ws.aead, nil
+}
+
+// Save stores the wallets and their nonces.
+func (ws *WalletStore) Save(wallets []*Wallet) error {
+	batch := ws.db.Db().NewBatch()
+	defer batch.Close() // nolint:errcheck
+
+	for _, w := range wallets {
+		stored := storedWallet{Key: crypto.FromECDSA(w.PrivateKey)}
+		if ws.passphrase != "" {
+			aead, err := ws.cipher()
+			if err != nil {
+				return err
+			}
+			nonce := make([]byte, aead.NonceSize())
+			if _, err := rand.Read(nonce); err != nil {
+				return fmt.Errorf("failed to generate nonce: %w", err)
+			}
+			stored = storedWallet{Key: aead.Seal(nonce, nonce, stored.Key, w.Address.Bytes()), Encrypted: true}
+		}
+
+		val, err := json.Marshal(stored)
+		if err != nil {
+			return fmt.Errorf("failed to marshal wallet %s: %w", w.Address, err)
+		}
+		if err := batch.Set(ws.db.GenKey("wallet", ws.uid, w.Address.Hex()), val, nil); err != nil {
+			return err
+		}
+		if err := batch.Set(ws.db.GenKey("nonce", ws.uid, w.Address.Hex()), binary.LittleEndian.AppendUint64(nil, w.Nonce), nil); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// SaveNonces stores the last known nonces of the wallets, wallets that are
+// not stored are skipped.
+func (ws *WalletStore) SaveNonces(wallets map[common.Address]*Wallet) error {
+	batch := ws.db.Db().NewBatch()
+	defer batch.Close() // nolint:errcheck
+
+	for addr, w := range wallets {
+		_, closer, err := ws.db.Db().Get(ws.db.GenKey("wallet", ws.uid, addr.Hex()))
+		if err == pebble.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		closer.Close() // nolint:errcheck
+
+		w.mu.RLock()
+		nonce := w.Nonce
+		w.mu.RUnlock()
+		if err := batch.Set(ws.db.GenKey("nonce", ws.uid, addr.Hex()), binary.LittleEndian.AppendUint64(nil, nonce), nil); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// Load returns the stored wallets with their last known nonce.
+func (ws *WalletStore) Load() ([]*Wallet, error) {
+	prefix := ws.db.GenKey("wallet", ws.uid, "")
+	iter, err := ws.db.Db().NewIter(&pebble.IterOptions{LowerBound: prefix, UpperBound: prefixUpperBound(prefix)})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close() // nolint:errcheck
+
+	wallets := make([]*Wallet, 0)
+	for iter.First(); iter.Valid(); iter.Next() {
+		addr := common.HexToAddress(string(iter.Key()[len(prefix):]))
+
+		var stored storedWallet
+		if err := json.Unmarshal(iter.Value(), &stored); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal wallet %s: %w", addr, err)
+		}
+		key := []byte(stored.Key)
+		if stored.Encrypted {
+			if ws.passphrase == "" {
+				return nil, fmt.Errorf("wallet %s is encrypted and no passphrase is set", addr)
+			}
+			aead, err := ws.cipher()
+			if err != nil {
+				return nil, err
+			}
+			if len(key) < aead.NonceSize() {
+				return nil, fmt.Errorf("invalid encrypted wallet %s", addr)
+			}
+			key, err = aead.Open(nil, key[:aead.NonceSize()], key[aead.NonceSize():], addr.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt wallet %s, wrong passphrase: %w", addr, err)
+			}
+		}
+
+		pk, err := crypto.ToECDSA(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse wallet %s: %w", addr, err)
+		}
+		w := &Wallet{Address: crypto.PubkeyToAddress(pk.PublicKey), PrivateKey: pk}
+		if w.Address != addr {
+			return nil, fmt.Errorf("stored key does not match wallet %s", addr)
+		}
+		if nonce, ok := ws.nonce(addr); ok {
+			w.Nonce = nonce
+			w.OffsetNonce = nonce
+		}
+		wallets = append(wallets, w)
+	}
+	return wallets, iter.Error()
+}
+
+func (ws *WalletStore) nonce(addr common.Address) (uint64, bool) {
+	val, closer, err := ws.db.Db().Get(ws.db.GenKey("nonce", ws.uid, addr.Hex()))
+	if err != nil {
+		return 0, false
+	}
+	defer closer.Close() // nolint:errcheck
+	return binary.LittleEndian.Uint64(val), true
+}
+
+// prefixUpperBound returns the smallest key greater than every key starting
+// with prefix.
+func prefixUpperBound(prefix []byte) []byte {
+	upper := append([]byte(nil), prefix...)
+	for i := len(upper) - 1; i >= 0; i-- {
+		upper[i]++
+		if upper[i] != 0 {
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
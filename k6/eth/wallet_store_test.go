@@ -0,0 +1,112 @@
+package eth
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletStore(t *testing.T) {
+	newDb := func(t *testing.T) *PebbleDb {
+		db, err := NewPebbleDb(filepath.Join(t.TempDir(), "test.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() }) // nolint:errcheck
+		return db
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		db := newDb(t)
+		wallet := createTestWallet(t)
+		wallet.Nonce = 7
+
+		ws := NewWalletStore(db, "client", "")
+		require.NoError(t, ws.Save([]*Wallet{wallet}))
+
+		wallets, err := ws.Load()
+		require.NoError(t, err)
+		require.Len(t, wallets, 1)
+		assert.Equal(t, wallet.Address, wallets[0].Address)
+		assert.Equal(t, wallet.PrivateKey.D, wallets[0].PrivateKey.D)
+		assert.Equal(t, uint64(7), wallets[0].Nonce)
+
+		// wallets of other clients are not loaded
+		wallets, err = NewWalletStore(db, "other", "").Load()
+		require.NoError(t, err)
+		assert.Empty(t, wallets)
+	})
+
+	t.Run("encrypted", func(t *testing.T) {
+		db := newDb(t)
+		wallet := createTestWallet(t)
+
+		require.NoError(t, NewWalletStore(db, "client", "secret").Save([]*Wallet{wallet}))
+
+		wallets, err := NewWalletStore(db, "client", "secret").Load()
+		require.NoError(t, err)
+		require.Len(t, wallets, 1)
+		assert.Equal(t, wallet.PrivateKey.D, wallets[0].PrivateKey.D)
+
+		_, err = NewWalletStore(db, "client", "wrong").Load()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "wrong passphrase")
+
+		_, err = NewWalletStore(db, "client", "").Load()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "no passphrase is set")
+	})
+
+	t.Run("nonces", func(t *testing.T) {
+		db := newDb(t)
+		wallet := createTestWallet(t)
+		ws := NewWalletStore(db, "client", "")
+		require.NoError(t, ws.Save([]*Wallet{wallet}))
+
+		wallet.Nonce = 42
+		other := createTestWallet(t)
+		require.NoError(t, ws.SaveNonces(map[common.Address]*Wallet{wallet.Address: wallet, other.Address: other}))
+		_, _, err := db.Db().Get(db.GenKey("nonce", "client", other.Address.Hex()))
+		assert.Error(t, err)
+
+		wallets, err := ws.Load()
+		require.NoError(t, err)
+		require.Len(t, wallets, 1)
+		assert.Equal(t, uint64(42), wallets[0].Nonce)
+	})
+
+	t.Run("carried over on rotation", func(t *testing.T) {
+		pth := filepath.Join(t.TempDir(), "test.db")
+		db, err := NewPebbleDb(pth)
+		require.NoError(t, err)
+		wallet := createTestWallet(t)
+		require.NoError(t, NewWalletStore(db, "client", "").Save([]*Wallet{wallet}))
+		require.NoError(t, db.Db().Set(db.GenKey("tx", "0x01"), []byte{1}, nil))
+		require.NoError(t, db.Close())
+
+		db, err = NewPebbleDb(pth)
+		require.NoError(t, err)
+		defer db.Close() // nolint:errcheck
+
+		wallets, err := NewWalletStore(db, "client", "").Load()
+		require.NoError(t, err)
+		require.Len(t, wallets, 1)
+		assert.Equal(t, wallet.Address, wallets[0].Address)
+
+		_, _, err = db.Db().Get(db.GenKey("tx", "0x01"))
+		assert.Error(t, err)
+	})
+
+	t.Run("closed by the last user", func(t *testing.T) {
+		db, err := NewPebbleDb(filepath.Join(t.TempDir(), "test.db"))
+		require.NoError(t, err)
+		db.Retain()
+		db.Retain()
+
+		require.NoError(t, db.Close())
+		require.NoError(t, NewWalletStore(db, "client", "").Save([]*Wallet{createTestWallet(t)}))
+		require.NoError(t, db.Close())
+		require.NoError(t, db.Close())
+	})
+}
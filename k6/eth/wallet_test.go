@@ -53,6 +53,20 @@ func TestWalletRegistry_Unregister(t *testing.T) {
 	assert.Empty(t, registry.locks)
 }
 
+func TestWalletRegistry_Merge(t *testing.T) {
+	registry := NewEmptyWalletRegistry()
+	shared := createTestWallet(t)
+	registry.Register(shared)
+
+	other := NewEmptyWalletRegistry()
+	other.Register(shared)
+	other.Register(createTestWallet(t))
+
+	registry.Merge(other)
+	assert.Len(t, registry.wallets, 2)
+	assert.Equal(t, 2, registry.Ln())
+}
+
 func TestWalletRegistry_Lock(t *testing.T) {
 	registry := NewEmptyWalletRegistry()
 	wallet := createTestWallet(t)
@@ -118,6 +132,33 @@ func TestWalletRegistry_GetAvailableWallet(t *testing.T) {
 	assert.Nil(t, available3)
 }
 
+func TestWalletRegistry_Pause(t *testing.T) {
+	registry := NewEmptyWalletRegistry()
+	wallet1 := createTestWallet(t)
+	wallet2 := createTestWallet(t)
+
+	registry.Register(wallet1)
+	registry.Register(wallet2)
+
+	registry.Pause(wallet1.Address)
+	assert.True(t, registry.IsPaused(wallet1.Address))
+
+	// Paused wallets are skipped
+	available := registry.GetAvailableWallet()
+	assert.Equal(t, wallet2.Address, available.Address)
+	assert.Nil(t, registry.GetAvailableWallet())
+
+	registry.Resume(wallet1.Address)
+	assert.False(t, registry.IsPaused(wallet1.Address))
+	available = registry.GetAvailableWallet()
+	assert.Equal(t, wallet1.Address, available.Address)
+
+	// Unknown wallets are not paused
+	other := createTestWallet(t)
+	registry.Pause(other.Address)
+	assert.False(t, registry.IsPaused(other.Address))
+}
+
 func TestWalletRegistry_IsLocked(t *testing.T) {
 	registry := NewEmptyWalletRegistry()
 	wallet := createTestWallet(t)
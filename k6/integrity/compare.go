@@ -0,0 +1,318 @@
+package integrity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	blockPlaceholder   = "$block"
+	defaultEstimates   = 3 // gas estimates per block of the generated calls
+	compareCallTimeout = conformanceCallTimeout
+	maxComparedBlocks  = 256
+)
+
+// NamedCaller is the node of a client.
+type NamedCaller struct {
+	UID string
+	RC  Caller
+}
+
+// CompareOptions are the calls compared across the clients. Either a single
+// method, whose string params equal to "$block" are replaced by the common
+// height, or the calls generated over the recent blocks.
+type CompareOptions struct {
+	Method    string
+	Params    []interface{}
+	Blocks    uint64   // generate calls over the blocks up to the common height
+	Block     *uint64  // common height, the lowest head of the clients by default
+	Estimates int      // gas estimates per block of the generated calls
+	Ignore    []string // fields left out of the comparison, e.g. "timestamp"
+}
+
+// FieldDiff is a value of a client differing from the reference client.
+type FieldDiff struct {
+	Path      string      `json:"path"`
+	Reference interface{} `json:"reference"` // nil when missing
+	Value     interface{} `json:"value"`     // nil when missing
+}
+
+// CallComparison is the comparison of one call on every client.
+type CallComparison struct {
+	Method    string                  `json:"method"`
+	Params    []interface{}           `json:"params"`
+	Reference string                  `json:"reference"`        // uid of the client compared to
+	Diffs     map[string][]*FieldDiff `json:"diffs,omitempty"`  // by client uid, clients agreeing with the reference are left out
+	Errors    map[string]string       `json:"errors,omitempty"` // by client uid, when only some clients failed
+}
+
+// Mismatch reports whether a client disagrees with the reference.
+func (cc *CallComparison) Mismatch(uid string) bool {
+	_, diff := cc.Diffs[uid]
+	_, failed := cc.Errors[uid]
+	return diff || failed
+}
+
+// Comparison is the result of the calls compared across the clients.
+type Comparison struct {
+	Block      uint64            `json:"block"`      // common height
+	Calls      int               `json:"calls"`      // number of calls compared
+	Mismatches int               `json:"mismatches"` // calls on which a client disagrees with the reference
+	Results    []*CallComparison `json:"results"`    // mismatched calls only
+	compared   []*CallComparison // every call, for the metrics
+}
+
+type rpcCall struct {
+	method string
+	params []interface{}
+}
+
+// Compare runs the calls on every client at the same height and diffs the
+// results of the clients with the first one, field by field.
+func Compare(ctx context.Context, callers []NamedCaller, opts *CompareOptions) (*Comparison, error) {
+	if len(callers) < 2 {
+		return nil, fmt.Errorf("at least 2 clients are required, got %d", len(callers))
+	}
+	if opts.Method == "" && opts.Blocks == 0 {
+		return nil, fmt.Errorf("either method or blocks is required")
+	}
+
+	height, err := commonHeight(ctx, callers, opts.Block)
+	if err != nil {
+		return nil, err
+	}
+
+	calls := make([]rpcCall, 0)
+	if opts.Method != "" {
+		calls = append(calls, rpcCall{method: opts.Method, params: withBlock(opts.Params, height)})
+	}
+	if opts.Blocks > 0 {
+		generated, err := blockCalls(ctx, callers[0].RC, height, min(opts.Blocks, maxComparedBlocks), opts.Estimates)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, generated...)
+	}
+
+	cmp := &Comparison{Block: height, Results: make([]*CallComparison, 0), compared: make([]*CallComparison, 0, len(calls))}
+	for _, call := range calls {
+		cc := compareCall(ctx, callers, call, opts.Ignore)
+		cmp.Calls++
+		cmp.compared = append(cmp.compared, cc)
+		if len(cc.Diffs) > 0 || len(cc.Errors) > 0 {
+			cmp.Mismatches++
+			cmp.Results = append(cmp.Results, cc)
+		}
+	}
+	return cmp, nil
+}
+
+// commonHeight returns block when set, the lowest head of the clients
+// otherwise.
+func commonHeight(ctx context.Context, callers []NamedCaller, block *uint64) (uint64, error) {
+	if block != nil {
+		return *block, nil
+	}
+	heads := make([]uint64, len(callers))
+	for i, c := range callers {
+		var head hexutil.Uint64
+		if err := callWithTimeout(ctx, c.RC, &head, "eth_blockNumber"); err != nil {
+			return 0, fmt.Errorf("failed to get the head of %s: %w", c.UID, err)
+		}
+		heads[i] = uint64(head)
+	}
+	return slices.Min(heads), nil
+}
+
+func callWithTimeout(ctx context.Context, rc Caller, result interface{}, method string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, compareCallTimeout)
+	defer cancel()
+	return rc.CallContext(ctx, result, method, args...)
+}
+
+// withBlock replaces the "$block" params by the height.
+func withBlock(params []interface{}, height uint64) []interface{} {
+	out := make([]interface{}, len(params))
+	for i, p := range params {
+		if s, ok := p.(string); ok && s == blockPlaceholder {
+			out[i] = hexutil.Uint64(height).String()
+			continue
+		}
+		out[i] = p
+	}
+	return out
+}
+
+// blockCalls generates the calls over the n blocks up to height: the block
+// with its transactions, its receipts and logs, the balance of the miner, and
+// gas estimates of the first transactions against the parent state.
+func blockCalls(ctx context.Context, rc Caller, height, n uint64, estimates int) ([]rpcCall, error) {
+	if estimates <= 0 {
+		estimates = defaultEstimates
+	}
+	calls := make([]rpcCall, 0)
+	for number := height; number+n > height && number > 0; number-- {
+		hex := hexutil.Uint64(number).String()
+		var block struct {
+			Miner        string `json:"miner"`
+			Transactions []struct {
+				From  string  `json:"from"`
+				To    *string `json:"to"`
+				Value string  `json:"value"`
+				Input string  `json:"input"`
+			} `json:"transactions"`
+		}
+		if err := callWithTimeout(ctx, rc, &block, "eth_getBlockByNumber", hex, true); err != nil {
+			return nil, fmt.Errorf("failed to get block %d: %w", number, err)
+		}
+
+		calls = append(calls,
+			rpcCall{method: "eth_getBlockByNumber", params: []interface{}{hex, true}},
+			rpcCall{method: "eth_getBlockReceipts", params: []interface{}{hex}},
+			rpcCall{method: "eth_getLogs", params: []interface{}{map[string]interface{}{"fromBlock": hex, "toBlock": hex}}},
+			rpcCall{method: "eth_getBalance", params: []interface{}{block.Miner, hex}},
+		)
+		parent := hexutil.Uint64(number - 1).String()
+		for i, tx := range block.Transactions {
+			if i == estimates {
+				break
+			}
+			msg := map[string]interface{}{"from": tx.From, "value": tx.Value, "input": tx.Input}
+			if tx.To != nil {
+				msg["to"] = *tx.To
+			}
+			calls = append(calls, rpcCall{method: "eth_estimateGas", params: []interface{}{msg, parent}})
+		}
+	}
+	return calls, nil
+}
+
+// compareCall runs the call on every client concurrently and diffs the
+// results with the first client.
+func compareCall(ctx context.Context, callers []NamedCaller, call rpcCall, ignore []string) *CallComparison {
+	results := make([]json.RawMessage, len(callers))
+	errs := make([]error, len(callers))
+	wg := sync.WaitGroup{}
+	wg.Add(len(callers))
+	for i, c := range callers {
+		go func() {
+			defer wg.Done()
+			errs[i] = callWithTimeout(ctx, c.RC, &results[i], call.method, call.params...)
+		}()
+	}
+	wg.Wait()
+
+	cc := &CallComparison{
+		Method:    call.method,
+		Params:    call.params,
+		Reference: callers[0].UID,
+		Diffs:     make(map[string][]*FieldDiff),
+		Errors:    make(map[string]string),
+	}
+	reference, refErr := decodeJSON(results[0])
+	if errs[0] == nil && refErr != nil {
+		errs[0] = refErr
+	}
+	for i, c := range callers[1:] {
+		err := errs[i+1]
+		var value interface{}
+		if err == nil {
+			value, err = decodeJSON(results[i+1])
+		}
+		switch {
+		case errs[0] != nil && err != nil:
+			// failing on every client is not a disagreement
+		case errs[0] != nil:
+			// the client has a result where the reference failed
+			cc.Errors[callers[0].UID] = errs[0].Error()
+			cc.Diffs[c.UID] = []*FieldDiff{{Value: value}}
+		case err != nil:
+			cc.Errors[c.UID] = err.Error()
+		default:
+			if diffs := diffJSON("", reference, value, ignore); len(diffs) > 0 {
+				cc.Diffs[c.UID] = diffs
+			}
+		}
+	}
+	return cc
+}
+
+func decodeJSON(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+	return v, nil
+}
+
+// diffJSON returns the values of b differing from a, by path. Hex strings
+// are compared case insensitively, e.g. checksummed addresses.
+func diffJSON(path string, a, b interface{}, ignore []string) []*FieldDiff {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			return []*FieldDiff{{Path: path, Reference: a, Value: b}}
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+
+		diffs := make([]*FieldDiff, 0)
+		for _, k := range keys {
+			if slices.Contains(ignore, k) {
+				continue
+			}
+			diffs = append(diffs, diffJSON(joinPath(path, k), av[k], bv[k], ignore)...)
+		}
+		return diffs
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			return []*FieldDiff{{Path: path, Reference: a, Value: b}}
+		}
+		diffs := make([]*FieldDiff, 0)
+		if len(av) != len(bv) {
+			diffs = append(diffs, &FieldDiff{Path: joinPath(path, "length"), Reference: len(av), Value: len(bv)})
+		}
+		for i := range min(len(av), len(bv)) {
+			diffs = append(diffs, diffJSON(fmt.Sprintf("%s[%d]", path, i), av[i], bv[i], ignore)...)
+		}
+		return diffs
+	case string:
+		if bv, ok := b.(string); ok && (av == bv || (strings.HasPrefix(av, "0x") && strings.EqualFold(av, bv))) {
+			return nil
+		}
+	default:
+		if a == b {
+			return nil
+		}
+	}
+	return []*FieldDiff{{Path: path, Reference: a, Value: b}}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
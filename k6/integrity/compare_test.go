@@ -0,0 +1,100 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffJSON(t *testing.T) {
+	decode := func(s string) interface{} {
+		v, err := decodeJSON(json.RawMessage(s))
+		require.NoError(t, err)
+		return v
+	}
+
+	tests := []struct {
+		name  string
+		a, b  string
+		paths []string
+	}{
+		{"equal", `{"a":"0x1","b":[1,2]}`, `{"b":[1,2],"a":"0x1"}`, nil},
+		{"hex case", `{"to":"0xAbCd"}`, `{"to":"0xabcd"}`, nil},
+		{"value", `{"gasUsed":"0x5208"}`, `{"gasUsed":"0x5209"}`, []string{"gasUsed"}},
+		{"missing field", `{"a":1,"b":2}`, `{"a":1}`, []string{"b"}},
+		{"nested", `{"logs":[{"data":"0x"}]}`, `{"logs":[{"data":"0x01"}]}`, []string{"logs[0].data"}},
+		{"length", `[1,2]`, `[1]`, []string{"length"}},
+		{"type", `{"to":null}`, `{"to":"0x1"}`, []string{"to"}},
+		{"ignored", `{"a":1,"timestamp":"0x1"}`, `{"a":1,"timestamp":"0x2"}`, nil},
+	}
+	for _, tt := range tests {
+		diffs := diffJSON("", decode(tt.a), decode(tt.b), []string{"timestamp"})
+		paths := make([]string, 0)
+		for _, d := range diffs {
+			paths = append(paths, d.Path)
+		}
+		assert.ElementsMatch(t, tt.paths, paths, tt.name)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	reference, other := newFakeNode(), newFakeNode()
+	other["eth_blockNumber"] = value("0x11")
+	other["eth_getBlockReceipts"] = func([]interface{}) (interface{}, error) {
+		receipt := testReceipt()
+		receipt["gasUsed"] = "0x5209"
+		return []interface{}{receipt}, nil
+	}
+	other["eth_estimateGas"] = func([]interface{}) (interface{}, error) {
+		return nil, &jsonError{code: -32000, msg: "gas required exceeds allowance"}
+	}
+	callers := []NamedCaller{{UID: "geth", RC: reference}, {UID: "reth", RC: other}}
+
+	t.Run("recent blocks", func(t *testing.T) {
+		cmp, err := Compare(context.Background(), callers, &CompareOptions{Blocks: 1})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0x10), cmp.Block)
+		// block, receipts, logs, balance and one gas estimate
+		assert.Equal(t, 5, cmp.Calls)
+		assert.Equal(t, 2, cmp.Mismatches)
+
+		receipts := cmp.Results[0]
+		assert.Equal(t, "eth_getBlockReceipts", receipts.Method)
+		assert.Equal(t, "geth", receipts.Reference)
+		require.Len(t, receipts.Diffs["reth"], 1)
+		assert.Equal(t, &FieldDiff{Path: "[0].gasUsed", Reference: "0x5208", Value: "0x5209"}, receipts.Diffs["reth"][0])
+		assert.True(t, receipts.Mismatch("reth"))
+
+		estimate := cmp.Results[1]
+		assert.Equal(t, "eth_estimateGas", estimate.Method)
+		assert.Equal(t, []interface{}{map[string]interface{}{"from": testAddress, "value": "0x0", "input": "0x"}, "0xf"}, estimate.Params)
+		assert.Equal(t, "gas required exceeds allowance", estimate.Errors["reth"])
+	})
+
+	t.Run("single method at the common height", func(t *testing.T) {
+		cmp, err := Compare(context.Background(), callers, &CompareOptions{Method: "eth_getBlockByNumber", Params: []interface{}{"$block", false}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, cmp.Calls)
+		assert.Zero(t, cmp.Mismatches)
+		assert.Equal(t, []interface{}{"0x10", false}, cmp.compared[0].Params)
+	})
+
+	t.Run("reference failed", func(t *testing.T) {
+		swapped := []NamedCaller{callers[1], callers[0]}
+		cmp, err := Compare(context.Background(), swapped, &CompareOptions{Method: "eth_estimateGas", Params: []interface{}{map[string]interface{}{}}})
+		require.NoError(t, err)
+		require.Len(t, cmp.Results, 1)
+		assert.True(t, cmp.Results[0].Mismatch("geth"))
+		assert.Equal(t, "0x5208", cmp.Results[0].Diffs["geth"][0].Value)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := Compare(context.Background(), callers[:1], &CompareOptions{Blocks: 1})
+		assert.Error(t, err)
+		_, err = Compare(context.Background(), callers, &CompareOptions{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,422 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	conformanceCallTimeout = 10 * time.Second
+	maxTxSearchDepth       = 64 // blocks searched back from the head for a transaction
+
+	rpcErrCodeMethodNotFound = -32601
+	rpcErrCodeInvalidParams  = -32602
+)
+
+// Caller calls JSON-RPC methods, *rpc.Client implements it.
+type Caller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+type CheckKind string
+
+const (
+	CheckPresence CheckKind = "presence"  // the method is served
+	CheckResult   CheckKind = "result"    // the result is encoded as specified
+	CheckParams   CheckKind = "params"    // valid parameters are accepted
+	CheckErrors   CheckKind = "errors"    // invalid input fails with the specified error code
+	CheckNotFound CheckKind = "not_found" // unknown blocks and transactions are null
+	CheckShape    CheckKind = "shape"     // objects have the specified fields
+)
+
+// ConformanceCheck is the outcome of one check of a method.
+type ConformanceCheck struct {
+	Method  string    `json:"method"`
+	Check   CheckKind `json:"check"`
+	Case    string    `json:"case,omitempty"` // input of the check, when a method is checked several times
+	Passed  bool      `json:"passed"`
+	Skipped bool      `json:"skipped,omitempty"` // no input found, e.g. no transaction on chain
+	Error   string    `json:"error,omitempty"`
+}
+
+type ConformanceReport struct {
+	Passed  int                 `json:"passed"`
+	Failed  int                 `json:"failed"`
+	Skipped int                 `json:"skipped"`
+	Checks  []*ConformanceCheck `json:"checks"`
+}
+
+// Failures returns the checks that failed.
+func (r *ConformanceReport) Failures() []*ConformanceCheck {
+	failures := make([]*ConformanceCheck, 0, r.Failed)
+	for _, c := range r.Checks {
+		if !c.Passed && !c.Skipped {
+			failures = append(failures, c)
+		}
+	}
+	return failures
+}
+
+// conformance checks a node against the execution-apis spec. Inputs are taken
+// from the chain: the head block, the latest transaction within
+// maxTxSearchDepth blocks and its sender.
+type conformance struct {
+	rc     Caller
+	report *ConformanceReport
+
+	head    json.RawMessage // latest block without hydrated transactions
+	number  string
+	hash    string
+	account string
+	tx      string // empty when no transaction was found
+	txBlock string
+}
+
+// CheckConformance runs every check against the node.
+func CheckConformance(ctx context.Context, rc Caller) *ConformanceReport {
+	c := &conformance{rc: rc, report: &ConformanceReport{Checks: make([]*ConformanceCheck, 0)}}
+	if err := c.discover(ctx); err != nil {
+		c.add(&ConformanceCheck{Method: "eth_getBlockByNumber", Check: CheckPresence, Error: err.Error()})
+		return c.report
+	}
+	c.checkMethods(ctx)
+	c.checkParams(ctx)
+	c.checkErrors(ctx)
+	c.checkNotFound(ctx)
+	c.checkShapes(ctx)
+	return c.report
+}
+
+func (c *conformance) add(check *ConformanceCheck) {
+	switch {
+	case check.Skipped:
+		c.report.Skipped++
+	case check.Passed:
+		c.report.Passed++
+	default:
+		c.report.Failed++
+	}
+	c.report.Checks = append(c.report.Checks, check)
+}
+
+func (c *conformance) call(ctx context.Context, method string, args ...interface{}) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, conformanceCallTimeout)
+	defer cancel()
+	var result json.RawMessage
+	if err := c.rc.CallContext(ctx, &result, method, args...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// discover finds the inputs of the checks on chain.
+func (c *conformance) discover(ctx context.Context) error {
+	head, err := c.call(ctx, "eth_getBlockByNumber", "latest", false)
+	if err != nil {
+		return fmt.Errorf("failed to get the latest block: %w", err)
+	}
+	type rpcBlock struct {
+		Number       hexutil.Uint64 `json:"number"`
+		Hash         common.Hash    `json:"hash"`
+		Miner        common.Address `json:"miner"`
+		Transactions []common.Hash  `json:"transactions"`
+	}
+	var latest rpcBlock
+	if err := json.Unmarshal(head, &latest); err != nil {
+		return fmt.Errorf("failed to decode the latest block: %w", err)
+	}
+	c.head, c.number, c.hash, c.account = head, latest.Number.String(), latest.Hash.Hex(), latest.Miner.Hex()
+
+	headNumber := uint64(latest.Number)
+	for depth := uint64(0); depth < maxTxSearchDepth && depth <= headNumber; depth++ {
+		number := hexutil.Uint64(headNumber - depth).String()
+		block := latest
+		if depth > 0 {
+			block = rpcBlock{}
+			raw, err := c.call(ctx, "eth_getBlockByNumber", number, false)
+			if err != nil || json.Unmarshal(raw, &block) != nil {
+				break
+			}
+		}
+		if len(block.Transactions) == 0 {
+			continue
+		}
+		c.tx, c.txBlock = block.Transactions[len(block.Transactions)-1].Hex(), number
+
+		var tx struct {
+			From common.Address `json:"from"`
+		}
+		if raw, err := c.call(ctx, "eth_getTransactionByHash", c.tx); err == nil && json.Unmarshal(raw, &tx) == nil {
+			c.account = tx.From.Hex()
+		}
+		break
+	}
+	return nil
+}
+
+type methodCase struct {
+	method string
+	args   []interface{}
+	result valueKind
+	needTx bool // skipped when no transaction was found
+}
+
+func (c *conformance) methodCases() []methodCase {
+	call := map[string]interface{}{"from": c.account, "to": c.account, "value": "0x0"}
+	return []methodCase{
+		{method: "web3_clientVersion", result: kindString},
+		{method: "net_version", result: kindDecimal},
+		{method: "eth_chainId", result: kindQuantity},
+		{method: "eth_syncing", result: kindAny},
+		{method: "eth_blockNumber", result: kindQuantity},
+		{method: "eth_gasPrice", result: kindQuantity},
+		{method: "eth_maxPriorityFeePerGas", result: kindQuantity},
+		{method: "eth_feeHistory", args: []interface{}{"0x1", "latest", []int{50}}, result: kindObject},
+		{method: "eth_getBalance", args: []interface{}{c.account, "latest"}, result: kindQuantity},
+		{method: "eth_getTransactionCount", args: []interface{}{c.account, "latest"}, result: kindQuantity},
+		{method: "eth_getCode", args: []interface{}{c.account, "latest"}, result: kindData},
+		{method: "eth_getStorageAt", args: []interface{}{c.account, "0x0", "latest"}, result: kindHash},
+		{method: "eth_call", args: []interface{}{call, "latest"}, result: kindData},
+		{method: "eth_estimateGas", args: []interface{}{call}, result: kindQuantity},
+		{method: "eth_createAccessList", args: []interface{}{call, "latest"}, result: kindObject},
+		{method: "eth_getProof", args: []interface{}{c.account, []string{}, "latest"}, result: kindObject},
+		{method: "eth_getBlockByNumber", args: []interface{}{c.number, false}, result: kindObject},
+		{method: "eth_getBlockByHash", args: []interface{}{c.hash, false}, result: kindObject},
+		{method: "eth_getBlockTransactionCountByNumber", args: []interface{}{c.number}, result: kindQuantity},
+		{method: "eth_getBlockTransactionCountByHash", args: []interface{}{c.hash}, result: kindQuantity},
+		{method: "eth_getUncleCountByBlockNumber", args: []interface{}{c.number}, result: kindQuantity},
+		{method: "eth_getBlockReceipts", args: []interface{}{c.number}, result: kindArray},
+		{method: "eth_getLogs", args: []interface{}{map[string]interface{}{"fromBlock": c.number, "toBlock": c.number}}, result: kindArray},
+		{method: "eth_getTransactionByHash", args: []interface{}{c.tx}, result: kindObject, needTx: true},
+		{method: "eth_getTransactionByBlockNumberAndIndex", args: []interface{}{c.txBlock, "0x0"}, result: kindObject, needTx: true},
+		{method: "eth_getTransactionReceipt", args: []interface{}{c.tx}, result: kindObject, needTx: true},
+		// an empty transaction is refused, the method only has to be served
+		{method: "eth_sendRawTransaction", args: []interface{}{"0x"}, result: kindNone},
+	}
+}
+
+// checkMethods checks that the methods are served and their results are
+// encoded as specified.
+func (c *conformance) checkMethods(ctx context.Context) {
+	for _, mc := range c.methodCases() {
+		if mc.needTx && c.tx == "" {
+			c.add(&ConformanceCheck{Method: mc.method, Check: CheckPresence, Skipped: true, Error: "no transaction found"})
+			continue
+		}
+		args := mc.args
+		if args == nil {
+			args = []interface{}{}
+		}
+		result, err := c.call(ctx, mc.method, args...)
+		if rpcErrorCode(err) == rpcErrCodeMethodNotFound {
+			c.add(&ConformanceCheck{Method: mc.method, Check: CheckPresence, Error: err.Error()})
+			continue
+		}
+		c.add(&ConformanceCheck{Method: mc.method, Check: CheckPresence, Passed: true})
+		if mc.result == kindNone {
+			continue
+		}
+
+		check := &ConformanceCheck{Method: mc.method, Check: CheckResult}
+		switch {
+		case err != nil:
+			check.Error = err.Error()
+		case string(result) == "null":
+			check.Error = "result is null"
+		default:
+			if err := checkValue(result, mc.result); err != nil {
+				check.Error = err.Error()
+			}
+		}
+		check.Passed = check.Error == ""
+		c.add(check)
+	}
+}
+
+// checkParams checks that the block tags and the EIP-1898 block parameters
+// are accepted.
+func (c *conformance) checkParams(ctx context.Context) {
+	for _, tag := range []string{"earliest", "latest", "pending", "safe", "finalized"} {
+		_, err := c.call(ctx, "eth_getBlockByNumber", tag, false)
+		c.add(paramsCheck("eth_getBlockByNumber", "tag "+tag, err))
+	}
+
+	blocks := []struct {
+		name  string
+		block map[string]interface{}
+	}{
+		{"block number object", map[string]interface{}{"blockNumber": c.number}},
+		{"block hash object", map[string]interface{}{"blockHash": c.hash}},
+		{"canonical block hash object", map[string]interface{}{"blockHash": c.hash, "requireCanonical": true}},
+	}
+	for _, b := range blocks {
+		_, err := c.call(ctx, "eth_getBalance", c.account, b.block)
+		c.add(paramsCheck("eth_getBalance", b.name, err))
+	}
+
+	check := &ConformanceCheck{Method: "eth_getBlockByNumber", Check: CheckParams, Case: "hydrated transactions"}
+	raw, err := c.call(ctx, "eth_getBlockByNumber", c.number, true)
+	if err != nil {
+		check.Error = err.Error()
+	} else {
+		var block struct {
+			Transactions []json.RawMessage `json:"transactions"`
+		}
+		if err := json.Unmarshal(raw, &block); err != nil {
+			check.Error = err.Error()
+		}
+		for i, tx := range block.Transactions {
+			if err := checkFields(tx, transactionFields); err != nil {
+				check.Error = fmt.Sprintf("transaction %d: %s", i, err)
+				break
+			}
+		}
+	}
+	check.Passed = check.Error == ""
+	c.add(check)
+}
+
+// paramsCheck passes unless the parameters were refused, other errors like a
+// finalized block not known yet are not conformance issues.
+func paramsCheck(method, name string, err error) *ConformanceCheck {
+	check := &ConformanceCheck{Method: method, Check: CheckParams, Case: name, Passed: true}
+	switch rpcErrorCode(err) {
+	case rpcErrCodeInvalidParams, rpcErrCodeMethodNotFound:
+		check.Passed = false
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// checkErrors checks that invalid input fails with the error code of the
+// spec.
+func (c *conformance) checkErrors(ctx context.Context) {
+	cases := []struct {
+		method string
+		name   string
+		args   []interface{}
+		code   int
+	}{
+		{"gasper_unknownMethod", "unknown method", nil, rpcErrCodeMethodNotFound},
+		{"eth_getBlockByNumber", "invalid hex block number", []interface{}{"0xzz", false}, rpcErrCodeInvalidParams},
+		{"eth_getBlockByNumber", "block number with leading zeros", []interface{}{"0x01", false}, rpcErrCodeInvalidParams},
+		{"eth_getBlockByNumber", "too many params", []interface{}{"latest", false, true}, rpcErrCodeInvalidParams},
+		{"eth_getBalance", "short address", []interface{}{"0x1234", "latest"}, rpcErrCodeInvalidParams},
+		{"eth_getBalance", "missing params", nil, rpcErrCodeInvalidParams},
+		{"eth_getTransactionByHash", "short hash", []interface{}{"0x1234"}, rpcErrCodeInvalidParams},
+		{"eth_getBlockByHash", "hash without prefix", []interface{}{strings.TrimPrefix(c.hash, "0x"), false}, rpcErrCodeInvalidParams},
+	}
+	for _, ec := range cases {
+		args := ec.args
+		if args == nil {
+			args = []interface{}{}
+		}
+		_, err := c.call(ctx, ec.method, args...)
+		check := &ConformanceCheck{Method: ec.method, Check: CheckErrors, Case: ec.name}
+		switch code := rpcErrorCode(err); {
+		case err == nil:
+			check.Error = fmt.Sprintf("expected error code %d, got a result", ec.code)
+		case code != ec.code:
+			check.Error = fmt.Sprintf("expected error code %d, got %d: %s", ec.code, code, err)
+		default:
+			check.Passed = true
+		}
+		c.add(check)
+	}
+}
+
+// checkNotFound checks that unknown blocks and transactions are null rather
+// than errors.
+func (c *conformance) checkNotFound(ctx context.Context) {
+	unknown := common.Hash{}.Hex()
+	var head uint64
+	if n, err := hexutil.DecodeUint64(c.number); err == nil {
+		head = n
+	}
+	future := hexutil.Uint64(head + 1_000_000).String()
+
+	cases := []struct {
+		method string
+		args   []interface{}
+	}{
+		{"eth_getBlockByHash", []interface{}{unknown, false}},
+		{"eth_getBlockByNumber", []interface{}{future, false}},
+		{"eth_getTransactionByHash", []interface{}{unknown}},
+		{"eth_getTransactionReceipt", []interface{}{unknown}},
+	}
+	for _, nc := range cases {
+		result, err := c.call(ctx, nc.method, nc.args...)
+		check := &ConformanceCheck{Method: nc.method, Check: CheckNotFound}
+		switch {
+		case err != nil:
+			check.Error = err.Error()
+		case string(result) != "null":
+			check.Error = fmt.Sprintf("expected null, got %s", truncate(result))
+		default:
+			check.Passed = true
+		}
+		c.add(check)
+	}
+}
+
+// checkShapes checks the fields of the block, transaction, receipt and log
+// objects.
+func (c *conformance) checkShapes(ctx context.Context) {
+	c.add(shapeCheck("eth_getBlockByNumber", "block", c.head, nil, blockFields))
+
+	if c.tx == "" {
+		for _, method := range []string{"eth_getTransactionByHash", "eth_getTransactionReceipt"} {
+			c.add(&ConformanceCheck{Method: method, Check: CheckShape, Skipped: true, Error: "no transaction found"})
+		}
+		return
+	}
+	tx, err := c.call(ctx, "eth_getTransactionByHash", c.tx)
+	c.add(shapeCheck("eth_getTransactionByHash", "transaction", tx, err, transactionFields))
+
+	receipt, err := c.call(ctx, "eth_getTransactionReceipt", c.tx)
+	c.add(shapeCheck("eth_getTransactionReceipt", "receipt", receipt, err, receiptFields))
+	if err != nil {
+		return
+	}
+
+	var r struct {
+		Logs []json.RawMessage `json:"logs"`
+	}
+	if err := json.Unmarshal(receipt, &r); err != nil || len(r.Logs) == 0 {
+		return
+	}
+	c.add(shapeCheck("eth_getTransactionReceipt", "log", r.Logs[0], nil, logFields))
+}
+
+func shapeCheck(method, name string, raw json.RawMessage, err error, fields []fieldSpec) *ConformanceCheck {
+	check := &ConformanceCheck{Method: method, Check: CheckShape, Case: name}
+	switch {
+	case err != nil:
+		check.Error = err.Error()
+	case string(raw) == "null":
+		check.Error = "result is null"
+	default:
+		if err := checkFields(raw, fields); err != nil {
+			check.Error = err.Error()
+		}
+	}
+	check.Passed = check.Error == ""
+	return check
+}
+
+// rpcErrorCode returns the JSON-RPC error code of err, 0 when it has none.
+func rpcErrorCode(err error) int {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.ErrorCode()
+	}
+	return 0
+}
@@ -0,0 +1,296 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonError struct {
+	code int
+	msg  string
+}
+
+func (e *jsonError) Error() string  { return e.msg }
+func (e *jsonError) ErrorCode() int { return e.code }
+
+func invalidParams(msg string) error {
+	return &jsonError{code: rpcErrCodeInvalidParams, msg: msg}
+}
+
+var (
+	testHash    = "0x" + strings.Repeat("ab", 32)
+	testTx      = "0x" + strings.Repeat("cd", 32)
+	testAddress = "0x" + strings.Repeat("12", 20)
+	testBloom   = "0x" + strings.Repeat("00", 256)
+)
+
+func testBlock(hydrated bool) map[string]interface{} {
+	var txs interface{} = []string{testTx}
+	if hydrated {
+		txs = []interface{}{testTransaction()}
+	}
+	return map[string]interface{}{
+		"number": "0x10", "hash": testHash, "parentHash": testHash, "sha3Uncles": testHash,
+		"miner": testAddress, "stateRoot": testHash, "transactionsRoot": testHash, "receiptsRoot": testHash,
+		"logsBloom": testBloom, "difficulty": "0x0", "gasLimit": "0x1c9c380", "gasUsed": "0x5208",
+		"timestamp": "0x6553f100", "extraData": "0x", "mixHash": testHash, "nonce": "0x0000000000000000",
+		"size": "0x27f", "transactions": txs, "uncles": []string{}, "baseFeePerGas": "0x7",
+	}
+}
+
+func testTransaction() map[string]interface{} {
+	return map[string]interface{}{
+		"hash": testTx, "blockHash": testHash, "blockNumber": "0x10", "transactionIndex": "0x0",
+		"from": testAddress, "to": nil, "nonce": "0x0", "value": "0x0", "gas": "0x5208", "input": "0x",
+		"type": "0x2", "v": "0x0", "r": "0x1", "s": "0x1", "chainId": "0x539",
+		"maxFeePerGas": "0xf", "maxPriorityFeePerGas": "0x1", "accessList": []interface{}{},
+	}
+}
+
+func testReceipt() map[string]interface{} {
+	return map[string]interface{}{
+		"transactionHash": testTx, "transactionIndex": "0x0", "blockHash": testHash, "blockNumber": "0x10",
+		"from": testAddress, "to": nil, "cumulativeGasUsed": "0x5208", "gasUsed": "0x5208",
+		"contractAddress": testAddress, "logsBloom": testBloom, "status": "0x1", "effectiveGasPrice": "0x8", "type": "0x2",
+		"logs": []interface{}{map[string]interface{}{
+			"address": testAddress, "topics": []string{testHash}, "data": "0x", "blockNumber": "0x10",
+			"blockHash": testHash, "transactionHash": testTx, "transactionIndex": "0x0", "logIndex": "0x0", "removed": false,
+		}},
+	}
+}
+
+// fakeNode serves the methods of the handlers, with the error codes of the
+// spec for invalid input.
+type fakeNode map[string]func(args []interface{}) (interface{}, error)
+
+func (fn fakeNode) CallContext(_ context.Context, result interface{}, method string, args ...interface{}) error {
+	handler, ok := fn[method]
+	if !ok {
+		return &jsonError{code: rpcErrCodeMethodNotFound, msg: fmt.Sprintf("the method %s does not exist/is not available", method)}
+	}
+	res, err := handler(args)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, result)
+}
+
+func value(v interface{}) func([]interface{}) (interface{}, error) {
+	return func([]interface{}) (interface{}, error) { return v, nil }
+}
+
+func checkArgs(args []interface{}, kinds ...valueKind) error {
+	if len(args) != len(kinds) {
+		return invalidParams(fmt.Sprintf("expected %d params, got %d", len(kinds), len(args)))
+	}
+	for i, kind := range kinds {
+		s, ok := args[i].(string)
+		if !ok {
+			continue
+		}
+		raw, _ := json.Marshal(s)
+		if err := checkValue(raw, kind); err != nil {
+			return invalidParams(fmt.Sprintf("invalid argument %d: %s", i, err))
+		}
+	}
+	return nil
+}
+
+func newFakeNode() fakeNode {
+	return fakeNode{
+		"web3_clientVersion":       value("Geth/v1.15.11"),
+		"net_version":              value("1337"),
+		"eth_chainId":              value("0x539"),
+		"eth_syncing":              value(false),
+		"eth_blockNumber":          value("0x10"),
+		"eth_gasPrice":             value("0x8"),
+		"eth_maxPriorityFeePerGas": value("0x1"),
+		"eth_feeHistory":           value(map[string]interface{}{"oldestBlock": "0x10"}),
+		"eth_getBalance": func(args []interface{}) (interface{}, error) {
+			if len(args) == 2 {
+				if _, ok := args[1].(map[string]interface{}); ok {
+					args = args[:1]
+					return "0x10", checkArgs(args, kindAddress)
+				}
+			}
+			return "0x10", checkArgs(args, kindAddress, kindAny)
+		},
+		"eth_getTransactionCount": value("0x1"),
+		"eth_getCode":             value("0x"),
+		"eth_getStorageAt":        value("0x" + strings.Repeat("00", 32)),
+		"eth_call":                value("0x"),
+		"eth_estimateGas":         value("0x5208"),
+		"eth_createAccessList":    value(map[string]interface{}{"accessList": []interface{}{}, "gasUsed": "0x5208"}),
+		"eth_getProof":            value(map[string]interface{}{"address": testAddress}),
+		"eth_getBlockByNumber": func(args []interface{}) (interface{}, error) {
+			if err := checkArgs(args, kindAny, kindAny); err != nil {
+				return nil, err
+			}
+			tag := args[0].(string)
+			switch tag {
+			case "earliest", "latest", "pending", "safe", "finalized", "0x10", "0xf":
+			default:
+				if !quantityPattern.MatchString(tag) {
+					return nil, invalidParams("invalid block number " + tag)
+				}
+				if tag != "0x0" {
+					return nil, nil
+				}
+			}
+			return testBlock(args[1] == true), nil
+		},
+		"eth_getBlockByHash": func(args []interface{}) (interface{}, error) {
+			if err := checkArgs(args, kindHash, kindAny); err != nil {
+				return nil, err
+			}
+			if args[0] != testHash {
+				return nil, nil
+			}
+			return testBlock(false), nil
+		},
+		"eth_getBlockTransactionCountByNumber": value("0x1"),
+		"eth_getBlockTransactionCountByHash":   value("0x1"),
+		"eth_getUncleCountByBlockNumber":       value("0x0"),
+		"eth_getBlockReceipts":                 value([]interface{}{testReceipt()}),
+		"eth_getLogs":                          value([]interface{}{}),
+		"eth_getTransactionByHash": func(args []interface{}) (interface{}, error) {
+			if err := checkArgs(args, kindHash); err != nil {
+				return nil, err
+			}
+			if args[0] != testTx {
+				return nil, nil
+			}
+			return testTransaction(), nil
+		},
+		"eth_getTransactionByBlockNumberAndIndex": value(testTransaction()),
+		"eth_getTransactionReceipt": func(args []interface{}) (interface{}, error) {
+			if args[0] != testTx {
+				return nil, nil
+			}
+			return testReceipt(), nil
+		},
+		"eth_sendRawTransaction": func([]interface{}) (interface{}, error) {
+			return nil, &jsonError{code: -32000, msg: "typed transaction too short"}
+		},
+	}
+}
+
+func TestCheckConformance(t *testing.T) {
+	t.Run("conforming node", func(t *testing.T) {
+		report := CheckConformance(context.Background(), newFakeNode())
+		assert.Empty(t, report.Failures())
+		assert.Zero(t, report.Skipped)
+		assert.Equal(t, len(report.Checks), report.Passed)
+	})
+
+	t.Run("non conforming node", func(t *testing.T) {
+		node := newFakeNode()
+		delete(node, "eth_getBlockReceipts")
+		node["eth_blockNumber"] = value("0x010")
+		node["eth_getTransactionReceipt"] = func(args []interface{}) (interface{}, error) {
+			if args[0] != testTx {
+				return nil, &jsonError{code: -32000, msg: "not found"}
+			}
+			receipt := testReceipt()
+			delete(receipt, "effectiveGasPrice")
+			return receipt, nil
+		}
+		node["eth_getBalance"] = value("0x10")
+
+		report := CheckConformance(context.Background(), node)
+		failures := make([]string, 0)
+		for _, f := range report.Failures() {
+			failures = append(failures, fmt.Sprintf("%s %s %s", f.Method, f.Check, f.Case))
+		}
+		assert.ElementsMatch(t, []string{
+			"eth_getBlockReceipts presence ",
+			"eth_blockNumber result ",
+			"eth_getBalance errors short address",
+			"eth_getBalance errors missing params",
+			"eth_getTransactionReceipt not_found ",
+			"eth_getTransactionReceipt shape receipt",
+		}, failures)
+		assert.Equal(t, len(failures), report.Failed)
+	})
+
+	t.Run("no transaction", func(t *testing.T) {
+		node := newFakeNode()
+		node["eth_getBlockByNumber"] = func(args []interface{}) (interface{}, error) {
+			block := testBlock(false)
+			block["number"] = "0x1"
+			block["transactions"] = []string{}
+			return block, nil
+		}
+		report := CheckConformance(context.Background(), node)
+		assert.Equal(t, 5, report.Skipped)
+	})
+
+	t.Run("unreachable node", func(t *testing.T) {
+		report := CheckConformance(context.Background(), fakeNode{})
+		require.Len(t, report.Checks, 1)
+		assert.Equal(t, 1, report.Failed)
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	node := newFakeNode()
+	node["eth_getBlockByNumber"] = func(args []interface{}) (interface{}, error) {
+		number := args[0].(string)
+		if number == "latest" {
+			number = "0x10"
+		}
+		block := testBlock(false)
+		block["number"] = number
+		if number != "0xe" {
+			block["transactions"] = []string{}
+		}
+		return block, nil
+	}
+	c := &conformance{rc: node, report: &ConformanceReport{}}
+	require.NoError(t, c.discover(context.Background()))
+	assert.Equal(t, "0x10", c.number)
+	assert.Equal(t, "0xe", c.txBlock)
+	assert.Equal(t, testTx, c.tx)
+}
+
+func TestCheckValue(t *testing.T) {
+	tests := []struct {
+		value string
+		kind  valueKind
+		valid bool
+	}{
+		{`"0x0"`, kindQuantity, true},
+		{`"0x1a"`, kindQuantity, true},
+		{`"0x01"`, kindQuantity, false},
+		{`"0x"`, kindQuantity, false},
+		{`"0x1A"`, kindQuantity, false},
+		{`16`, kindQuantity, false},
+		{`"0x"`, kindData, true},
+		{`"0x0a0b"`, kindData, true},
+		{`"0xabc"`, kindData, false},
+		{`"0x` + strings.Repeat("ab", 32) + `"`, kindHash, true},
+		{`"0x` + strings.Repeat("ab", 31) + `"`, kindHash, false},
+		{`"0x` + strings.Repeat("aB", 20) + `"`, kindAddress, true},
+		{`"1337"`, kindDecimal, true},
+		{`"0x539"`, kindDecimal, false},
+		{`[]`, kindHashes, true},
+		{`["0x12"]`, kindHashes, false},
+		{`{}`, kindObject, true},
+		{`null`, kindObject, false},
+		{`false`, kindBool, true},
+	}
+	for _, tt := range tests {
+		err := checkValue(json.RawMessage(tt.value), tt.kind)
+		assert.Equal(t, tt.valid, err == nil, "%s as %s: %v", tt.value, tt.kind, err)
+	}
+}
@@ -0,0 +1,132 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/mysteryforge/gasper/bindings"
+	"github.com/mysteryforge/gasper/k6/loadtest"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	maxBalancesAtOnce = 16
+
+	checkLedgerBalance      = "ledger_balance"       // wei balance of a target address
+	checkLedgerTokenBalance = "ledger_token_balance" // ERC20 balance of a target address or tester
+)
+
+// LedgerDiscrepancy is an on-chain balance differing from the balance
+// expected from the transfers of the client.
+type LedgerDiscrepancy struct {
+	Address    string `json:"address"`
+	Asset      string `json:"asset"` // eth or erc20
+	Expected   string `json:"expected"`
+	Actual     string `json:"actual"`
+	Difference string `json:"difference"` // actual - expected
+	Pending    int64  `json:"pending"`    // change of the transfers without a receipt, allowed on top of expected
+}
+
+type LedgerReport struct {
+	Block         uint64               `json:"block"`    // block of the on-chain balances
+	Baseline      uint64               `json:"baseline"` // block of the balances before the first transfer
+	Accounts      int                  `json:"accounts"`
+	Discrepancies []*LedgerDiscrepancy `json:"discrepancies"`
+	Errors        []*AccountError      `json:"errors"`
+	results       []integrityResult
+}
+
+// CheckLedger compares the expected balances of the ledger with the balances
+// at the head. A balance is consistent when it is between the expected balance
+// and the expected balance with the pending transfers.
+func CheckLedger(ctx context.Context, rc Caller, state *loadtest.LedgerState) (*LedgerReport, error) {
+	var head hexutil.Uint64
+	if err := callWithTimeout(ctx, rc, &head, "eth_blockNumber"); err != nil {
+		return nil, fmt.Errorf("failed to get the head: %w", err)
+	}
+	tag := hexutil.Uint64(head).String()
+
+	actual := make([]*big.Int, len(state.Accounts))
+	errs := make([]error, len(state.Accounts))
+	sem := semaphore.NewWeighted(maxBalancesAtOnce)
+	for i, account := range state.Accounts {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+		go func() {
+			defer sem.Release(1)
+			if account.Token {
+				actual[i], errs[i] = tokenBalance(ctx, rc, state.ERC20, account.Address, tag)
+				return
+			}
+			var balance hexutil.Big
+			errs[i] = callWithTimeout(ctx, rc, &balance, "eth_getBalance", account.Address, tag)
+			actual[i] = balance.ToInt()
+		}()
+	}
+	if err := sem.Acquire(ctx, maxBalancesAtOnce); err != nil {
+		return nil, err
+	}
+
+	report := &LedgerReport{
+		Block:         uint64(head),
+		Baseline:      state.Block,
+		Discrepancies: make([]*LedgerDiscrepancy, 0),
+		Errors:        make([]*AccountError, 0),
+		results:       make([]integrityResult, 0, len(state.Accounts)),
+	}
+	for i, account := range state.Accounts {
+		if errs[i] != nil {
+			report.Errors = append(report.Errors, &AccountError{Address: account.Address.Hex(), Error: errs[i].Error()})
+			continue
+		}
+		report.Accounts++
+
+		check, asset := checkLedgerBalance, "eth"
+		if account.Token {
+			check, asset = checkLedgerTokenBalance, "erc20"
+		}
+		diff := new(big.Int).Sub(actual[i], account.Expected)
+		low, high := min(account.Pending, 0), max(account.Pending, 0)
+		violated := diff.Cmp(big.NewInt(low)) < 0 || diff.Cmp(big.NewInt(high)) > 0
+		report.results = append(report.results, integrityResult{check: check, violated: violated})
+		if violated {
+			report.Discrepancies = append(report.Discrepancies, &LedgerDiscrepancy{
+				Address:    account.Address.Hex(),
+				Asset:      asset,
+				Expected:   account.Expected.String(),
+				Actual:     actual[i].String(),
+				Difference: diff.String(),
+				Pending:    account.Pending,
+			})
+		}
+	}
+	return report, nil
+}
+
+func tokenBalance(ctx context.Context, rc Caller, token *common.Address, addr common.Address, tag string) (*big.Int, error) {
+	if token == nil {
+		return nil, fmt.Errorf("no ERC20 contract")
+	}
+	parsed, err := bindings.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ERC20 abi: %w", err)
+	}
+	data, err := parsed.Pack("balanceOf", addr)
+	if err != nil {
+		return nil, err
+	}
+	var res hexutil.Bytes
+	msg := map[string]interface{}{"to": token, "input": hexutil.Bytes(data)}
+	if err := callWithTimeout(ctx, rc, &res, "eth_call", msg, tag); err != nil {
+		return nil, fmt.Errorf("failed to get ERC20 balance: %w", err)
+	}
+	out, err := parsed.Unpack("balanceOf", res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ERC20 balance: %w", err)
+	}
+	return out[0].(*big.Int), nil
+}
@@ -0,0 +1,67 @@
+package integrity
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/mysteryforge/gasper/k6/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckLedger(t *testing.T) {
+	erc20 := common.HexToAddress("0xe20")
+	consistent, short, pending, failing := common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03"), common.HexToAddress("0x04")
+	balances := map[common.Address]int64{consistent: 1005, short: 999, pending: 1002}
+	tokens := map[common.Address]int64{consistent: 7, pending: 4}
+
+	node := fakeNode{
+		"eth_blockNumber": value("0x20"),
+		"eth_getBalance": func(args []interface{}) (interface{}, error) {
+			addr := args[0].(common.Address)
+			if addr == failing {
+				return nil, &jsonError{code: -32000, msg: "missing trie node"}
+			}
+			return (*hexutil.Big)(big.NewInt(balances[addr])), nil
+		},
+		"eth_call": func(args []interface{}) (interface{}, error) {
+			input := args[0].(map[string]interface{})["input"].(hexutil.Bytes)
+			// balanceOf(address)
+			addr := common.BytesToAddress(input[4:])
+			return hexutil.Bytes(common.BigToHash(big.NewInt(tokens[addr])).Bytes()), nil
+		},
+	}
+
+	state := &loadtest.LedgerState{
+		Block: 0x10,
+		ERC20: &erc20,
+		Accounts: []*loadtest.LedgerAccount{
+			{Address: consistent, Expected: big.NewInt(1005)},
+			{Address: short, Expected: big.NewInt(1000)},
+			// 3 transfers without a receipt, 2 of them included
+			{Address: pending, Expected: big.NewInt(1000), Pending: 3},
+			{Address: failing, Expected: big.NewInt(1000)},
+			{Address: consistent, Token: true, Expected: big.NewInt(7)},
+			// sent 2 tokens without a receipt, only 1 included
+			{Address: pending, Token: true, Expected: big.NewInt(5), Pending: -2},
+		},
+	}
+	report, err := CheckLedger(context.Background(), node, state)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x20), report.Block)
+	assert.Equal(t, uint64(0x10), report.Baseline)
+	assert.Equal(t, 5, report.Accounts)
+	assert.Equal(t, []*LedgerDiscrepancy{{Address: short.Hex(), Asset: "eth", Expected: "1000", Actual: "999", Difference: "-1"}}, report.Discrepancies)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, failing.Hex(), report.Errors[0].Address)
+
+	state.Accounts = []*loadtest.LedgerAccount{{Address: consistent, Token: true, Expected: big.NewInt(8)}}
+	report, err = CheckLedger(context.Background(), node, state)
+	require.NoError(t, err)
+	require.Len(t, report.Discrepancies, 1)
+	assert.Equal(t, "erc20", report.Discrepancies[0].Asset)
+	assert.Equal(t, []integrityResult{{check: checkLedgerTokenBalance, violated: true}}, report.results)
+}
@@ -0,0 +1,106 @@
+package integrity
+
+import (
+	"time"
+
+	"github.com/mysteryforge/gasper/k6/loadtest"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/metrics"
+)
+
+type IntegrityMetrics struct {
+	Conformance *metrics.Metric
+	RPCMismatch *metrics.Metric
+	Violation   *metrics.Metric
+}
+
+func RegisterMetrics(vu modules.VU) *IntegrityMetrics {
+	r := vu.InitEnv().Registry
+	return &IntegrityMetrics{
+		Conformance: r.MustNewMetric("gasper_conformance", metrics.Rate, metrics.Default),
+		RPCMismatch: r.MustNewMetric("gasper_rpc_mismatch", metrics.Rate, metrics.Default),
+		Violation:   r.MustNewMetric("gasper_integrity_violation", metrics.Rate, metrics.Default),
+	}
+}
+
+// ReportConformanceFromStats reports every check of the report that was not
+// skipped, tagged with its method and kind of check.
+func ReportConformanceFromStats(vu modules.VU, m *IntegrityMetrics, clientUID string, report *ConformanceReport) {
+	if vu.State() == nil {
+		return
+	}
+
+	now := time.Now()
+	samples := make(metrics.Samples, 0, len(report.Checks))
+	for _, check := range report.Checks {
+		if check.Skipped {
+			continue
+		}
+		tags := metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+			"client_uid": clientUID,
+			"test_uid":   loadtest.TestUID,
+			"method":     check.Method,
+			"check":      string(check.Check),
+		})
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: m.Conformance, Tags: tags},
+			Value:      metrics.B(check.Passed),
+			Time:       now,
+		})
+	}
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, samples)
+}
+
+// ReportComparisonFromStats reports whether each client disagreed with the
+// reference on each compared call, tagged with the method.
+func ReportComparisonFromStats(vu modules.VU, m *IntegrityMetrics, clientUIDs []string, cmp *Comparison) {
+	if vu.State() == nil {
+		return
+	}
+
+	now := time.Now()
+	samples := make(metrics.Samples, 0, len(cmp.compared)*len(clientUIDs))
+	for _, cc := range cmp.compared {
+		for _, uid := range clientUIDs {
+			if uid == cc.Reference {
+				continue
+			}
+			tags := metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+				"client_uid": uid,
+				"test_uid":   loadtest.TestUID,
+				"method":     cc.Method,
+				"reference":  cc.Reference,
+			})
+			samples = append(samples, metrics.Sample{
+				TimeSeries: metrics.TimeSeries{Metric: m.RPCMismatch, Tags: tags},
+				Value:      metrics.B(cc.Mismatch(uid)),
+				Time:       now,
+			})
+		}
+	}
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, samples)
+}
+
+// ReportViolationsFromStats reports whether each integrity check was
+// violated, tagged with the check.
+func ReportViolationsFromStats(vu modules.VU, m *IntegrityMetrics, clientUID string, results []integrityResult) {
+	if vu.State() == nil {
+		return
+	}
+
+	now := time.Now()
+	samples := make(metrics.Samples, 0, len(results))
+	for _, r := range results {
+		tags := metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+			"client_uid": clientUID,
+			"test_uid":   loadtest.TestUID,
+			"check":      r.check,
+		})
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: m.Violation, Tags: tags},
+			Value:      metrics.B(r.violated),
+			Time:       now,
+		})
+	}
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, samples)
+}
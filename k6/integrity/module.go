@@ -1,6 +1,9 @@
 package integrity
 
 import (
+	"fmt"
+
+	"github.com/mysteryforge/gasper/k6/loadtest"
 	"go.k6.io/k6/js/modules"
 )
 
@@ -9,7 +12,8 @@ func init() {}
 type RootModule struct{}
 
 type ModuleInstance struct {
-	vu modules.VU
+	vu      modules.VU
+	metrics *IntegrityMetrics
 }
 
 var (
@@ -23,7 +27,8 @@ func New() *RootModule {
 
 func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	return &ModuleInstance{
-		vu: vu,
+		vu:      vu,
+		metrics: RegisterMetrics(vu),
 	}
 }
 
@@ -33,6 +38,181 @@ func (mi *ModuleInstance) Exports() modules.Exports {
 			"sayHello": func() interface{} {
 				return "Hello Integrity"
 			},
+			"checkConformance": func(uid string) interface{} {
+				return loadtest.SharedClients(uid).Execute(func(c loadtest.Client) (any, error) {
+					return mi.checkConformance(c)
+				})
+			},
+			"compareClients": func(uid string, params map[string]interface{}) interface{} {
+				cmp, err := mi.compareClients(loadtest.SharedClients(uid), params)
+				return &loadtest.Result{Err: err, Data: cmp}
+			},
+			"checkLedger": func(uid string) interface{} {
+				return loadtest.SharedClients(uid).Execute(func(c loadtest.Client) (any, error) {
+					return mi.checkLedger(c)
+				})
+			},
+			"verifyProofs": func(uid string, params map[string]interface{}) interface{} {
+				return loadtest.SharedClients(uid).Execute(func(c loadtest.Client) (any, error) {
+					return mi.verifyProofs(c, params)
+				})
+			},
+			"verifyRoots": func(uid string, params map[string]interface{}) interface{} {
+				return loadtest.SharedClients(uid).Execute(func(c loadtest.Client) (any, error) {
+					return mi.verifyRoots(c, params)
+				})
+			},
 		},
 	}
 }
+
+// checkConformance checks the node of the client against the execution-apis
+// spec.
+func (mi *ModuleInstance) checkConformance(c loadtest.Client) (*ConformanceReport, error) {
+	ec := c.EthClient()
+	if ec == nil {
+		return nil, fmt.Errorf("client %s has no node connection", c.UID())
+	}
+	report := CheckConformance(mi.vu.Context(), ec.Rc)
+	ReportConformanceFromStats(mi.vu, mi.metrics, c.UID(), report)
+	return report, nil
+}
+
+// compareClients runs the calls of params on every client and diffs the
+// results with the first client of the config.
+func (mi *ModuleInstance) compareClients(cs *loadtest.Clients, params map[string]interface{}) (*Comparison, error) {
+	opts, err := parseCompareParams(params)
+	if err != nil {
+		return nil, err
+	}
+	callers := make([]NamedCaller, 0)
+	uids := make([]string, 0)
+	for _, c := range cs.List() {
+		ec := c.EthClient()
+		if ec == nil {
+			return nil, fmt.Errorf("client %s has no node connection", c.UID())
+		}
+		callers = append(callers, NamedCaller{UID: c.UID(), RC: ec.Rc})
+		uids = append(uids, c.UID())
+	}
+
+	cmp, err := Compare(mi.vu.Context(), callers, opts)
+	if err != nil {
+		return nil, err
+	}
+	ReportComparisonFromStats(mi.vu, mi.metrics, uids, cmp)
+	return cmp, nil
+}
+
+// verifyRoots recomputes the roots of the blocks of params served by the
+// node of the client.
+func (mi *ModuleInstance) verifyRoots(c loadtest.Client, params map[string]interface{}) (*RootsReport, error) {
+	ec := c.EthClient()
+	if ec == nil {
+		return nil, fmt.Errorf("client %s has no node connection", c.UID())
+	}
+	opts, err := parseRootsParams(params)
+	if err != nil {
+		return nil, err
+	}
+	report, err := VerifyRoots(mi.vu.Context(), ec.Rc, opts)
+	if err != nil {
+		return nil, err
+	}
+	ReportViolationsFromStats(mi.vu, mi.metrics, c.UID(), report.results)
+	return report, nil
+}
+
+// checkLedger compares the balances expected from the transfers of the client
+// with the on-chain ones.
+func (mi *ModuleInstance) checkLedger(c loadtest.Client) (*LedgerReport, error) {
+	ec := c.EthClient()
+	if ec == nil {
+		return nil, fmt.Errorf("client %s has no node connection", c.UID())
+	}
+	state, err := c.Ledger(mi.vu.Context())
+	if err != nil {
+		return nil, err
+	}
+	report, err := CheckLedger(mi.vu.Context(), ec.Rc, state)
+	if err != nil {
+		return nil, err
+	}
+	ReportViolationsFromStats(mi.vu, mi.metrics, c.UID(), report.results)
+	return report, nil
+}
+
+// verifyProofs verifies the state proofs of the tester wallets and contracts
+// of the client.
+func (mi *ModuleInstance) verifyProofs(c loadtest.Client, params map[string]interface{}) (*ProofReport, error) {
+	ec := c.EthClient()
+	if ec == nil {
+		return nil, fmt.Errorf("client %s has no node connection", c.UID())
+	}
+	var block *uint64
+	if b, ok := params["block"].(int64); ok {
+		if b < 0 {
+			return nil, fmt.Errorf("invalid block: %d", b)
+		}
+		n := uint64(b)
+		block = &n
+	}
+	report, err := VerifyProofs(mi.vu.Context(), ec.Rc, ProofTargets(c.StateAccounts()), block)
+	if err != nil {
+		return nil, err
+	}
+	ReportViolationsFromStats(mi.vu, mi.metrics, c.UID(), report.results)
+	return report, nil
+}
+
+func parseCompareParams(params map[string]interface{}) (*CompareOptions, error) {
+	opts := &CompareOptions{}
+	if method, ok := params["method"].(string); ok {
+		opts.Method = method
+	}
+	if p, ok := params["params"].([]interface{}); ok {
+		opts.Params = p
+	}
+	if blocks, ok := params["blocks"].(int64); ok && blocks > 0 {
+		opts.Blocks = uint64(blocks)
+	}
+	if block, ok := params["block"].(int64); ok {
+		if block < 0 {
+			return nil, fmt.Errorf("invalid block: %d", block)
+		}
+		b := uint64(block)
+		opts.Block = &b
+	}
+	if estimates, ok := params["estimates"].(int64); ok {
+		opts.Estimates = int(estimates)
+	}
+	if ignore, ok := params["ignore"].([]interface{}); ok {
+		for _, field := range ignore {
+			s, ok := field.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid ignored field: %v", field)
+			}
+			opts.Ignore = append(opts.Ignore, s)
+		}
+	}
+	return opts, nil
+}
+
+func parseRootsParams(params map[string]interface{}) (*RootsOptions, error) {
+	opts := &RootsOptions{}
+	for key, dst := range map[string]**uint64{"from": &opts.From, "to": &opts.To} {
+		v, ok := params[key].(int64)
+		if !ok {
+			continue
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("invalid %s: %d", key, v)
+		}
+		n := uint64(v)
+		*dst = &n
+	}
+	if blocks, ok := params["blocks"].(int64); ok && blocks > 0 {
+		opts.Blocks = uint64(blocks)
+	}
+	return opts, nil
+}
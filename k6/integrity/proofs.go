@@ -0,0 +1,339 @@
+package integrity
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/mysteryforge/gasper/k6/loadtest"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	maxSlotsPerProof = 32 // storage keys of one eth_getProof call
+	maxProofsAtOnce  = 8
+
+	// storage layout of the OpenZeppelin tokens of the load tests
+	erc20BalancesSlot     = 0
+	erc20TotalSupplySlot  = 2
+	erc721BalancesSlot    = 3
+	erc721CurrentTokenID  = 6
+	deployedContractSlots = 3 // first slots of the deployed contracts, e.g. DummyStorage
+)
+
+type ProofCheck string
+
+const (
+	ProofAccount   ProofCheck = "account_proof" // the account proof leads to the account from the state root
+	ProofStorage   ProofCheck = "storage_proof" // the storage proofs lead to the values from the storage root
+	ProofBalance   ProofCheck = "balance"       // eth_getBalance agrees with the proof
+	ProofStorageAt ProofCheck = "storage_at"    // eth_getStorageAt agrees with the proof
+)
+
+// ProofTarget is an account and the storage slots proven.
+type ProofTarget struct {
+	Name    string // kind of account, e.g. tester or erc20
+	Address common.Address
+	Slots   []common.Hash
+}
+
+type ProofViolation struct {
+	Address string     `json:"address"`
+	Name    string     `json:"name"`
+	Check   ProofCheck `json:"check"`
+	Slot    string     `json:"slot,omitempty"`
+	Error   string     `json:"error"`
+}
+
+// AccountError is an account whose state could not be requested.
+type AccountError struct {
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+type ProofReport struct {
+	Block      uint64            `json:"block"`
+	StateRoot  string            `json:"stateRoot"`
+	Accounts   int               `json:"accounts"` // accounts proven
+	Slots      int               `json:"slots"`    // storage slots proven
+	Checks     int               `json:"checks"`
+	Violations []*ProofViolation `json:"violations"`
+	Errors     []*AccountError   `json:"errors"`
+	results    []integrityResult
+}
+
+type accountResult struct {
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	StorageProof []struct {
+		Value *hexutil.Big    `json:"value"`
+		Proof []hexutil.Bytes `json:"proof"`
+	} `json:"storageProof"`
+}
+
+type proofOutcome struct {
+	violations []*ProofViolation
+	results    []integrityResult
+	err        error   // the proof could not be requested
+	callErrs   []error // cross-checks that could not be requested, not counted as checks
+}
+
+func (o *proofOutcome) add(target *ProofTarget, check ProofCheck, slot *common.Hash, err error) {
+	o.results = append(o.results, integrityResult{check: string(check), violated: err != nil})
+	if err == nil {
+		return
+	}
+	v := &ProofViolation{Address: target.Address.Hex(), Name: target.Name, Check: check, Error: err.Error()}
+	if slot != nil {
+		v.Slot = slot.Hex()
+	}
+	o.violations = append(o.violations, v)
+}
+
+// ProofTargets returns the tester wallets and the contracts to prove, with the
+// tester balances of the token contracts. Contracts with many slots are split
+// across several targets.
+func ProofTargets(accounts *loadtest.StateAccounts) []*ProofTarget {
+	targets := make([]*ProofTarget, 0, len(accounts.Testers)+len(accounts.Deployed)+2)
+	for _, addr := range accounts.Testers {
+		targets = append(targets, &ProofTarget{Name: "tester", Address: addr})
+	}
+	if accounts.ERC20 != nil {
+		slots := []common.Hash{common.BigToHash(big.NewInt(erc20TotalSupplySlot))}
+		for _, addr := range accounts.Testers {
+			slots = append(slots, mappingSlot(addr, erc20BalancesSlot))
+		}
+		targets = append(targets, splitTarget("erc20", *accounts.ERC20, slots)...)
+	}
+	if accounts.ERC721 != nil {
+		slots := []common.Hash{common.BigToHash(big.NewInt(erc721CurrentTokenID))}
+		for _, addr := range accounts.Testers {
+			slots = append(slots, mappingSlot(addr, erc721BalancesSlot))
+		}
+		targets = append(targets, splitTarget("erc721", *accounts.ERC721, slots)...)
+	}
+	for _, addr := range accounts.Deployed {
+		slots := make([]common.Hash, deployedContractSlots)
+		for i := range slots {
+			slots[i] = common.BigToHash(big.NewInt(int64(i)))
+		}
+		targets = append(targets, &ProofTarget{Name: "contract", Address: addr, Slots: slots})
+	}
+	return targets
+}
+
+// mappingSlot returns the slot of the key of a solidity mapping at slot.
+func mappingSlot(key common.Address, slot int64) common.Hash {
+	return crypto.Keccak256Hash(common.LeftPadBytes(key.Bytes(), 32), common.BigToHash(big.NewInt(slot)).Bytes())
+}
+
+func splitTarget(name string, addr common.Address, slots []common.Hash) []*ProofTarget {
+	targets := make([]*ProofTarget, 0)
+	for i := 0; i < len(slots); i += maxSlotsPerProof {
+		targets = append(targets, &ProofTarget{Name: name, Address: addr, Slots: slots[i:min(i+maxSlotsPerProof, len(slots))]})
+	}
+	return targets
+}
+
+// VerifyProofs requests the proofs of the targets at the block, the head by
+// default, verifies them against the state root of the block and cross-checks
+// them with eth_getBalance and eth_getStorageAt.
+func VerifyProofs(ctx context.Context, rc Caller, targets []*ProofTarget, block *uint64) (*ProofReport, error) {
+	var number uint64
+	if block != nil {
+		number = *block
+	} else {
+		var head hexutil.Uint64
+		if err := callWithTimeout(ctx, rc, &head, "eth_blockNumber"); err != nil {
+			return nil, fmt.Errorf("failed to get the head: %w", err)
+		}
+		number = uint64(head)
+	}
+	tag := hexutil.Uint64(number).String()
+
+	var header *types.Header
+	if err := callWithTimeout(ctx, rc, &header, "eth_getBlockByNumber", tag, false); err != nil {
+		return nil, fmt.Errorf("failed to get block %d: %w", number, err)
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+
+	outcomes := make([]*proofOutcome, len(targets))
+	sem := semaphore.NewWeighted(maxProofsAtOnce)
+	for i, target := range targets {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+		go func() {
+			defer sem.Release(1)
+			outcomes[i] = verifyProof(ctx, rc, header.Root, target, tag)
+		}()
+	}
+	if err := sem.Acquire(ctx, maxProofsAtOnce); err != nil {
+		return nil, err
+	}
+
+	report := &ProofReport{
+		Block:      number,
+		StateRoot:  header.Root.Hex(),
+		Violations: make([]*ProofViolation, 0),
+		Errors:     make([]*AccountError, 0),
+		results:    make([]integrityResult, 0),
+	}
+	accounts := make(map[common.Address]struct{})
+	for i, o := range outcomes {
+		if o.err != nil {
+			report.Errors = append(report.Errors, &AccountError{Address: targets[i].Address.Hex(), Error: o.err.Error()})
+			continue
+		}
+		accounts[targets[i].Address] = struct{}{}
+		report.Slots += len(targets[i].Slots)
+		report.Checks += len(o.results)
+		report.Violations = append(report.Violations, o.violations...)
+		for _, err := range o.callErrs {
+			report.Errors = append(report.Errors, &AccountError{Address: targets[i].Address.Hex(), Error: err.Error()})
+		}
+		report.results = append(report.results, o.results...)
+	}
+	report.Accounts = len(accounts)
+	return report, nil
+}
+
+func verifyProof(ctx context.Context, rc Caller, stateRoot common.Hash, target *ProofTarget, tag string) *proofOutcome {
+	keys := make([]string, len(target.Slots))
+	for i, slot := range target.Slots {
+		keys[i] = slot.Hex()
+	}
+	var res *accountResult
+	if err := callWithTimeout(ctx, rc, &res, "eth_getProof", target.Address, keys, tag); err != nil {
+		return &proofOutcome{err: fmt.Errorf("failed to get proof: %w", err)}
+	}
+	if res == nil || res.Balance == nil {
+		return &proofOutcome{err: fmt.Errorf("no proof returned")}
+	}
+	if len(res.StorageProof) != len(target.Slots) {
+		return &proofOutcome{err: fmt.Errorf("got %d storage proofs for %d slots", len(res.StorageProof), len(target.Slots))}
+	}
+
+	o := &proofOutcome{}
+	o.add(target, ProofAccount, nil, verifyAccountProof(stateRoot, target.Address, res))
+	for i, slot := range target.Slots {
+		sp := res.StorageProof[i]
+		var err error
+		if sp.Value == nil {
+			err = fmt.Errorf("no value")
+		} else {
+			err = verifyStorageProof(res.StorageHash, slot, sp.Value.ToInt(), sp.Proof)
+		}
+		o.add(target, ProofStorage, &slot, err)
+	}
+
+	var balance hexutil.Big
+	if err := callWithTimeout(ctx, rc, &balance, "eth_getBalance", target.Address, tag); err != nil {
+		o.callErrs = append(o.callErrs, fmt.Errorf("failed to get balance: %w", err))
+	} else {
+		var err error
+		if balance.ToInt().Cmp(res.Balance.ToInt()) != 0 {
+			err = fmt.Errorf("eth_getBalance returned %s, the proof %s", balance.ToInt(), res.Balance.ToInt())
+		}
+		o.add(target, ProofBalance, nil, err)
+	}
+
+	for i, slot := range target.Slots {
+		var value common.Hash
+		if err := callWithTimeout(ctx, rc, &value, "eth_getStorageAt", target.Address, slot.Hex(), tag); err != nil {
+			o.callErrs = append(o.callErrs, fmt.Errorf("failed to get storage at %s: %w", slot.Hex(), err))
+			continue
+		}
+		var err error
+		if proven := res.StorageProof[i].Value; proven != nil && value.Big().Cmp(proven.ToInt()) != 0 {
+			err = fmt.Errorf("eth_getStorageAt returned %s, the proof %s", value.Big(), proven.ToInt())
+		}
+		o.add(target, ProofStorageAt, &slot, err)
+	}
+	return o
+}
+
+func proofDB(proof []hexutil.Bytes) *memorydb.Database {
+	db := memorydb.New()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node) // nolint:errcheck
+	}
+	return db
+}
+
+// verifyAccountProof returns why the account proof does not lead from the
+// state root to the account of the result, nil when it does.
+func verifyAccountProof(stateRoot common.Hash, addr common.Address, res *accountResult) error {
+	value, err := trie.VerifyProof(stateRoot, crypto.Keccak256(addr.Bytes()), proofDB(res.AccountProof))
+	if err != nil {
+		return fmt.Errorf("invalid account proof: %w", err)
+	}
+
+	account := types.NewEmptyStateAccount()
+	if value != nil {
+		if err := rlp.DecodeBytes(value, account); err != nil {
+			return fmt.Errorf("failed to decode account: %w", err)
+		}
+	} else {
+		// some nodes return zero hashes for missing accounts
+		if res.StorageHash == (common.Hash{}) {
+			account.Root = common.Hash{}
+		}
+		if res.CodeHash == (common.Hash{}) {
+			account.CodeHash = res.CodeHash.Bytes()
+		}
+	}
+
+	switch {
+	case account.Nonce != uint64(res.Nonce):
+		return fmt.Errorf("nonce is %d, proven %d", res.Nonce, account.Nonce)
+	case account.Balance.ToBig().Cmp(res.Balance.ToInt()) != 0:
+		return fmt.Errorf("balance is %s, proven %s", res.Balance.ToInt(), account.Balance)
+	case account.Root != res.StorageHash:
+		return fmt.Errorf("storage hash is %s, proven %s", res.StorageHash.Hex(), account.Root.Hex())
+	case !bytes.Equal(account.CodeHash, res.CodeHash.Bytes()):
+		return fmt.Errorf("code hash is %s, proven %s", res.CodeHash.Hex(), common.BytesToHash(account.CodeHash).Hex())
+	}
+	return nil
+}
+
+// verifyStorageProof returns why the proof does not lead from the storage
+// root to the value of the slot, nil when it does.
+func verifyStorageProof(storageRoot, slot common.Hash, value *big.Int, proof []hexutil.Bytes) error {
+	proven := new(big.Int)
+	if len(proof) == 0 && (storageRoot == types.EmptyRootHash || storageRoot == (common.Hash{})) {
+		// nothing to prove in an empty storage
+		if value.Sign() != 0 {
+			return fmt.Errorf("value is %s, the storage is empty", value)
+		}
+		return nil
+	}
+	enc, err := trie.VerifyProof(storageRoot, crypto.Keccak256(slot.Bytes()), proofDB(proof))
+	if err != nil {
+		return fmt.Errorf("invalid storage proof: %w", err)
+	}
+	if enc != nil {
+		_, content, _, err := rlp.Split(enc)
+		if err != nil {
+			return fmt.Errorf("failed to decode storage value: %w", err)
+		}
+		proven.SetBytes(content)
+	}
+	if proven.Cmp(value) != 0 {
+		return fmt.Errorf("value is %s, proven %s", value, proven)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+	"github.com/mysteryforge/gasper/k6/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// proofList collects the nodes of a proof.
+type proofList []hexutil.Bytes
+
+func (l *proofList) Put(_ []byte, value []byte) error {
+	*l = append(*l, common.CopyBytes(value))
+	return nil
+}
+
+func (l *proofList) Delete([]byte) error { return nil }
+
+// testState serves the proofs of accounts built with go-ethereum's trie.
+type testState struct {
+	t        *testing.T
+	accounts *trie.Trie
+	storage  map[common.Address]*trie.Trie
+	values   map[common.Address]map[common.Hash]*big.Int
+	state    map[common.Address]*types.StateAccount
+}
+
+func newTestState(t *testing.T) *testState {
+	return &testState{
+		t:        t,
+		accounts: trie.NewEmpty(triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil)),
+		storage:  make(map[common.Address]*trie.Trie),
+		values:   make(map[common.Address]map[common.Hash]*big.Int),
+		state:    make(map[common.Address]*types.StateAccount),
+	}
+}
+
+func (ts *testState) setAccount(addr common.Address, nonce, balance uint64, slots map[common.Hash]*big.Int) {
+	account := types.NewEmptyStateAccount()
+	account.Nonce = nonce
+	account.Balance = uint256.NewInt(balance)
+	if len(slots) > 0 {
+		st := trie.NewEmpty(triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil))
+		for slot, value := range slots {
+			enc, err := rlp.EncodeToBytes(value.Bytes())
+			require.NoError(ts.t, err)
+			require.NoError(ts.t, st.Update(crypto.Keccak256(slot.Bytes()), enc))
+		}
+		account.Root = st.Hash()
+		account.CodeHash = crypto.Keccak256([]byte{0x60, 0x00})
+		ts.storage[addr] = st
+		ts.values[addr] = slots
+	}
+	enc, err := rlp.EncodeToBytes(account)
+	require.NoError(ts.t, err)
+	require.NoError(ts.t, ts.accounts.Update(crypto.Keccak256(addr.Bytes()), enc))
+	ts.state[addr] = account
+}
+
+func (ts *testState) proof(args []interface{}) (map[string]interface{}, error) {
+	addr := args[0].(common.Address)
+	var accountProof proofList
+	require.NoError(ts.t, ts.accounts.Prove(crypto.Keccak256(addr.Bytes()), &accountProof))
+
+	account, ok := ts.state[addr]
+	if !ok {
+		account = types.NewEmptyStateAccount()
+	}
+	storageProof := make([]interface{}, 0)
+	for _, key := range args[1].([]string) {
+		slot := common.HexToHash(key)
+		var proof proofList
+		value := new(big.Int)
+		if st, ok := ts.storage[addr]; ok {
+			require.NoError(ts.t, st.Prove(crypto.Keccak256(slot.Bytes()), &proof))
+			if v, ok := ts.values[addr][slot]; ok {
+				value = v
+			}
+		}
+		storageProof = append(storageProof, map[string]interface{}{"key": key, "value": (*hexutil.Big)(value), "proof": proof})
+	}
+	return map[string]interface{}{
+		"address":      addr,
+		"accountProof": accountProof,
+		"balance":      (*hexutil.Big)(account.Balance.ToBig()),
+		"codeHash":     common.BytesToHash(account.CodeHash),
+		"nonce":        hexutil.Uint64(account.Nonce),
+		"storageHash":  account.Root,
+		"storageProof": storageProof,
+	}, nil
+}
+
+func (ts *testState) node() fakeNode {
+	header := &types.Header{Number: big.NewInt(0x10), Difficulty: common.Big0, Root: ts.accounts.Hash()}
+	raw, err := json.Marshal(header)
+	require.NoError(ts.t, err)
+	return fakeNode{
+		"eth_blockNumber":      value("0x10"),
+		"eth_getBlockByNumber": value(json.RawMessage(raw)),
+		"eth_getProof": func(args []interface{}) (interface{}, error) {
+			return ts.proof(args)
+		},
+		"eth_getBalance": func(args []interface{}) (interface{}, error) {
+			if account, ok := ts.state[args[0].(common.Address)]; ok {
+				return (*hexutil.Big)(account.Balance.ToBig()), nil
+			}
+			return "0x0", nil
+		},
+		"eth_getStorageAt": func(args []interface{}) (interface{}, error) {
+			if v, ok := ts.values[args[0].(common.Address)][common.HexToHash(args[1].(string))]; ok {
+				return common.BigToHash(v), nil
+			}
+			return common.Hash{}, nil
+		},
+	}
+}
+
+func TestVerifyProofs(t *testing.T) {
+	tester := common.HexToAddress("0x01")
+	missing := common.HexToAddress("0x02")
+	contract := common.HexToAddress("0x03")
+	targets := []*ProofTarget{
+		{Name: "tester", Address: tester},
+		{Name: "tester", Address: missing},
+		ProofTargets(&loadtest.StateAccounts{Deployed: []common.Address{contract}})[0],
+	}
+	newState := func() *testState {
+		ts := newTestState(t)
+		ts.setAccount(tester, 5, 1000, nil)
+		ts.setAccount(contract, 1, 0, map[common.Hash]*big.Int{
+			common.BigToHash(big.NewInt(0)): big.NewInt(250),
+			common.BigToHash(big.NewInt(1)): big.NewInt(251),
+		})
+		return ts
+	}
+
+	t.Run("consistent", func(t *testing.T) {
+		ts := newState()
+		report, err := VerifyProofs(context.Background(), ts.node(), targets, nil)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0x10), report.Block)
+		assert.Equal(t, ts.accounts.Hash().Hex(), report.StateRoot)
+		assert.Equal(t, 3, report.Accounts)
+		assert.Equal(t, 3, report.Slots)
+		// account and balance of each account, proof and eth_getStorageAt of each slot
+		assert.Equal(t, 12, report.Checks)
+		assert.Empty(t, report.Violations)
+		assert.Empty(t, report.Errors)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		ts := newState()
+		node := ts.node()
+		node["eth_getProof"] = func(args []interface{}) (interface{}, error) {
+			res, err := ts.proof(args)
+			switch args[0].(common.Address) {
+			case tester:
+				res["nonce"] = hexutil.Uint64(6)
+			case contract:
+				res["storageProof"].([]interface{})[1].(map[string]interface{})["value"] = (*hexutil.Big)(big.NewInt(1))
+			}
+			return res, err
+		}
+		node["eth_getBalance"] = value("0x1")
+
+		report, err := VerifyProofs(context.Background(), node, targets, nil)
+		require.NoError(t, err)
+		checks := make(map[string][]ProofCheck)
+		for _, v := range report.Violations {
+			checks[v.Address] = append(checks[v.Address], v.Check)
+		}
+		assert.Equal(t, map[string][]ProofCheck{
+			tester.Hex():   {ProofAccount, ProofBalance},
+			missing.Hex():  {ProofBalance},
+			contract.Hex(): {ProofStorage, ProofBalance, ProofStorageAt},
+		}, checks)
+	})
+
+	t.Run("cross-checks failing", func(t *testing.T) {
+		node := newState().node()
+		node["eth_getBalance"] = func([]interface{}) (interface{}, error) {
+			return nil, &jsonError{code: -32005, msg: "rate limited"}
+		}
+		delete(node, "eth_getStorageAt")
+		report, err := VerifyProofs(context.Background(), node, targets, nil)
+		require.NoError(t, err)
+		// only the proofs are checked
+		assert.Equal(t, 6, report.Checks)
+		assert.Empty(t, report.Violations)
+		assert.Len(t, report.Errors, 6)
+	})
+
+	t.Run("proof not served", func(t *testing.T) {
+		node := newState().node()
+		delete(node, "eth_getProof")
+		report, err := VerifyProofs(context.Background(), node, targets, nil)
+		require.NoError(t, err)
+		assert.Zero(t, report.Checks)
+		assert.Len(t, report.Errors, 3)
+	})
+}
+
+func TestProofTargets(t *testing.T) {
+	testers := make([]common.Address, 40)
+	for i := range testers {
+		testers[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+	erc20 := common.HexToAddress("0xe20")
+	targets := ProofTargets(&loadtest.StateAccounts{Testers: testers, ERC20: &erc20})
+	require.Len(t, targets, 42)
+	assert.Equal(t, "erc20", targets[40].Name)
+	// total supply and the balances of the testers
+	assert.Len(t, targets[40].Slots, maxSlotsPerProof)
+	assert.Len(t, targets[41].Slots, 41-maxSlotsPerProof)
+	assert.Equal(t, common.BigToHash(big.NewInt(erc20TotalSupplySlot)), targets[40].Slots[0])
+	assert.Equal(t, crypto.Keccak256Hash(common.LeftPadBytes(testers[0].Bytes(), 32), make([]byte, 32)), targets[40].Slots[1])
+}
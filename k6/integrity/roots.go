@@ -0,0 +1,251 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	defaultVerifiedBlocks = 64
+	maxVerifiedBlocks     = 4096
+	maxBlocksAtOnce       = 8 // blocks fetched concurrently
+)
+
+type RootKind string
+
+const (
+	RootTransactions RootKind = "transactions_root"
+	RootReceipts     RootKind = "receipts_root"
+	RootLogsBloom    RootKind = "logs_bloom"
+	RootWithdrawals  RootKind = "withdrawals_root"
+)
+
+// RootsOptions is the range of blocks verified, the last 64 blocks by default.
+type RootsOptions struct {
+	From   *uint64
+	To     *uint64 // the head by default
+	Blocks uint64  // number of blocks up to To, when From is not set
+}
+
+// RootViolation is a root of a block header differing from the root
+// recomputed from the body and receipts served by the node.
+type RootViolation struct {
+	Block    uint64   `json:"block"`
+	Hash     string   `json:"hash"`
+	Root     RootKind `json:"root"`
+	Header   string   `json:"header"`
+	Computed string   `json:"computed"`
+}
+
+// BlockError is a block that could not be verified, e.g. with transactions of
+// a type unknown to go-ethereum.
+type BlockError struct {
+	Block uint64 `json:"block"`
+	Error string `json:"error"`
+}
+
+type RootsReport struct {
+	From       uint64           `json:"from"`
+	To         uint64           `json:"to"`
+	Verified   int              `json:"verified"` // blocks whose roots were recomputed
+	Checks     int              `json:"checks"`
+	Violations []*RootViolation `json:"violations"`
+	Errors     []*BlockError    `json:"errors"`
+	results    []integrityResult
+}
+
+// integrityResult is the outcome of one integrity check, reported as
+// gasper_integrity_violation.
+type integrityResult struct {
+	check    string
+	violated bool
+}
+
+type rootCheck struct {
+	root     RootKind
+	header   string
+	computed string
+}
+
+type blockRoots struct {
+	hash   string
+	checks []rootCheck
+	err    error
+}
+
+// VerifyRoots recomputes the transactions root, receipts root, logs bloom and
+// withdrawals root, when the header has one, of every block of the range and
+// compares them with the header.
+func VerifyRoots(ctx context.Context, rc Caller, opts *RootsOptions) (*RootsReport, error) {
+	from, to, err := rootsRange(ctx, rc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*blockRoots, to-from+1)
+	sem := semaphore.NewWeighted(maxBlocksAtOnce)
+	for i := range blocks {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+		go func() {
+			defer sem.Release(1)
+			blocks[i] = verifyBlockRoots(ctx, rc, from+uint64(i))
+		}()
+	}
+	if err := sem.Acquire(ctx, maxBlocksAtOnce); err != nil {
+		return nil, err
+	}
+
+	report := &RootsReport{
+		From:       from,
+		To:         to,
+		Violations: make([]*RootViolation, 0),
+		Errors:     make([]*BlockError, 0),
+		results:    make([]integrityResult, 0),
+	}
+	for i, b := range blocks {
+		number := from + uint64(i)
+		if b.err != nil {
+			report.Errors = append(report.Errors, &BlockError{Block: number, Error: b.err.Error()})
+			continue
+		}
+		report.Verified++
+		for _, c := range b.checks {
+			violated := c.header != c.computed
+			report.Checks++
+			report.results = append(report.results, integrityResult{check: string(c.root), violated: violated})
+			if violated {
+				report.Violations = append(report.Violations, &RootViolation{
+					Block:    number,
+					Hash:     b.hash,
+					Root:     c.root,
+					Header:   c.header,
+					Computed: c.computed,
+				})
+			}
+		}
+	}
+	return report, nil
+}
+
+func rootsRange(ctx context.Context, rc Caller, opts *RootsOptions) (uint64, uint64, error) {
+	var to uint64
+	if opts.To != nil {
+		to = *opts.To
+	} else {
+		var head hexutil.Uint64
+		if err := callWithTimeout(ctx, rc, &head, "eth_blockNumber"); err != nil {
+			return 0, 0, fmt.Errorf("failed to get the head: %w", err)
+		}
+		to = uint64(head)
+	}
+
+	var from uint64
+	switch {
+	case opts.From != nil:
+		from = *opts.From
+	default:
+		blocks := opts.Blocks
+		if blocks == 0 {
+			blocks = defaultVerifiedBlocks
+		}
+		if blocks <= to {
+			from = to - blocks + 1
+		}
+	}
+	if from > to {
+		return 0, 0, fmt.Errorf("invalid block range: %d > %d", from, to)
+	}
+	if to-from >= maxVerifiedBlocks {
+		return 0, 0, fmt.Errorf("block range too large: %d blocks, at most %d", to-from+1, maxVerifiedBlocks)
+	}
+	return from, to, nil
+}
+
+// verifyBlockRoots fetches the block and its receipts and recomputes its
+// roots.
+func verifyBlockRoots(ctx context.Context, rc Caller, number uint64) *blockRoots {
+	var raw json.RawMessage
+	if err := callWithTimeout(ctx, rc, &raw, "eth_getBlockByNumber", hexutil.Uint64(number).String(), true); err != nil {
+		return &blockRoots{err: fmt.Errorf("failed to get block: %w", err)}
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return &blockRoots{err: fmt.Errorf("block not found")}
+	}
+	var header types.Header
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return &blockRoots{err: fmt.Errorf("failed to decode header: %w", err)}
+	}
+	var body struct {
+		Hash         common.Hash          `json:"hash"`
+		Transactions []*types.Transaction `json:"transactions"`
+		Withdrawals  []*types.Withdrawal  `json:"withdrawals"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return &blockRoots{err: fmt.Errorf("failed to decode body: %w", err)}
+	}
+
+	receipts, err := blockReceipts(ctx, rc, body.Hash, body.Transactions)
+	if err != nil {
+		return &blockRoots{err: err}
+	}
+	if len(receipts) != len(body.Transactions) {
+		return &blockRoots{err: fmt.Errorf("got %d receipts for %d transactions", len(receipts), len(body.Transactions))}
+	}
+
+	var bloom types.Bloom
+	for _, r := range receipts {
+		b := types.CreateBloom(r)
+		for i := range bloom {
+			bloom[i] |= b[i]
+		}
+	}
+	b := &blockRoots{
+		hash: body.Hash.Hex(),
+		checks: []rootCheck{
+			{root: RootTransactions, header: header.TxHash.Hex(), computed: types.DeriveSha(types.Transactions(body.Transactions), trie.NewStackTrie(nil)).Hex()},
+			{root: RootReceipts, header: header.ReceiptHash.Hex(), computed: types.DeriveSha(types.Receipts(receipts), trie.NewStackTrie(nil)).Hex()},
+			{root: RootLogsBloom, header: hexutil.Encode(header.Bloom[:]), computed: hexutil.Encode(bloom[:])},
+		},
+	}
+	if header.WithdrawalsHash != nil {
+		b.checks = append(b.checks, rootCheck{
+			root:     RootWithdrawals,
+			header:   header.WithdrawalsHash.Hex(),
+			computed: types.DeriveSha(types.Withdrawals(body.Withdrawals), trie.NewStackTrie(nil)).Hex(),
+		})
+	}
+	return b
+}
+
+// blockReceipts returns the receipts of the block, one by one when the node
+// does not serve eth_getBlockReceipts.
+func blockReceipts(ctx context.Context, rc Caller, hash common.Hash, txs []*types.Transaction) ([]*types.Receipt, error) {
+	var receipts []*types.Receipt
+	err := callWithTimeout(ctx, rc, &receipts, "eth_getBlockReceipts", hash.Hex())
+	if err == nil {
+		return receipts, nil
+	}
+	if rpcErrorCode(err) != rpcErrCodeMethodNotFound {
+		return nil, fmt.Errorf("failed to get receipts: %w", err)
+	}
+
+	receipts = make([]*types.Receipt, len(txs))
+	for i, tx := range txs {
+		if err := callWithTimeout(ctx, rc, &receipts[i], "eth_getTransactionReceipt", tx.Hash().Hex()); err != nil {
+			return nil, fmt.Errorf("failed to get receipt of %s: %w", tx.Hash().Hex(), err)
+		}
+		if receipts[i] == nil {
+			return nil, fmt.Errorf("receipt of %s not found", tx.Hash().Hex())
+		}
+	}
+	return receipts, nil
+}
@@ -0,0 +1,191 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testChain serves blocks built with go-ethereum, so their roots are
+// consistent.
+type testChain struct {
+	blocks   []map[string]interface{}
+	receipts [][]*types.Receipt
+}
+
+func newTestChain(t *testing.T, n int) *testChain {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	to := common.HexToAddress(testAddress)
+
+	chain := &testChain{}
+	for number := range n {
+		txs := make([]*types.Transaction, 0)
+		receipts := make([]*types.Receipt, 0)
+		for i := range 2 {
+			tx := types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   big.NewInt(1337),
+				Nonce:     uint64(number*2 + i),
+				GasTipCap: big.NewInt(1),
+				GasFeeCap: big.NewInt(10),
+				Gas:       21000,
+				To:        &to,
+				Value:     big.NewInt(1),
+			})
+			receipt := &types.Receipt{
+				Type:              tx.Type(),
+				Status:            types.ReceiptStatusSuccessful,
+				CumulativeGasUsed: uint64(21000 * (i + 1)),
+				TxHash:            tx.Hash(),
+				GasUsed:           21000,
+				Logs: []*types.Log{{
+					Address: to,
+					Topics:  []common.Hash{common.HexToHash(testHash)},
+					Data:    []byte{byte(i)},
+					TxHash:  tx.Hash(),
+				}},
+			}
+			receipt.Bloom = types.CreateBloom(receipt)
+			txs = append(txs, tx)
+			receipts = append(receipts, receipt)
+		}
+		withdrawals := []*types.Withdrawal{{Index: uint64(number), Validator: 1, Address: to, Amount: 100}}
+		header := &types.Header{
+			Number:     big.NewInt(int64(number)),
+			Difficulty: common.Big0,
+			GasLimit:   30_000_000,
+			BaseFee:    big.NewInt(params.InitialBaseFee),
+		}
+		block := types.NewBlock(header, &types.Body{Transactions: txs, Withdrawals: withdrawals}, receipts, trie.NewStackTrie(nil))
+
+		raw, err := json.Marshal(block.Header())
+		require.NoError(t, err)
+		fields := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(raw, &fields))
+		fields["transactions"] = txs
+		fields["withdrawals"] = withdrawals
+		chain.blocks = append(chain.blocks, fields)
+		chain.receipts = append(chain.receipts, receipts)
+	}
+	return chain
+}
+
+func (tc *testChain) node() fakeNode {
+	blockIndex := func(hash string) int {
+		for i, b := range tc.blocks {
+			if b["hash"] == hash {
+				return i
+			}
+		}
+		return -1
+	}
+	return fakeNode{
+		"eth_blockNumber": value(hexutil.Uint64(len(tc.blocks) - 1).String()),
+		"eth_getBlockByNumber": func(args []interface{}) (interface{}, error) {
+			number, err := hexutil.DecodeUint64(args[0].(string))
+			if err != nil || number >= uint64(len(tc.blocks)) {
+				return nil, nil
+			}
+			return tc.blocks[number], nil
+		},
+		"eth_getBlockReceipts": func(args []interface{}) (interface{}, error) {
+			if i := blockIndex(args[0].(string)); i >= 0 {
+				return tc.receipts[i], nil
+			}
+			return nil, nil
+		},
+	}
+}
+
+func TestVerifyRoots(t *testing.T) {
+	uint64p := func(n uint64) *uint64 { return &n }
+
+	t.Run("consistent", func(t *testing.T) {
+		chain := newTestChain(t, 3)
+		report, err := VerifyRoots(context.Background(), chain.node(), &RootsOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), report.From)
+		assert.Equal(t, uint64(2), report.To)
+		assert.Equal(t, 3, report.Verified)
+		assert.Equal(t, 12, report.Checks)
+		assert.Empty(t, report.Violations)
+		assert.Empty(t, report.Errors)
+		for _, r := range report.results {
+			assert.False(t, r.violated, r.check)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		chain := newTestChain(t, 3)
+		// logs missing from the receipts change the receipts root and the bloom
+		for _, r := range chain.receipts[1] {
+			r.Logs = []*types.Log{}
+		}
+		chain.blocks[2]["transactionsRoot"] = testHash
+		chain.blocks[2]["withdrawals"] = []*types.Withdrawal{}
+
+		report, err := VerifyRoots(context.Background(), chain.node(), &RootsOptions{From: uint64p(1), To: uint64p(2)})
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Verified)
+		roots := make(map[uint64][]RootKind)
+		for _, v := range report.Violations {
+			roots[v.Block] = append(roots[v.Block], v.Root)
+			assert.NotEqual(t, v.Header, v.Computed)
+		}
+		assert.Equal(t, map[uint64][]RootKind{
+			1: {RootReceipts, RootLogsBloom},
+			2: {RootTransactions, RootWithdrawals},
+		}, roots)
+		assert.Equal(t, testHash, report.Violations[2].Header)
+	})
+
+	t.Run("receipts one by one", func(t *testing.T) {
+		chain := newTestChain(t, 2)
+		node := chain.node()
+		delete(node, "eth_getBlockReceipts")
+		node["eth_getTransactionReceipt"] = func(args []interface{}) (interface{}, error) {
+			for _, receipts := range chain.receipts {
+				for _, r := range receipts {
+					if r.TxHash.Hex() == args[0] {
+						return r, nil
+					}
+				}
+			}
+			return nil, nil
+		}
+		report, err := VerifyRoots(context.Background(), node, &RootsOptions{Blocks: 1})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), report.From)
+		assert.Equal(t, 1, report.Verified)
+		assert.Empty(t, report.Violations)
+	})
+
+	t.Run("unverifiable block", func(t *testing.T) {
+		chain := newTestChain(t, 2)
+		chain.blocks[1]["transactions"] = []interface{}{map[string]interface{}{"type": "0x7e"}}
+		report, err := VerifyRoots(context.Background(), chain.node(), &RootsOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Verified)
+		require.Len(t, report.Errors, 1)
+		assert.Equal(t, uint64(1), report.Errors[0].Block)
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		node := newTestChain(t, 1).node()
+		_, err := VerifyRoots(context.Background(), node, &RootsOptions{From: uint64p(2), To: uint64p(1)})
+		assert.Error(t, err)
+		_, err = VerifyRoots(context.Background(), node, &RootsOptions{From: uint64p(0), To: uint64p(maxVerifiedBlocks)})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,269 @@
+package integrity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// valueKind is the encoding of a JSON-RPC value, after the base types of the
+// execution-apis spec.
+type valueKind int
+
+const (
+	kindAny      valueKind = iota
+	kindQuantity           // hex unsigned integer without leading zeros
+	kindData               // hex bytes
+	kindBytes8             // 8 bytes of hex data, e.g. the block nonce
+	kindHash               // 32 bytes of hex data
+	kindAddress            // 20 bytes of hex data
+	kindBloom              // 256 bytes of hex data
+	kindString
+	kindDecimal // decimal string, e.g. net_version
+	kindBool
+	kindObject
+	kindArray
+	kindHashes // array of 32 bytes of hex data
+	kindNone   // no result expected
+)
+
+var (
+	quantityPattern = regexp.MustCompile(`^0x(0|[1-9a-f][0-9a-f]*)$`)
+	dataPattern     = regexp.MustCompile(`^0x([0-9a-f]{2})*$`)
+	bytes8Pattern   = regexp.MustCompile(`^0x[0-9a-f]{16}$`)
+	hashPattern     = regexp.MustCompile(`^0x[0-9a-f]{64}$`)
+	addressPattern  = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+	bloomPattern    = regexp.MustCompile(`^0x[0-9a-f]{512}$`)
+	decimalPattern  = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindQuantity:
+		return "quantity"
+	case kindData:
+		return "data"
+	case kindBytes8:
+		return "8 bytes"
+	case kindHash:
+		return "hash"
+	case kindAddress:
+		return "address"
+	case kindBloom:
+		return "bloom"
+	case kindString:
+		return "string"
+	case kindDecimal:
+		return "decimal string"
+	case kindBool:
+		return "bool"
+	case kindObject:
+		return "object"
+	case kindArray:
+		return "array"
+	case kindHashes:
+		return "array of hashes"
+	}
+	return "any"
+}
+
+// checkValue returns why raw is not a value of the kind, nil when it is.
+func checkValue(raw json.RawMessage, kind valueKind) error {
+	switch kind {
+	case kindAny, kindNone:
+		return nil
+	case kindBool:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return fmt.Errorf("%s is not a bool", raw)
+		}
+		return nil
+	case kindObject:
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil || obj == nil {
+			return fmt.Errorf("%s is not an object", truncate(raw))
+		}
+		return nil
+	case kindArray:
+		var arr []json.RawMessage
+		if err := json.Unmarshal(raw, &arr); err != nil || arr == nil {
+			return fmt.Errorf("%s is not an array", truncate(raw))
+		}
+		return nil
+	case kindHashes:
+		var arr []json.RawMessage
+		if err := json.Unmarshal(raw, &arr); err != nil || arr == nil {
+			return fmt.Errorf("%s is not an array", truncate(raw))
+		}
+		for i, v := range arr {
+			if err := checkValue(v, kindHash); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return fmt.Errorf("%s is not a string", truncate(raw))
+	}
+	var pattern *regexp.Regexp
+	switch kind {
+	case kindQuantity:
+		pattern = quantityPattern
+	case kindData:
+		pattern = dataPattern
+	case kindBytes8:
+		pattern = bytes8Pattern
+	case kindHash:
+		pattern = hashPattern
+	case kindAddress:
+		pattern = addressPattern
+	case kindBloom:
+		pattern = bloomPattern
+	case kindDecimal:
+		pattern = decimalPattern
+	case kindString:
+		return nil
+	}
+	if !pattern.MatchString(s) {
+		return fmt.Errorf("%q is not a valid %s", truncate(json.RawMessage(s)), kind)
+	}
+	return nil
+}
+
+func truncate(raw json.RawMessage) string {
+	if len(raw) > 80 {
+		return string(raw[:77]) + "..."
+	}
+	return string(raw)
+}
+
+type fieldSpec struct {
+	name     string
+	kind     valueKind
+	optional bool // may be missing, e.g. fields of later forks
+	nullable bool
+}
+
+// blockFields are the fields of a block without hydrated transactions.
+var blockFields = []fieldSpec{
+	{name: "number", kind: kindQuantity},
+	{name: "hash", kind: kindHash},
+	{name: "parentHash", kind: kindHash},
+	{name: "sha3Uncles", kind: kindHash},
+	{name: "miner", kind: kindAddress},
+	{name: "stateRoot", kind: kindHash},
+	{name: "transactionsRoot", kind: kindHash},
+	{name: "receiptsRoot", kind: kindHash},
+	{name: "logsBloom", kind: kindBloom},
+	{name: "difficulty", kind: kindQuantity},
+	{name: "gasLimit", kind: kindQuantity},
+	{name: "gasUsed", kind: kindQuantity},
+	{name: "timestamp", kind: kindQuantity},
+	{name: "extraData", kind: kindData},
+	{name: "mixHash", kind: kindHash},
+	{name: "nonce", kind: kindBytes8},
+	{name: "size", kind: kindQuantity},
+	{name: "transactions", kind: kindHashes},
+	{name: "uncles", kind: kindHashes},
+	{name: "baseFeePerGas", kind: kindQuantity, optional: true},
+	{name: "withdrawalsRoot", kind: kindHash, optional: true},
+	{name: "withdrawals", kind: kindArray, optional: true},
+	{name: "blobGasUsed", kind: kindQuantity, optional: true},
+	{name: "excessBlobGas", kind: kindQuantity, optional: true},
+	{name: "parentBeaconBlockRoot", kind: kindHash, optional: true},
+	{name: "requestsHash", kind: kindHash, optional: true},
+}
+
+// transactionFields are the fields of a mined transaction.
+var transactionFields = []fieldSpec{
+	{name: "hash", kind: kindHash},
+	{name: "blockHash", kind: kindHash},
+	{name: "blockNumber", kind: kindQuantity},
+	{name: "transactionIndex", kind: kindQuantity},
+	{name: "from", kind: kindAddress},
+	{name: "to", kind: kindAddress, nullable: true},
+	{name: "nonce", kind: kindQuantity},
+	{name: "value", kind: kindQuantity},
+	{name: "gas", kind: kindQuantity},
+	{name: "input", kind: kindData},
+	{name: "type", kind: kindQuantity},
+	{name: "v", kind: kindQuantity},
+	{name: "r", kind: kindQuantity},
+	{name: "s", kind: kindQuantity},
+	{name: "gasPrice", kind: kindQuantity, optional: true},
+	{name: "chainId", kind: kindQuantity, optional: true},
+	{name: "yParity", kind: kindQuantity, optional: true},
+	{name: "maxFeePerGas", kind: kindQuantity, optional: true},
+	{name: "maxPriorityFeePerGas", kind: kindQuantity, optional: true},
+	{name: "accessList", kind: kindArray, optional: true},
+	{name: "maxFeePerBlobGas", kind: kindQuantity, optional: true},
+	{name: "blobVersionedHashes", kind: kindHashes, optional: true},
+	{name: "authorizationList", kind: kindArray, optional: true},
+}
+
+var receiptFields = []fieldSpec{
+	{name: "transactionHash", kind: kindHash},
+	{name: "transactionIndex", kind: kindQuantity},
+	{name: "blockHash", kind: kindHash},
+	{name: "blockNumber", kind: kindQuantity},
+	{name: "from", kind: kindAddress},
+	{name: "to", kind: kindAddress, nullable: true},
+	{name: "cumulativeGasUsed", kind: kindQuantity},
+	{name: "gasUsed", kind: kindQuantity},
+	{name: "contractAddress", kind: kindAddress, nullable: true},
+	{name: "logs", kind: kindArray},
+	{name: "logsBloom", kind: kindBloom},
+	{name: "status", kind: kindQuantity},
+	{name: "effectiveGasPrice", kind: kindQuantity},
+	{name: "type", kind: kindQuantity},
+	{name: "blobGasUsed", kind: kindQuantity, optional: true},
+	{name: "blobGasPrice", kind: kindQuantity, optional: true},
+}
+
+var logFields = []fieldSpec{
+	{name: "address", kind: kindAddress},
+	{name: "topics", kind: kindHashes},
+	{name: "data", kind: kindData},
+	{name: "blockNumber", kind: kindQuantity},
+	{name: "blockHash", kind: kindHash},
+	{name: "transactionHash", kind: kindHash},
+	{name: "transactionIndex", kind: kindQuantity},
+	{name: "logIndex", kind: kindQuantity},
+	{name: "removed", kind: kindBool},
+}
+
+// checkFields returns the problems of the fields of the object raw, nil when
+// it matches the fields.
+func checkFields(raw json.RawMessage, fields []fieldSpec) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil || obj == nil {
+		return fmt.Errorf("%s is not an object", truncate(raw))
+	}
+
+	problems := make([]string, 0)
+	for _, f := range fields {
+		v, ok := obj[f.name]
+		switch {
+		case !ok:
+			if !f.optional {
+				problems = append(problems, fmt.Sprintf("%s is missing", f.name))
+			}
+		case string(v) == "null":
+			if !f.nullable {
+				problems = append(problems, fmt.Sprintf("%s is null", f.name))
+			}
+		default:
+			if err := checkValue(v, f.kind); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", f.name, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package loadtest
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-logr/logr"
+	"github.com/mysteryforge/gasper/k6/eth"
+)
+
+const (
+	defaultRefillInterval = 10 * time.Second
+	balanceFullCheckEvery = 10 // ticks between balance checks of every wallet
+	maxPendingRefills     = 1024
+)
+
+type refillEvent struct {
+	Wallets  int
+	Duration time.Duration
+	Failed   bool
+	Time     time.Time
+}
+
+// balanceWatcher keeps the tester wallets funded during long runs. It
+// estimates the balance of every wallet from its last checked balance minus
+// the cost of the transactions sent since, checks the balance of wallets
+// estimated below the threshold (and of every wallet now and then), takes the
+// ones really below it out of rotation and refills them in one batch.
+type balanceWatcher struct {
+	log       logr.Logger
+	wallets   *eth.WalletRegistry
+	threshold *big.Int
+	amount    *big.Int
+	interval  time.Duration
+	balance   func(ctx context.Context, addr common.Address) (*big.Int, error)
+	refill    func(ctx context.Context, wallets *eth.WalletRegistry) error
+	estimates map[common.Address]*big.Int // nil until the balance is checked
+	ticks     int
+	events    []*refillEvent
+	mu        *sync.Mutex
+}
+
+func newBalanceWatcher(log logr.Logger, wallets *eth.WalletRegistry, threshold, amount *big.Int, interval time.Duration) *balanceWatcher {
+	if interval <= 0 {
+		interval = defaultRefillInterval
+	}
+	return &balanceWatcher{
+		log:       log,
+		wallets:   wallets,
+		threshold: threshold,
+		amount:    amount,
+		interval:  interval,
+		estimates: make(map[common.Address]*big.Int),
+		events:    make([]*refillEvent, 0),
+		mu:        &sync.Mutex{},
+	}
+}
+
+func (bw *balanceWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(bw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			bw.tick(ctx)
+		}
+	}
+}
+
+// spend lowers the estimated balance of the wallet by the cost of a sent
+// transaction.
+func (bw *balanceWatcher) spend(addr common.Address, cost *big.Int) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if estimate, ok := bw.estimates[addr]; ok {
+		estimate.Sub(estimate, cost)
+	}
+}
+
+// candidates returns the wallets whose balance should be checked.
+func (bw *balanceWatcher) candidates() []common.Address {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	full := bw.ticks%balanceFullCheckEvery == 0
+	bw.ticks++
+
+	addrs := make([]common.Address, 0)
+	for addr := range bw.wallets.All() {
+		estimate, ok := bw.estimates[addr]
+		if full || !ok || estimate.Cmp(bw.threshold) < 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func (bw *balanceWatcher) tick(ctx context.Context) {
+	low := eth.NewEmptyWalletRegistry()
+	for _, addr := range bw.candidates() {
+		balance, err := bw.balance(ctx, addr)
+		if err != nil {
+			bw.log.Error(err, "failed to check wallet balance", "address", addr)
+			continue
+		}
+
+		bw.mu.Lock()
+		bw.estimates[addr] = balance
+		bw.mu.Unlock()
+
+		if balance.Cmp(bw.threshold) < 0 {
+			bw.wallets.Pause(addr)
+			if wallet, ok := bw.wallets.All()[addr]; ok {
+				low.Register(wallet)
+			}
+		}
+	}
+	if low.Ln() == 0 {
+		return
+	}
+
+	start := time.Now()
+	err := bw.refill(ctx, low)
+	event := &refillEvent{Wallets: low.Ln(), Duration: time.Since(start), Failed: err != nil, Time: start}
+	if err != nil {
+		bw.log.Error(err, "failed to refill wallets", "num_wallets", low.Ln())
+	} else {
+		bw.log.Info("Refilled wallets", "num_wallets", low.Ln(), "amount", bw.amount.String())
+	}
+
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if len(bw.events) >= maxPendingRefills {
+		bw.events = bw.events[1:]
+	}
+	bw.events = append(bw.events, event)
+	if err != nil {
+		// stay out of rotation, the balance is checked again on the next tick
+		return
+	}
+	for addr := range low.All() {
+		bw.estimates[addr].Add(bw.estimates[addr], bw.amount)
+		bw.wallets.Resume(addr)
+	}
+}
+
+// drain returns the refill events since the last call.
+func (bw *balanceWatcher) drain() []*refillEvent {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	events := bw.events
+	bw.events = make([]*refillEvent, 0)
+	return events
+}
@@ -0,0 +1,118 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/go-logr/logr"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBalances is the chain side of the balance watcher.
+type fakeBalances struct {
+	balances  map[common.Address]*big.Int
+	checked   []common.Address
+	refilled  int
+	refillErr error
+	mu        *sync.Mutex
+}
+
+func (fb *fakeBalances) balance(_ context.Context, addr common.Address) (*big.Int, error) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	fb.checked = append(fb.checked, addr)
+	return new(big.Int).Set(fb.balances[addr]), nil
+}
+
+func (fb *fakeBalances) refill(amount *big.Int) func(context.Context, *eth.WalletRegistry) error {
+	return func(_ context.Context, wallets *eth.WalletRegistry) error {
+		fb.mu.Lock()
+		defer fb.mu.Unlock()
+		if fb.refillErr != nil {
+			return fb.refillErr
+		}
+		for addr := range wallets.All() {
+			fb.balances[addr].Add(fb.balances[addr], amount)
+			fb.refilled++
+		}
+		return nil
+	}
+}
+
+func newTestBalanceWatcher(t *testing.T, balances ...int64) (*balanceWatcher, *fakeBalances, []*eth.Wallet) {
+	wallets := eth.NewEmptyWalletRegistry()
+	fb := &fakeBalances{balances: make(map[common.Address]*big.Int), mu: &sync.Mutex{}}
+	list := make([]*eth.Wallet, 0, len(balances))
+	for _, balance := range balances {
+		pk, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		w := &eth.Wallet{Address: crypto.PubkeyToAddress(pk.PublicKey), PrivateKey: pk}
+		wallets.Register(w)
+		fb.balances[w.Address] = big.NewInt(balance)
+		list = append(list, w)
+	}
+
+	amount := big.NewInt(1000)
+	bw := newBalanceWatcher(logr.Discard(), wallets, big.NewInt(100), amount, 0)
+	bw.balance = fb.balance
+	bw.refill = fb.refill(amount)
+	return bw, fb, list
+}
+
+func TestBalanceWatcher(t *testing.T) {
+	t.Run("refills wallets below threshold", func(t *testing.T) {
+		bw, fb, wallets := newTestBalanceWatcher(t, 50, 500)
+
+		bw.tick(context.Background())
+		assert.Len(t, fb.checked, 2)
+		assert.Equal(t, 1, fb.refilled)
+		assert.Equal(t, big.NewInt(1050), fb.balances[wallets[0].Address])
+		assert.False(t, bw.wallets.IsPaused(wallets[0].Address))
+
+		events := bw.drain()
+		require.Len(t, events, 1)
+		assert.Equal(t, 1, events[0].Wallets)
+		assert.False(t, events[0].Failed)
+		assert.Empty(t, bw.drain())
+	})
+
+	t.Run("checks only wallets estimated below threshold", func(t *testing.T) {
+		bw, fb, wallets := newTestBalanceWatcher(t, 500, 500)
+		bw.tick(context.Background())
+		fb.checked = nil
+
+		bw.tick(context.Background())
+		assert.Empty(t, fb.checked)
+
+		// spend lowers the estimate below the threshold, so it is checked
+		fb.balances[wallets[1].Address] = big.NewInt(20)
+		bw.spend(wallets[1].Address, big.NewInt(480))
+		bw.tick(context.Background())
+		assert.Equal(t, []common.Address{wallets[1].Address}, fb.checked)
+		assert.Equal(t, 1, fb.refilled)
+	})
+
+	t.Run("keeps wallets out of rotation when refill fails", func(t *testing.T) {
+		bw, fb, wallets := newTestBalanceWatcher(t, 50)
+		fb.refillErr = fmt.Errorf("sponsors are dry")
+
+		bw.tick(context.Background())
+		assert.True(t, bw.wallets.IsPaused(wallets[0].Address))
+		assert.Nil(t, bw.wallets.GetAvailableWallet())
+
+		events := bw.drain()
+		require.Len(t, events, 1)
+		assert.True(t, events[0].Failed)
+
+		fb.refillErr = nil
+		bw.tick(context.Background())
+		assert.False(t, bw.wallets.IsPaused(wallets[0].Address))
+	})
+}
@@ -0,0 +1,310 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-logr/logr"
+	"github.com/mysteryforge/gasper/k6/eth"
+)
+
+const (
+	maxPendingBlocks = 1024
+	reorgWindow      = 64
+
+	blockPollInterval           = 500 * time.Millisecond
+	subscribedBlockPollInterval = 5 * time.Second
+)
+
+// trackedBlock is a block waiting to be reported, with the interval in ms
+// since its parent.
+type trackedBlock struct {
+	Block        *eth.SlimBlock
+	Arrival      time.Time
+	IntervalMili uint64
+}
+
+// reorgEvent is a change of the canonical chain, Dropped holds the blocks
+// which were already reported and are no longer canonical.
+type reorgEvent struct {
+	Number  uint64 // first replaced block
+	Depth   uint64
+	OldHead common.Hash
+	NewHead common.Hash
+	Dropped []*eth.SlimBlock
+	Time    time.Time
+}
+
+// blockTracker walks every block from the last tracked one to the head in the
+// background and buffers them until ReportBlockMetrics drains them, so no
+// block is skipped however rarely the metrics are reported. New heads come
+// from the newHeads subscription when the transport supports it and from
+// polling eth_blockNumber otherwise. The hashes of the last reorgWindow
+// blocks are kept to find the common ancestor when the chain reorganizes.
+type blockTracker struct {
+	log        logr.Logger
+	fetchHead  func(ctx context.Context) (uint64, error)
+	fetchBlock func(ctx context.Context, number uint64) (*eth.SlimBlock, error)
+	heads      *headSubscriber
+
+	window      []*eth.SlimBlock
+	lastArrival time.Time
+
+	blocks    []*trackedBlock
+	reorgs    []*reorgEvent
+	listeners map[int]func(*trackedBlock)
+	nextID    int
+	mu        *sync.Mutex
+}
+
+func newBlockTracker(ethClient *eth.Client, start *eth.SlimBlock, log logr.Logger) *blockTracker {
+	bt := &blockTracker{
+		log:       log,
+		fetchHead: ethClient.Ec.BlockNumber,
+		fetchBlock: func(ctx context.Context, number uint64) (*eth.SlimBlock, error) {
+			return ethClient.SlimBlockByNumber(ctx, new(big.Int).SetUint64(number))
+		},
+		window:      []*eth.SlimBlock{start},
+		lastArrival: time.Now(),
+		blocks:      make([]*trackedBlock, 0),
+		reorgs:      make([]*reorgEvent, 0),
+		listeners:   make(map[int]func(*trackedBlock)),
+		mu:          &sync.Mutex{},
+	}
+	if ethClient.SupportsSubscriptions() {
+		bt.heads = newHeadSubscriber(ethClient, log)
+	}
+	return bt
+}
+
+func (bt *blockTracker) run(ctx context.Context) {
+	interval := blockPollInterval
+	var notify chan struct{}
+	if bt.heads != nil {
+		go bt.heads.run(ctx)
+		notify = bt.heads.notify
+		// still poll, in case the subscription is down
+		interval = subscribedBlockPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var (
+			head     uint64
+			arrivals map[common.Hash]time.Time
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-notify:
+			heads := bt.heads.drain()
+			arrivals = make(map[common.Hash]time.Time, len(heads))
+			for _, h := range heads {
+				arrivals[h.Header.Hash()] = h.Arrival
+				head = max(head, h.Header.Number.Uint64())
+			}
+		case <-ticker.C:
+			var err error
+			head, err = bt.fetchHead(ctx)
+			if err != nil {
+				bt.log.Error(err, "failed to get block number")
+				continue
+			}
+		}
+
+		if err := bt.walk(ctx, head, arrivals); err != nil && ctx.Err() == nil {
+			bt.log.Error(err, "failed to track blocks", "head", head)
+		}
+	}
+}
+
+func (bt *blockTracker) last() *eth.SlimBlock {
+	return bt.window[len(bt.window)-1]
+}
+
+// walk tracks every block after the last tracked one up to head. arrivals
+// holds the arrival time of subscribed heads, other blocks arrive now.
+func (bt *blockTracker) walk(ctx context.Context, head uint64, arrivals map[common.Hash]time.Time) error {
+	for bt.last().Number.Uint64() < head {
+		last := bt.last()
+		number := last.Number.Uint64() + 1
+		block, err := bt.fetchBlock(ctx, number)
+		if errors.Is(err, ethereum.NotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get block %d: %w", number, err)
+		}
+
+		if block.ParentHash != last.Hash {
+			if err := bt.rewind(ctx, block); err != nil {
+				return err
+			}
+			// walk the new canonical chain again from the common ancestor
+			continue
+		}
+
+		arrival, ok := arrivals[block.Hash]
+		if !ok {
+			arrival = time.Now()
+		}
+		bt.track(block, last, arrival)
+	}
+	return nil
+}
+
+// rewind drops the tracked blocks which are no longer canonical, down to the
+// common ancestor with the chain of newHead, and records the reorg.
+func (bt *blockTracker) rewind(ctx context.Context, newHead *eth.SlimBlock) error {
+	oldHead := bt.last()
+	dropped := make([]*eth.SlimBlock, 0)
+	for {
+		last := bt.last()
+		canonical, err := bt.fetchBlock(ctx, last.Number.Uint64())
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return fmt.Errorf("failed to get block %d: %w", last.Number.Uint64(), err)
+		}
+		if canonical != nil && canonical.Hash == last.Hash {
+			break
+		}
+
+		dropped = append(dropped, last)
+		if len(bt.window) > 1 {
+			bt.window = bt.window[:len(bt.window)-1]
+			continue
+		}
+
+		// deeper than the window, restart from the canonical block
+		if canonical == nil {
+			return fmt.Errorf("reorg deeper than %d blocks below block %d", reorgWindow, last.Number.Uint64())
+		}
+		bt.log.Info("reorg deeper than the tracked window", "window", reorgWindow)
+		bt.window = bt.window[:0]
+		bt.track(canonical, last, time.Now())
+		break
+	}
+	if len(dropped) == 0 {
+		// the tracked head is still canonical, newHead came from a chain the
+		// node already switched away from
+		return fmt.Errorf("block %d does not follow the canonical block %d", newHead.Number.Uint64(), oldHead.Number.Uint64())
+	}
+
+	bt.recordReorg(&reorgEvent{
+		Number:  dropped[len(dropped)-1].Number.Uint64(),
+		Depth:   uint64(len(dropped)),
+		OldHead: oldHead.Hash,
+		NewHead: newHead.Hash,
+		Dropped: dropped,
+		Time:    time.Now(),
+	})
+	return nil
+}
+
+// recordReorg removes the dropped blocks which were not reported yet, and
+// keeps the reported ones in the event.
+func (bt *blockTracker) recordReorg(reorg *reorgEvent) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	dropped := make(map[common.Hash]struct{}, len(reorg.Dropped))
+	for _, b := range reorg.Dropped {
+		dropped[b.Hash] = struct{}{}
+	}
+	pending := make(map[common.Hash]struct{}, len(bt.blocks))
+	blocks := make([]*trackedBlock, 0, len(bt.blocks))
+	for _, tb := range bt.blocks {
+		if _, ok := dropped[tb.Block.Hash]; ok {
+			pending[tb.Block.Hash] = struct{}{}
+			continue
+		}
+		blocks = append(blocks, tb)
+	}
+	bt.blocks = blocks
+
+	reported := make([]*eth.SlimBlock, 0, len(reorg.Dropped))
+	for _, b := range reorg.Dropped {
+		if _, ok := pending[b.Hash]; !ok {
+			reported = append(reported, b)
+		}
+	}
+	reorg.Dropped = reported
+	bt.reorgs = append(bt.reorgs, reorg)
+}
+
+func (bt *blockTracker) track(block *eth.SlimBlock, parent *eth.SlimBlock, arrival time.Time) {
+	tb := &trackedBlock{
+		Block:        block,
+		Arrival:      arrival,
+		IntervalMili: blockInterval(block, parent, arrival, bt.lastArrival),
+	}
+
+	bt.window = append(bt.window, block)
+	if len(bt.window) > reorgWindow {
+		bt.window = bt.window[len(bt.window)-reorgWindow:]
+	}
+	bt.lastArrival = arrival
+
+	bt.mu.Lock()
+	if len(bt.blocks) >= maxPendingBlocks {
+		bt.log.Info("dropping oldest tracked block, block metrics are not reported often enough", "number", bt.blocks[0].Block.Number)
+		bt.blocks = bt.blocks[1:]
+	}
+	bt.blocks = append(bt.blocks, tb)
+	listeners := make([]func(*trackedBlock), 0, len(bt.listeners))
+	for _, fn := range bt.listeners {
+		listeners = append(listeners, fn)
+	}
+	bt.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(tb)
+	}
+}
+
+// onBlock calls fn with every tracked block, on the tracker goroutine, until
+// the returned func is called. fn must not block.
+func (bt *blockTracker) onBlock(fn func(*trackedBlock)) func() {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	id := bt.nextID
+	bt.nextID++
+	bt.listeners[id] = fn
+	return func() {
+		bt.mu.Lock()
+		defer bt.mu.Unlock()
+		delete(bt.listeners, id)
+	}
+}
+
+// drain returns the tracked blocks in chain order and the reorgs since the
+// last drain, and clears the buffers.
+func (bt *blockTracker) drain() ([]*trackedBlock, []*reorgEvent) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	blocks, reorgs := bt.blocks, bt.reorgs
+	bt.blocks = make([]*trackedBlock, 0)
+	bt.reorgs = make([]*reorgEvent, 0)
+	return blocks, reorgs
+}
+
+// blockInterval returns the ms between parent and block from their
+// timestamps. Blocks produced within the same second share a timestamp, for
+// them the interval between the arrival times is used.
+func blockInterval(block *eth.SlimBlock, parent *eth.SlimBlock, arrival time.Time, parentArrival time.Time) uint64 {
+	if block.Timestamp > parent.Timestamp {
+		return (block.Timestamp - parent.Timestamp) * 1000
+	}
+	if arrival.After(parentArrival) {
+		return uint64(arrival.Sub(parentArrival).Milliseconds())
+	}
+	return 0
+}
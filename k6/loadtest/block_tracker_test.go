@@ -0,0 +1,217 @@
+package loadtest
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-logr/logr"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChain struct {
+	blocks map[uint64]*eth.SlimBlock
+}
+
+func newFakeChain(n uint64, fork byte) *fakeChain {
+	fc := &fakeChain{blocks: make(map[uint64]*eth.SlimBlock)}
+	for i := uint64(0); i <= n; i++ {
+		fc.set(i, fork)
+	}
+	return fc
+}
+
+// set replaces block number i with a block of the given fork, linked to the
+// current parent.
+func (fc *fakeChain) set(i uint64, fork byte) {
+	block := &eth.SlimBlock{
+		Number:    new(big.Int).SetUint64(i),
+		Hash:      common.Hash{fork, byte(i)},
+		Timestamp: 1000 + i*2,
+		GasUsed:   i,
+	}
+	if parent, ok := fc.blocks[i-1]; ok && i > 0 {
+		block.ParentHash = parent.Hash
+	}
+	fc.blocks[i] = block
+}
+
+func (fc *fakeChain) tracker(start uint64) *blockTracker {
+	return &blockTracker{
+		log: logr.Discard(),
+		fetchBlock: func(_ context.Context, number uint64) (*eth.SlimBlock, error) {
+			block, ok := fc.blocks[number]
+			if !ok {
+				return nil, ethereum.NotFound
+			}
+			return block, nil
+		},
+		window:      []*eth.SlimBlock{fc.blocks[start]},
+		lastArrival: time.Now(),
+		blocks:      make([]*trackedBlock, 0),
+		reorgs:      make([]*reorgEvent, 0),
+		listeners:   make(map[int]func(*trackedBlock)),
+		mu:          &sync.Mutex{},
+	}
+}
+
+func (bt *blockTracker) drainBlocks() []*trackedBlock {
+	blocks, _ := bt.drain()
+	return blocks
+}
+
+func trackedNumbers(blocks []*trackedBlock) []uint64 {
+	res := make([]uint64, 0, len(blocks))
+	for _, tb := range blocks {
+		res = append(res, tb.Block.Number.Uint64())
+	}
+	return res
+}
+
+func TestBlockTrackerWalk(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("every block up to head", func(t *testing.T) {
+		fc := newFakeChain(10, 0)
+		bt := fc.tracker(2)
+		require.NoError(t, bt.walk(ctx, 6, nil))
+		require.NoError(t, bt.walk(ctx, 8, nil))
+
+		blocks := bt.drainBlocks()
+		assert.Equal(t, []uint64{3, 4, 5, 6, 7, 8}, trackedNumbers(blocks))
+		for _, tb := range blocks {
+			assert.Equal(t, uint64(2000), tb.IntervalMili)
+		}
+		assert.Empty(t, bt.drainBlocks())
+	})
+
+	t.Run("head not yet available", func(t *testing.T) {
+		fc := newFakeChain(4, 0)
+		bt := fc.tracker(2)
+		require.NoError(t, bt.walk(ctx, 6, nil))
+		assert.Equal(t, []uint64{3, 4}, trackedNumbers(bt.drainBlocks()))
+	})
+
+	t.Run("subscribed arrival time", func(t *testing.T) {
+		fc := newFakeChain(4, 0)
+		bt := fc.tracker(2)
+		arrival := time.Now().Add(time.Second)
+		require.NoError(t, bt.walk(ctx, 4, map[common.Hash]time.Time{fc.blocks[4].Hash: arrival}))
+		blocks := bt.drainBlocks()
+		require.Len(t, blocks, 2)
+		assert.Equal(t, arrival, blocks[1].Arrival)
+	})
+
+	t.Run("reorged parent is tracked again", func(t *testing.T) {
+		fc := newFakeChain(5, 0)
+		bt := fc.tracker(2)
+		require.NoError(t, bt.walk(ctx, 5, nil))
+		assert.Equal(t, []uint64{3, 4, 5}, trackedNumbers(bt.drainBlocks()))
+
+		fc.set(5, 1)
+		fc.set(6, 1)
+		require.NoError(t, bt.walk(ctx, 6, nil))
+		blocks, reorgs := bt.drain()
+		assert.Equal(t, []uint64{5, 6}, trackedNumbers(blocks))
+		assert.Equal(t, fc.blocks[5].Hash, blocks[0].Block.Hash)
+		assert.Equal(t, uint64(2000), blocks[0].IntervalMili)
+
+		require.Len(t, reorgs, 1)
+		assert.Equal(t, uint64(5), reorgs[0].Number)
+		assert.Equal(t, uint64(1), reorgs[0].Depth)
+		require.Len(t, reorgs[0].Dropped, 1)
+		assert.Equal(t, common.Hash{0, 5}, reorgs[0].Dropped[0].Hash)
+	})
+}
+
+func TestBlockTrackerReorg(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("depth from common ancestor", func(t *testing.T) {
+		fc := newFakeChain(8, 0)
+		bt := fc.tracker(2)
+		require.NoError(t, bt.walk(ctx, 8, nil))
+		bt.drain()
+
+		for i := uint64(6); i <= 9; i++ {
+			fc.set(i, 1)
+		}
+		require.NoError(t, bt.walk(ctx, 9, nil))
+		blocks, reorgs := bt.drain()
+		assert.Equal(t, []uint64{6, 7, 8, 9}, trackedNumbers(blocks))
+		require.Len(t, reorgs, 1)
+		assert.Equal(t, uint64(6), reorgs[0].Number)
+		assert.Equal(t, uint64(3), reorgs[0].Depth)
+		assert.Len(t, reorgs[0].Dropped, 3)
+		assert.Equal(t, common.Hash{0, 8}, reorgs[0].OldHead)
+		assert.Equal(t, common.Hash{1, 9}, reorgs[0].NewHead)
+	})
+
+	t.Run("pending dropped blocks are not reported", func(t *testing.T) {
+		fc := newFakeChain(6, 0)
+		bt := fc.tracker(2)
+		require.NoError(t, bt.walk(ctx, 6, nil))
+
+		fc.set(5, 1)
+		fc.set(6, 1)
+		fc.set(7, 1)
+		require.NoError(t, bt.walk(ctx, 7, nil))
+		blocks, reorgs := bt.drain()
+		assert.Equal(t, []uint64{3, 4, 5, 6, 7}, trackedNumbers(blocks))
+		assert.Equal(t, common.Hash{1, 5}, blocks[2].Block.Hash)
+		require.Len(t, reorgs, 1)
+		assert.Equal(t, uint64(2), reorgs[0].Depth)
+		assert.Empty(t, reorgs[0].Dropped)
+	})
+
+	t.Run("stale fork block", func(t *testing.T) {
+		fc := newFakeChain(4, 0)
+		bt := fc.tracker(2)
+		require.NoError(t, bt.walk(ctx, 4, nil))
+		bt.drain()
+
+		// block 5 of a fork whose parent is not tracked, while 4 is canonical
+		fc.blocks[5] = &eth.SlimBlock{Number: big.NewInt(5), Hash: common.Hash{1, 5}, ParentHash: common.Hash{1, 4}}
+		assert.Error(t, bt.walk(ctx, 5, nil))
+		_, reorgs := bt.drain()
+		assert.Empty(t, reorgs)
+		assert.Equal(t, uint64(4), bt.last().Number.Uint64())
+
+		fc.set(5, 0)
+		require.NoError(t, bt.walk(ctx, 5, nil))
+		assert.Equal(t, []uint64{5}, trackedNumbers(bt.drainBlocks()))
+	})
+
+	t.Run("deeper than the window", func(t *testing.T) {
+		fc := newFakeChain(reorgWindow+4, 0)
+		bt := fc.tracker(0)
+		require.NoError(t, bt.walk(ctx, reorgWindow+4, nil))
+		bt.drain()
+
+		for i := uint64(0); i <= reorgWindow+5; i++ {
+			fc.set(i, 1)
+		}
+		require.NoError(t, bt.walk(ctx, reorgWindow+5, nil))
+		blocks, reorgs := bt.drain()
+		require.Len(t, reorgs, 1)
+		assert.Equal(t, uint64(reorgWindow), reorgs[0].Depth)
+		assert.Equal(t, uint64(5), reorgs[0].Number)
+		assert.Equal(t, []uint64{5, 6}, trackedNumbers(blocks)[:2])
+		assert.Equal(t, uint64(reorgWindow+5), blocks[len(blocks)-1].Block.Number.Uint64())
+	})
+}
+
+func TestBlockInterval(t *testing.T) {
+	now := time.Now()
+	parent := &eth.SlimBlock{Timestamp: 100}
+
+	assert.Equal(t, uint64(3000), blockInterval(&eth.SlimBlock{Timestamp: 103}, parent, now, now))
+	assert.Equal(t, uint64(250), blockInterval(&eth.SlimBlock{Timestamp: 100}, parent, now.Add(250*time.Millisecond), now))
+	assert.Equal(t, uint64(0), blockInterval(&eth.SlimBlock{Timestamp: 100}, parent, now, now))
+}
@@ -3,16 +3,15 @@ package loadtest
 import (
 	"context"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"math/big"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/cockroachdb/pebble"
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -32,6 +31,13 @@ type Client interface {
 	SendTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error)
 	SendERC20Transaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error)
 	SendERC721Transaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error)
+	SendBlobTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error)
+	SendSetCodeTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error)
+	SendDelegatedTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error)
+	Dispatch(vu modules.VU, metrics *EthMetrics, txType eth.TransactionType, duration time.Duration, targetTPS uint64, options ...TransactionOption) (*DispatchResult, error)
+	DispatchGas(vu modules.VU, metrics *EthMetrics, duration time.Duration, targetMGas float64, options ...TransactionOption) (*DispatchResult, error)
+	SweepWallets(vu modules.VU, metrics *EthMetrics) (*SweepResult, error)
+	NonceStates(vu modules.VU, metrics *EthMetrics) ([]*eth.NonceState, error)
 
 	// Contract related
 	DeployContract(vu modules.VU, params *eth.DeployContractParams) (*common.Address, *common.Hash, *common.Address, error)
@@ -48,6 +54,9 @@ type Client interface {
 	Close()
 
 	UID() string
+	EthClient() *eth.Client
+	StateAccounts() *StateAccounts
+	Ledger(ctx context.Context) (*LedgerState, error)
 }
 
 type DefaultClient struct {
@@ -64,14 +73,39 @@ type DefaultClient struct {
 	erc721            *eth.ERC721
 	batchFunder       *eth.BatchFunder
 	deployedContracts map[common.Address]*bind.BoundContract
-	reportBlock       *ReportBlock
+	blocks            *blockTracker
+	timestampDelta    int64
+	targetTPS         uint64
+	dispatchStages    []dispatchStage
+	targetMGas        float64
+	gasMix            []gasMixEntry
 	db                *eth.PebbleDb
 	latestGasPrice    *eth.AtomicBigInt
 	latestGasTip      *eth.AtomicBigInt
 	txPool            *eth.PoolStatus
 	txPoolRateLimiter *eth.TxPoolRateLimiter
+	mineLatency       *mineLatency
+	walletStore       *eth.WalletStore
+	generatedWallets  *eth.WalletRegistry
+	balances          *balanceWatcher
+	nonces            *eth.NonceManager
+	receipts          *receiptReconciler
+	journal           *eth.Journal
+	journalExport     *journalExportConfig
+	ledger            *ledger
+	sweepOnClose      bool
+	sweepERC20        bool
+	dbTasks           *dbTasks
+	cancel            context.CancelFunc
+	background        *sync.WaitGroup
+	closeOnce         *sync.Once
 	isLegacy          bool
 	sharedWallets     map[string]*eth.Wallet
+	blobSidecars      *eth.BlobSidecarCache
+	delegationAddress *common.Address
+	delegated         *eth.TargetAddresses
+	delegatedSet      map[common.Address]struct{}
+	delegatedMux      *sync.Mutex
 }
 
 func NewClient(ctx context.Context, cfg *clientConfig, scenarioUID string, db *eth.PebbleDb, log logr.Logger) (*DefaultClient, error) {
@@ -89,8 +123,35 @@ func NewClient(ctx context.Context, cfg *clientConfig, scenarioUID string, db *e
 		latestGasTip:      eth.NewAtomicBigInt(nil),
 		txPool:            &eth.PoolStatus{},
 		sharedWallets:     make(map[string]*eth.Wallet),
+		blobSidecars:      eth.NewBlobSidecarCache(),
+		delegatedSet:      make(map[common.Address]struct{}),
+		delegatedMux:      &sync.Mutex{},
+		targetTPS:         cfg.TargetTPS,
+		dispatchStages:    cfg.Stages,
+		targetMGas:        cfg.TargetMGas,
+		gasMix:            cfg.GasMix,
+		sweepOnClose:      cfg.SweepOnClose,
+		sweepERC20:        cfg.SweepERC20,
+		journalExport:     cfg.JournalExport,
+		background:        &sync.WaitGroup{},
+		closeOnce:         &sync.Once{},
 	}
 	c.log = log.WithValues("uid", c.uid)
+	parent := ctx
+	ctx, c.cancel = context.WithCancel(ctx)
+	if db != nil {
+		db.Retain()
+		// generated wallets are only stored when a later run or the sweep
+		// may need their keys
+		if cfg.ReuseWallets || cfg.SweepOnClose {
+			if cfg.WalletPassphrase == "" {
+				c.log.Info("Storing generated wallet keys unencrypted, set wallet_passphrase to encrypt them", "db_path", cfg.DBPath)
+			}
+			c.walletStore = eth.NewWalletStore(db, c.uid, cfg.WalletPassphrase)
+		}
+		c.journal = eth.NewJournal(db)
+		c.dbTasks = newDBTasks()
+	}
 
 	var err error
 
@@ -112,29 +173,47 @@ func NewClient(ctx context.Context, cfg *clientConfig, scenarioUID string, db *e
 		return nil, err
 	}
 
-	c.firstBlockNumber, err = c.ethClient.Ec.BlockNumber(ctx)
+	if cfg.DelegationAddress != "" {
+		delegationAddress := common.HexToAddress(cfg.DelegationAddress)
+		c.delegationAddress = &delegationAddress
+	}
+	c.delegated, err = eth.NewTargetAddresses(nil, 0)
 	if err != nil {
 		return nil, err
 	}
-	c.reportBlock = &ReportBlock{
-		Number:        c.firstBlockNumber,
-		TimestampMili: uint64(time.Now().UnixMilli()),
-		Timestamp:     uint64(time.Now().Unix()),
+
+	c.firstBlockNumber, err = c.ethClient.Ec.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
 	}
-	latestBlock, err := c.ethClient.Ec.HeaderByNumber(ctx, new(big.Int).SetUint64(c.firstBlockNumber))
+	latestBlock, err := c.ethClient.SlimBlockByNumber(ctx, new(big.Int).SetUint64(c.firstBlockNumber))
 	if err != nil {
 		return nil, err
 	}
-	timestampDelta := time.Since(time.Unix(int64(latestBlock.Time), 0)).Milliseconds()
+	timestampDelta := time.Since(time.Unix(int64(latestBlock.Timestamp), 0)).Milliseconds()
 	if timestampDelta > 10_000 {
-		c.reportBlock.TimestampDelta = timestampDelta
+		c.timestampDelta = timestampDelta
+	}
+	c.blocks = newBlockTracker(c.ethClient, latestBlock, c.log)
+	c.goBackground(func() { c.blocks.run(ctx) })
+	if c.journal != nil {
+		c.blocks.onBlock(func(tb *trackedBlock) {
+			c.dbTasks.run(func() { c.journalInclusions(tb) })
+		})
+	}
+
+	if cfg.ReceiptReconciler != nil && c.db != nil {
+		c.setupReceiptReconciler(ctx, cfg.ReceiptReconciler)
 	}
 
 	if err := c.setupWalletsAndFund(
 		ctx,
 		cfg.PrivateKeys,
 		cfg.Wallets,
+		cfg.HDWallets,
 		cfg.NumWallets,
+		cfg.ReuseWallets,
+		&cfg.TopUpThreshold,
 		cfg.BatchFunderAddress,
 		&cfg.FundAmount,
 		cfg.MinGasPrice,
@@ -142,6 +221,17 @@ func NewClient(ctx context.Context, cfg *clientConfig, scenarioUID string, db *e
 		return nil, err
 	}
 
+	c.nonces = eth.NewNonceManager(c.ethClient.Ec, c.fillNonceGap)
+	if cfg.NonceCheckInterval >= 0 {
+		c.goBackground(func() { c.nonces.Run(ctx, cfg.NonceCheckInterval) })
+	}
+
+	if cfg.RefillThreshold.Sign() > 0 {
+		if err := c.setupBalanceWatcher(ctx, cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	if cfg.ERC20 {
 		if err := c.setupERC20(ctx, cfg.ERC20Address, cfg.ERC20MintAmount, cfg.MinGasPrice); err != nil {
 			return nil, err
@@ -154,11 +244,17 @@ func NewClient(ctx context.Context, cfg *clientConfig, scenarioUID string, db *e
 		}
 	}
 
+	if cfg.Ledger {
+		if err := c.setupLedger(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := c.setupLatestGas(ctx, cfg.MinGasPrice); err != nil {
 		return nil, err
 	}
 
-	go func() {
+	c.goBackground(func() {
 		ticker := time.NewTicker(100 * time.Millisecond)
 		for {
 			select {
@@ -170,14 +266,27 @@ func NewClient(ctx context.Context, cfg *clientConfig, scenarioUID string, db *e
 				}
 			}
 		}
-	}()
+	})
 
+	adaptive := cfg.AdaptiveRateLimit || cfg.RateController != nil
 	if cfg.RateLimite != nil && *cfg.RateLimite > 0 {
-		c.txPoolRateLimiter = eth.NewTxPoolRateLimiter(*cfg.RateLimite)
+		controller, err := cfg.RateController.controller()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create rate controller: %w", err)
+		}
+		c.txPoolRateLimiter = eth.NewControlledRateLimiter(*cfg.RateLimite, controller, cfg.RateController.maxRate())
+
+		if _, ok := controller.(*eth.LatencyController); ok && adaptive {
+			c.mineLatency = newMineLatency()
+			c.blocks.onBlock(func(tb *trackedBlock) {
+				c.mineLatency.included(tb.Block.Transactions, tb.Arrival)
+			})
+		}
 	}
 
-	go func() {
-		ticker := time.NewTicker(2 * time.Second)
+	c.goBackground(func() {
+		interval := cfg.RateController.interval()
+		ticker := time.NewTicker(interval)
 		for {
 			select {
 			case <-ctx.Done():
@@ -186,17 +295,28 @@ func NewClient(ctx context.Context, cfg *clientConfig, scenarioUID string, db *e
 				if err := c.updateTxPoolStatus(ctx); err != nil {
 					c.log.Error(err, "failed to get tx pool status")
 				}
-				if c.txPoolRateLimiter != nil && cfg.AdaptiveRateLimit {
-					c.txPoolRateLimiter.UpdateTxPoolSize(uint64(c.txPool.Pending + c.txPool.Queued))
+				if c.txPoolRateLimiter != nil && adaptive {
+					feedback := eth.RateFeedback{
+						TxPoolSize: uint64(c.txPool.Pending + c.txPool.Queued),
+						Interval:   interval,
+					}
+					if c.mineLatency != nil {
+						feedback.TimeToMine = c.mineLatency.average()
+					}
+					c.txPoolRateLimiter.Update(feedback)
 				}
 			}
 		}
-	}()
+	})
 
-	// listen for context cancellation and close the client
+	// listen for the cancellation of the parent context and close the client,
+	// until the client is closed
 	go func() {
-		<-ctx.Done()
-		c.Close()
+		select {
+		case <-parent.Done():
+			c.Close()
+		case <-ctx.Done():
+		}
 	}()
 
 	return c, nil
@@ -231,7 +351,10 @@ func (c *DefaultClient) setupWalletsAndFund(
 	ctx context.Context,
 	privateKeys []string,
 	wallets []string,
+	hdWallets *hdWalletConfig,
 	numNewWallets uint64,
+	reuseWallets bool,
+	topUpThreshold *big.Int,
 	batchFunderAddress string,
 	fundAmount *big.Int,
 	minGasPrice uint64,
@@ -254,36 +377,196 @@ func (c *DefaultClient) setupWalletsAndFund(
 		}
 	}
 
+	if hdWallets != nil {
+		if c.sponsors, err = c.deriveWallets(ctx, c.sponsors, hdWallets, hdWallets.Sponsors); err != nil {
+			return err
+		}
+		if c.testers, err = c.deriveWallets(ctx, c.testers, hdWallets, hdWallets.Testers); err != nil {
+			return err
+		}
+	}
+
 	if numNewWallets > 0 {
 		if c.testers == nil {
 			c.testers = eth.NewEmptyWalletRegistry()
 		}
-		if err := c.testers.GenerateAndStore(ctx, numNewWallets); err != nil {
+		toFund, err := c.generateWallets(ctx, numNewWallets, reuseWallets, topUpThreshold)
+		if err != nil {
 			return err
 		}
 
-		if batchFunderAddress == "" {
-			c.batchFunder, err = eth.DeployNewBatchFunder(ctx, c.ethClient, c.sponsors, minGasPrice)
-			if err != nil {
-				return err
-			}
-		} else {
-			batchFunderAddress := common.HexToAddress(batchFunderAddress)
-			c.batchFunder, err = eth.InitExistingBatchFunder(c.ethClient, batchFunderAddress)
-			if err != nil {
+		if err := c.setupBatchFunder(ctx, batchFunderAddress, minGasPrice); err != nil {
+			return err
+		}
+		if toFund.Ln() > 0 {
+			if err := c.batchFunder.FundWallets(ctx, c.ethClient, c.sponsors, toFund, fundAmount); err != nil {
 				return err
 			}
 		}
-		c.log.Info("Batch funder contract", "address", c.batchFunder.Address.Hex())
-		if err := c.batchFunder.FundWallets(ctx, c.ethClient, c.sponsors, c.testers, fundAmount); err != nil {
+		c.log.Info("Funded wallets", "num_wallets", toFund.Ln(), "fund_amount", fundAmount.String())
+	}
+
+	return nil
+}
+
+func (c *DefaultClient) setupBatchFunder(ctx context.Context, address string, minGasPrice uint64) error {
+	var err error
+	if address == "" {
+		c.batchFunder, err = eth.DeployNewBatchFunder(ctx, c.ethClient, c.sponsors, minGasPrice)
+		if err != nil {
+			return err
+		}
+	} else {
+		c.batchFunder, err = eth.InitExistingBatchFunder(c.ethClient, common.HexToAddress(address))
+		if err != nil {
+			return err
+		}
+	}
+	c.log.Info("Batch funder contract", "address", c.batchFunder.Address.Hex())
+	return nil
+}
+
+// setupBalanceWatcher starts refilling tester wallets with fundAmount once
+// they fall below threshold.
+func (c *DefaultClient) setupBalanceWatcher(ctx context.Context, cfg *clientConfig) error {
+	if c.testers == nil || c.testers.Ln() == 0 {
+		return nil
+	}
+	if c.batchFunder == nil {
+		setupCtx, cancel := context.WithTimeout(ctx, eth.WalletsTimeout)
+		defer cancel()
+		if err := c.setupBatchFunder(setupCtx, cfg.BatchFunderAddress, cfg.MinGasPrice); err != nil {
 			return err
 		}
-		c.log.Info("Funded wallets", "num_wallets", c.testers.Ln(), "fund_amount", fundAmount.String())
 	}
 
+	fundAmount := new(big.Int).Set(&cfg.FundAmount)
+	c.balances = newBalanceWatcher(c.log, c.testers, &cfg.RefillThreshold, fundAmount, cfg.RefillInterval)
+	c.balances.balance = func(ctx context.Context, addr common.Address) (*big.Int, error) {
+		return c.ethClient.Ec.BalanceAt(ctx, addr, nil)
+	}
+	c.balances.refill = func(ctx context.Context, wallets *eth.WalletRegistry) error {
+		ctx, cancel := context.WithTimeout(ctx, eth.WalletsTimeout)
+		defer cancel()
+		return c.batchFunder.FundWallets(ctx, c.ethClient, c.sponsors, wallets, fundAmount)
+	}
+	c.goBackground(func() { c.balances.run(ctx) })
+	return nil
+}
+
+// setupReceiptReconciler starts checking the receipts of the transactions
+// sent without confirmation, outcomes are stored in the database.
+func (c *DefaultClient) setupReceiptReconciler(ctx context.Context, cfg *receiptReconcilerConfig) {
+	c.receipts = newReceiptReconciler(c.log, cfg, c.firstBlockNumber)
+	c.receipts.receipts = func(ctx context.Context, hashes []common.Hash) ([]*types.Receipt, error) {
+		return fetchReceipts(ctx, c.ethClient.Rc, hashes)
+	}
+	c.receipts.store = c.journal.Receipt
+	c.blocks.onBlock(func(tb *trackedBlock) {
+		c.receipts.onBlock(tb.Block.Number.Uint64(), tb.Block.Transactions)
+	})
+	c.goBackground(func() { c.receipts.run(ctx) })
+}
+
+const nonceGapFeeMultiplier = 2 // fees of the transactions filling nonce gaps, over the suggested ones
+
+// fillNonceGap sends a zero value transfer from wallet to itself at nonce, so
+// the transactions queued behind a dropped one can be mined. A nonce already
+// taken by a transaction known to the node is not a gap anymore.
+func (c *DefaultClient) fillNonceGap(ctx context.Context, wallet *eth.Wallet, nonce uint64) error {
+	fees, err := c.suggestFees(ctx, nonceGapFeeMultiplier)
+	if err != nil {
+		return err
+	}
+	payload := &Payload{To: &wallet.Address, Value: big.NewInt(0), Gas: transferGas}
+	signedTx, err := types.SignTx(c.newTx(nonce, payload, fees), types.LatestSignerForChainID(c.ethClient.ChainID), wallet.PrivateKey)
+	if err != nil {
+		return err
+	}
+	if err := c.ethClient.Ec.SendTransaction(ctx, signedTx); err != nil {
+		if eth.NonceConsumed(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to fill nonce gap %d of %s: %w", nonce, wallet.Address, err)
+	}
+	if c.balances != nil {
+		c.balances.spend(wallet.Address, signedTx.Cost())
+	}
+	c.log.Info("Filled nonce gap", "address", wallet.Address, "nonce", nonce, "hash", signedTx.Hash())
 	return nil
 }
 
+// generateWallets adds numWallets new testers, loading the wallets stored by
+// previous runs first when reuse is set. It returns the wallets to fund, the
+// generated ones and the loaded ones with a balance below topUpThreshold.
+func (c *DefaultClient) generateWallets(ctx context.Context, numWallets uint64, reuse bool, topUpThreshold *big.Int) (*eth.WalletRegistry, error) {
+	toFund := eth.NewEmptyWalletRegistry()
+	c.generatedWallets = eth.NewEmptyWalletRegistry()
+	if reuse && c.walletStore != nil {
+		stored, err := c.walletStore.Load()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load wallets: %w", err)
+		}
+		if uint64(len(stored)) > numWallets {
+			stored = stored[:numWallets]
+		}
+		if len(stored) > 0 {
+			loaded, err := eth.NewWalletRegistryFromWallets(ctx, c.ethClient.Ec, stored)
+			if err != nil {
+				return nil, err
+			}
+			low, err := loaded.BelowBalance(ctx, c.ethClient.Ec, topUpThreshold)
+			if err != nil {
+				return nil, err
+			}
+			c.testers.Merge(loaded)
+			c.generatedWallets.Merge(loaded)
+			toFund.Merge(low)
+			numWallets -= uint64(len(stored))
+			c.log.Info("Reused wallets", "num_wallets", loaded.Ln(), "top_up", low.Ln())
+		}
+	}
+	if numWallets == 0 {
+		return toFund, nil
+	}
+
+	fresh := eth.NewEmptyWalletRegistry()
+	if err := fresh.GenerateAndStore(ctx, numWallets); err != nil {
+		return nil, err
+	}
+	if c.walletStore != nil {
+		wallets := make([]*eth.Wallet, 0, fresh.Ln())
+		for _, w := range fresh.All() {
+			wallets = append(wallets, w)
+		}
+		if err := c.walletStore.Save(wallets); err != nil {
+			return nil, fmt.Errorf("failed to store wallets: %w", err)
+		}
+	}
+	c.testers.Merge(fresh)
+	c.generatedWallets.Merge(fresh)
+	toFund.Merge(fresh)
+	return toFund, nil
+}
+
+// deriveWallets adds the wallets derived at indexes to wr, creating it when
+// nil.
+func (c *DefaultClient) deriveWallets(ctx context.Context, wr *eth.WalletRegistry, cfg *hdWalletConfig, indexes indexRange) (*eth.WalletRegistry, error) {
+	if indexes.Count == 0 {
+		return wr, nil
+	}
+	derived, err := eth.NewWalletRegistryFromMnemonic(ctx, c.ethClient.Ec, cfg.Mnemonic, cfg.Passphrase, cfg.DerivationPath, indexes.Start, indexes.Count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive wallets: %w", err)
+	}
+	c.log.Info("Derived wallets", "start", indexes.Start, "count", indexes.Count)
+	if wr == nil {
+		return derived, nil
+	}
+	wr.Merge(derived)
+	return wr, nil
+}
+
 func (c *DefaultClient) setupERC20(ctx context.Context, address string, mintAmount big.Int, minGasPrice uint64) error {
 	var err error
 	if address == "" {
@@ -332,18 +615,63 @@ func (c *DefaultClient) setupERC721(ctx context.Context, address string, mint bo
 	return nil
 }
 
+// goBackground runs fn in the background until the client is closed, fn
+// must return once the context of the client is done.
+func (c *DefaultClient) goBackground(fn func()) {
+	c.background.Add(1)
+	go func() {
+		defer c.background.Done()
+		fn()
+	}()
+}
+
+// dbTasks are the database writes and reads running in the background. Close
+// waits for them before exporting the journal and closing the database, and no
+// task is started after that.
+type dbTasks struct {
+	wg     *sync.WaitGroup
+	mu     *sync.Mutex
+	closed bool
+}
+
+func newDBTasks() *dbTasks {
+	return &dbTasks{wg: &sync.WaitGroup{}, mu: &sync.Mutex{}}
+}
+
+// run runs f in the background, unless the tasks are closed.
+func (dt *dbTasks) run(f func()) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+	if dt.closed {
+		return
+	}
+	dt.wg.Add(1)
+	go func() {
+		defer dt.wg.Done()
+		f()
+	}()
+}
+
+// close waits for the running tasks.
+func (dt *dbTasks) close() {
+	dt.mu.Lock()
+	dt.closed = true
+	dt.mu.Unlock()
+	dt.wg.Wait()
+}
+
 func (c *DefaultClient) storeTransactionStartTime(hash common.Hash, t time.Time) {
 	if c.db == nil {
 		return
 	}
 	// TODO: potentially we will use worker pools here and sync.Pool if this becomes the bottleneck
-	go func() {
+	c.dbTasks.run(func() {
 		timeBytes := make([]byte, 8)
 		binary.LittleEndian.PutUint64(timeBytes, uint64(t.UnixMilli()))
 		if err := c.db.Db().Set(c.db.GenKey("tx", hash.Hex()), timeBytes, pebble.NoSync); err != nil {
 			c.log.Error(err, "failed to store tx hash", "hash", hash.Hex())
 		}
-	}()
+	})
 }
 
 func (c *DefaultClient) reportTransactionsLatency(vu modules.VU, metrics *EthMetrics, txs []string, t time.Time) {
@@ -352,41 +680,134 @@ func (c *DefaultClient) reportTransactionsLatency(vu modules.VU, metrics *EthMet
 	}
 
 	reportTx := func(hash string) {
-		val, closer, err := c.db.Db().Get(c.db.GenKey("tx", hash))
-		if err != nil {
-			if err != pebble.ErrNotFound {
-				c.log.Error(err, "failed to get tx hash", "hash", hash)
-			}
+		startTime, ok := c.txStartTime(hash)
+		if !ok {
 			return
 		}
-		defer closer.Close() // nolint:errcheck
-		startTime := time.UnixMilli(int64(binary.LittleEndian.Uint64(val)))
-		ReportTimeToMineFromStats(vu, metrics, c.uid, t.Sub(startTime))
+		ReportTimeToMineFromStats(vu, metrics, c.uid, t.Sub(startTime), c.takeReorged(hash))
 	}
 
 	// TODO: potentially we will use worker pools here and sync.Pool if this becomes the bottleneck
-	go func() {
+	c.dbTasks.run(func() {
 		for _, tx := range txs {
 			reportTx(tx)
 		}
-	}()
+	})
+}
+
+// txStartTime returns when the tx was sent, false when it was not sent by this
+// test.
+func (c *DefaultClient) txStartTime(hash string) (time.Time, bool) {
+	val, closer, err := c.db.Db().Get(c.db.GenKey("tx", hash))
+	if err != nil {
+		if err != pebble.ErrNotFound {
+			c.log.Error(err, "failed to get tx hash", "hash", hash)
+		}
+		return time.Time{}, false
+	}
+	defer closer.Close() // nolint:errcheck
+	return time.UnixMilli(int64(binary.LittleEndian.Uint64(val))), true
+}
+
+// takeReorged reports whether the tx was in a reorged block and clears the
+// mark, so only its first inclusion after the reorg is tagged.
+func (c *DefaultClient) takeReorged(hash string) bool {
+	key := c.db.GenKey("reorged", hash)
+	_, closer, err := c.db.Db().Get(key)
+	if err != nil {
+		if err != pebble.ErrNotFound {
+			c.log.Error(err, "failed to get reorged tx", "hash", hash)
+		}
+		return false
+	}
+	closer.Close() // nolint:errcheck
+	if err := c.db.Db().Delete(key, pebble.NoSync); err != nil {
+		c.log.Error(err, "failed to clear reorged tx", "hash", hash)
+	}
+	return true
 }
 
 func (c *DefaultClient) Export() modules.Exports {
 	return modules.Exports{}
 }
 
+// Close sweeps the generated wallets when sweep_on_close is set, exports the
+// journal when journal_export is set, stores the wallet nonces and closes the
+// database and the node connection.
 func (c *DefaultClient) Close() {
-	if c.db != nil {
-		c.db.Close() // nolint:errcheck
-	}
-	c.ethClient.Close()
+	c.closeOnce.Do(func() {
+		// stop the background goroutines first, they use the database
+		c.cancel()
+		c.background.Wait()
+
+		if c.sweepOnClose {
+			ctx, cancel := context.WithTimeout(context.Background(), eth.WalletsTimeout)
+			if _, err := c.sweepWallets(ctx); err != nil {
+				c.log.Error(err, "failed to sweep wallets")
+			}
+			cancel()
+		}
+		if c.db != nil {
+			c.dbTasks.close()
+		}
+		if c.journal != nil {
+			if c.journalExport != nil {
+				c.exportJournal()
+			}
+		}
+		if c.walletStore != nil && c.testers != nil {
+			if err := c.walletStore.SaveNonces(c.testers.All()); err != nil {
+				c.log.Error(err, "failed to store wallet nonces")
+			}
+		}
+		if c.db != nil {
+			c.db.Close() // nolint:errcheck
+		}
+		c.ethClient.Close()
+	})
 }
 
 func (c *DefaultClient) UID() string {
 	return c.uid
 }
 
+// EthClient returns the connection to the node, for checks outside of the
+// load test.
+func (c *DefaultClient) EthClient() *eth.Client {
+	return c.ethClient
+}
+
+// StateAccounts are the accounts whose state the client changes.
+type StateAccounts struct {
+	Testers  []common.Address
+	ERC20    *common.Address
+	ERC721   *common.Address
+	Deployed []common.Address // contracts deployed with DeployContract, e.g. DummyStorage
+}
+
+// StateAccounts returns the tester wallets and the contracts of the client,
+// sorted by address.
+func (c *DefaultClient) StateAccounts() *StateAccounts {
+	accounts := &StateAccounts{Testers: make([]common.Address, 0), Deployed: make([]common.Address, 0)}
+	if c.testers != nil {
+		for addr := range c.testers.All() {
+			accounts.Testers = append(accounts.Testers, addr)
+		}
+	}
+	if c.erc20 != nil {
+		accounts.ERC20 = c.erc20.Address
+	}
+	if c.erc721 != nil {
+		accounts.ERC721 = c.erc721.Address
+	}
+	for addr := range c.deployedContracts {
+		accounts.Deployed = append(accounts.Deployed, addr)
+	}
+	slices.SortFunc(accounts.Testers, func(a, b common.Address) int { return a.Cmp(b) })
+	slices.SortFunc(accounts.Deployed, func(a, b common.Address) int { return a.Cmp(b) })
+	return accounts
+}
+
 func (c *DefaultClient) RequestSharedWallet() (*eth.Wallet, error) {
 	if c.testers == nil {
 		return nil, fmt.Errorf("no available wallet")
@@ -412,69 +833,106 @@ func (c *DefaultClient) ReleaseSharedWallet(address common.Address) {
 	c.testers.Unlock(address)
 }
 
-type ReportBlock struct {
-	Number         uint64
-	TimestampMili  uint64 // ms
-	Timestamp      uint64 // seconds last block second
-	TimestampDelta int64
+// NonceStates returns the nonce bookkeeping of the wallets used so far.
+func (c *DefaultClient) NonceStates(vu modules.VU, metrics *EthMetrics) ([]*eth.NonceState, error) {
+	return c.nonces.States(), nil
 }
 
 func (c *DefaultClient) ReportBlockMetrics(vu modules.VU, metrics *EthMetrics) error {
 	c.sendReportMux.Lock()
 	defer c.sendReportMux.Unlock()
 
-	ctx := vu.Context()
-
-	blockNum, err := c.ethClient.Ec.BlockNumber(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get block number: %w", err)
+	blocks, reorgs := c.blocks.drain()
+	for _, reorg := range reorgs {
+		c.reportReorg(vu, metrics, reorg)
 	}
-	if blockNum <= c.reportBlock.Number {
-		return nil
+	for _, tb := range blocks {
+		c.reportTrackedBlock(vu, metrics, tb)
 	}
+	if c.balances != nil {
+		for _, event := range c.balances.drain() {
+			ReportRefillFromStats(vu, metrics, c.uid, event.Wallets, event.Duration, event.Failed, event.Time)
+		}
+	}
+	if c.receipts != nil {
+		ReportReceiptsFromStats(vu, metrics, c.uid, c.receipts.drain(), time.Now())
+	}
+	for _, event := range c.nonces.DrainGapFills() {
+		ReportNonceGapFromStats(vu, metrics, c.uid, event.Failed, event.Time)
+	}
+	if c.txPoolRateLimiter != nil {
+		for _, sample := range c.txPoolRateLimiter.DrainHistory() {
+			ReportRateLimitFromStats(vu, metrics, c.uid, sample.Rate, sample.Time)
+		}
+	}
+	return nil
+}
 
-	prevBlockTimestamp := c.reportBlock.Timestamp
-	prevBlockTimestampMili := c.reportBlock.TimestampMili
-
-	c.reportBlock.Number = blockNum
-	c.reportBlock.Timestamp = uint64(time.Now().Unix())
-	c.reportBlock.TimestampMili = uint64(time.Now().UnixMilli())
+// reportReorg reports the depth of reorg and marks the transactions of its
+// dropped blocks, so their time to mine is reported again once they are
+// included in the canonical chain.
+func (c *DefaultClient) reportReorg(vu modules.VU, metrics *EthMetrics, reorg *reorgEvent) {
+	txs := 0
+	for _, b := range reorg.Dropped {
+		txs += len(b.Transactions)
+	}
+	c.log.Info("chain reorganized",
+		"number", reorg.Number,
+		"depth", reorg.Depth,
+		"old_head", reorg.OldHead,
+		"new_head", reorg.NewHead,
+		"dropped_txs", txs,
+	)
+	ReportReorgFromStats(vu, metrics, c.uid, reorg.Number, reorg.Depth, reorg.Time)
 
-	block, err := c.ethClient.SlimBlockByNumber(ctx, new(big.Int).SetUint64(blockNum))
-	if err != nil {
-		return fmt.Errorf("failed to get block: %w", err)
+	if c.db == nil || txs == 0 {
+		return
 	}
-	if block == nil {
-		return fmt.Errorf("block %d not found", blockNum)
+	batch := c.db.Db().NewBatch()
+	defer batch.Close() // nolint:errcheck
+	for _, b := range reorg.Dropped {
+		for _, hash := range b.Transactions {
+			if err := batch.Set(c.db.GenKey("reorged", hash), b.Hash.Bytes(), nil); err != nil {
+				c.log.Error(err, "failed to mark reorged tx", "hash", hash)
+			}
+		}
 	}
+	if err := batch.Commit(pebble.NoSync); err != nil {
+		c.log.Error(err, "failed to store reorged txs", "number", reorg.Number)
+	}
+}
+
+// reportTrackedBlock reports tb as seen at its arrival, computing the rates
+// over the interval since its parent.
+func (c *DefaultClient) reportTrackedBlock(vu modules.VU, metrics *EthMetrics, tb *trackedBlock) {
+	block := *tb.Block
 
 	var tps float64
-	blockTimestampDiff := c.reportBlock.Timestamp - prevBlockTimestamp
-	blockTimestampMiliDiff := c.reportBlock.TimestampMili - prevBlockTimestampMili
 	txsLn := len(block.Transactions)
-	if blockTimestampDiff >= 1 {
-		tps = float64(txsLn) / float64(blockTimestampDiff)
+	if tb.IntervalMili >= 1000 {
+		tps = float64(txsLn) / (float64(tb.IntervalMili) / 1000)
 	} else {
 		tps = float64(txsLn)
 	}
 
-	mgas := float64(block.GasUsed) / (float64(blockTimestampMiliDiff) / 1000) / 1000000
+	var mgas float64
+	if tb.IntervalMili > 0 {
+		mgas = float64(block.GasUsed) / (float64(tb.IntervalMili) / 1000) / 1000000
+	}
 
-	blockTimestampUnixMili := time.UnixMilli(int64(c.reportBlock.TimestampMili))
-	block.Timestamp = block.Timestamp + uint64(c.reportBlock.TimestampDelta)
+	block.Timestamp = block.Timestamp + uint64(c.timestampDelta)
 	ReportBlockMetrics(
 		vu,
 		metrics,
 		c.uid,
-		block,
+		&block,
 		tps,
 		mgas,
-		blockTimestampMiliDiff,
-		blockTimestampUnixMili,
+		tb.IntervalMili,
+		tb.Arrival,
 	)
 
-	c.reportTransactionsLatency(vu, metrics, block.Transactions, blockTimestampUnixMili)
-	return nil
+	c.reportTransactionsLatency(vu, metrics, block.Transactions, tb.Arrival)
 }
 
 func (c *DefaultClient) Call(vu modules.VU, metrics *EthMetrics, method string, args ...interface{}) (interface{}, error) {
@@ -530,6 +988,13 @@ type TransactionOptions struct {
 	TxCount             uint64
 	GasPriceMultiplier  uint64
 	WalletAddresses     []*common.Address
+	BlobCount           uint64
+	RandomBlobs         bool
+	AuthorizationCount  uint64
+	Data                []byte
+	To                  *common.Address
+	AccessList          types.AccessList
+	AutoAccessList      bool
 }
 
 type TransactionOption func(*TransactionOptions)
@@ -571,595 +1036,115 @@ func WithTransactionWalletAddress(addresses []*common.Address) TransactionOption
 	}
 }
 
-func DefaultTransactionOptions() *TransactionOptions {
-	return &TransactionOptions{
-		WaitForConfirmation: false,
-		ConfirmationDelay:   eth.EthDefaultBlockTime,
-		NoSend:              false,
-		OffsetNonce:         0,
-		TxCount:             1,
-		GasPriceMultiplier:  1,
-		WalletAddresses:     nil,
+func WithTransactionBlobCount(count uint64) TransactionOption {
+	return func(opts *TransactionOptions) {
+		opts.BlobCount = count
 	}
 }
 
-func (c *DefaultClient) SendTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
-	opts := DefaultTransactionOptions()
-	for _, opt := range options {
-		opt(opts)
+func WithTransactionRandomBlobs(random bool) TransactionOption {
+	return func(opts *TransactionOptions) {
+		opts.RandomBlobs = random
 	}
+}
 
-	if opts.WaitForConfirmation && opts.OffsetNonce > 0 {
-		return nil, fmt.Errorf("cannot use offset nonce with confirmation")
+func WithTransactionAuthorizationCount(count uint64) TransactionOption {
+	return func(opts *TransactionOptions) {
+		opts.AuthorizationCount = count
 	}
+}
 
-	var wallet *eth.Wallet
-	for _, address := range opts.WalletAddresses {
-		if c.IsSharedWallet(*address) {
-			wallet = c.sharedWallets[address.Hex()]
-			break
-		}
-	}
-	if wallet == nil {
-		wallet = c.testers.GetAvailableWallet()
-		if wallet == nil {
-			return nil, fmt.Errorf("no available wallet")
-		}
-		defer c.testers.Unlock(wallet.Address)
+func WithTransactionTo(to common.Address) TransactionOption {
+	return func(opts *TransactionOptions) {
+		opts.To = &to
 	}
+}
 
-	var hash common.Hash
-
-	errCh := make(chan error, int(opts.TxCount))
-	ctx := vu.Context()
-	targetAddress := c.targetAddresses.Random()
-
-	retryForNonce := false
-	sendTx := func() {
-		if c.txPoolRateLimiter != nil {
-			if err := c.txPoolRateLimiter.Wait(ctx); err != nil {
-				errCh <- fmt.Errorf("rate limit: %w", err)
-			}
-		}
-
-		var nonce uint64
-		wallet.Lock()
-		if retryForNonce {
-			retryForNonce = false
-		} else {
-			nonce = wallet.Nonce
-			if opts.OffsetNonce > 0 {
-				if wallet.OffsetNonce <= wallet.Nonce {
-					wallet.OffsetNonce = wallet.Nonce + opts.OffsetNonce
-				}
-				nonce = wallet.OffsetNonce
-			}
-			if opts.OffsetNonce == 0 {
-				wallet.Nonce++
-			}
-			wallet.OffsetNonce++
-		}
-		wallet.Unlock()
-
-		var tx *types.Transaction
-		if !c.isLegacy {
-			head, err := c.ethClient.Ec.HeaderByNumber(vu.Context(), nil)
-			if err != nil {
-				errCh <- err
-				return
-			}
-
-			// EIP-1559 transaction
-			tipCap := new(big.Int).Mul(c.latestGasTip.Load(), big.NewInt(int64(opts.GasPriceMultiplier)))
-			feeCap := new(big.Int).Add(
-				tipCap,
-				new(big.Int).Mul(head.BaseFee, big.NewInt(2)),
-			)
-			tx = types.NewTx(&types.DynamicFeeTx{
-				ChainID:   c.ethClient.ChainID,
-				Nonce:     nonce,
-				To:        targetAddress,
-				Value:     big.NewInt(1),
-				Gas:       21000,
-				GasTipCap: tipCap,
-				GasFeeCap: feeCap,
-				Data:      nil,
-			})
-		} else {
-			// Legacy transaction
-			tx = types.NewTx(&types.LegacyTx{
-				Nonce:    nonce,
-				To:       targetAddress,
-				Value:    big.NewInt(1),
-				Gas:      21000,
-				GasPrice: new(big.Int).Mul(c.latestGasPrice.Load(), big.NewInt(int64(opts.GasPriceMultiplier))),
-				Data:     nil,
-			})
-		}
-
-		signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(c.ethClient.ChainID), wallet.PrivateKey)
-		if err != nil {
-			errCh <- err
-			return
-		}
-
-		t := time.Now()
-		if opts.NoSend {
-			cm := ethereum.CallMsg{
-				From:       wallet.Address,
-				To:         signedTx.To(),
-				Gas:        signedTx.Gas(),
-				Value:      signedTx.Value(),
-				Data:       signedTx.Data(),
-				AccessList: signedTx.AccessList(),
-			}
-			if !c.isLegacy {
-				cm.GasFeeCap = signedTx.GasFeeCap()
-				cm.GasTipCap = signedTx.GasTipCap()
-			} else {
-				cm.GasPrice = signedTx.GasPrice()
-			}
-
-			if _, err := c.ethClient.Ec.CallContract(vu.Context(), cm, nil); err != nil {
-				if strings.Contains(err.Error(), "fee cap less than block base fee") {
-					retryForNonce = true
-				}
-				errCh <- err
-				return
-			}
-		} else {
-			if err := c.ethClient.Ec.SendTransaction(vu.Context(), signedTx); err != nil {
-				retryForNonce = true
-				if strings.Contains(err.Error(), "replacement transaction underpriced") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "transaction underpriced") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "nonce too low") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "already known") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "could not replace existing") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "fee cap less than block base fee") {
-					retryForNonce = true
-				}
-
-				errCh <- err
-				return
-			}
-		}
-
-		ReportEoaFromStats(vu, metrics, c.uid, 1, eth.TransactionTypeETH)
-		ReportReqDurationFromStats(vu, metrics, c.uid, "sendTransaction", time.Since(t))
-
-		hash = signedTx.Hash()
-		c.storeTransactionStartTime(hash, t)
-
-		if opts.WaitForConfirmation {
-			_, err := eth.WaitUntilMined(vu.Context(), c.ethClient.Ec, hash, opts.ConfirmationDelay, 10*time.Millisecond)
-			if err != nil {
-				errCh <- err
-				return
-			}
-
-			ReportReqDurationFromStats(vu, metrics, c.uid, "sendConfirmedTransaction", time.Since(t))
-		}
+func WithTransactionData(data []byte) TransactionOption {
+	return func(opts *TransactionOptions) {
+		opts.Data = data
 	}
+}
 
-	for i := 0; i < int(opts.TxCount); i++ {
-		if ctx.Err() != nil {
-			break
-		}
-
-		sendTx()
+func WithTransactionAccessList(accessList types.AccessList) TransactionOption {
+	return func(opts *TransactionOptions) {
+		opts.AccessList = accessList
+		opts.AutoAccessList = false
 	}
+}
 
-	close(errCh)
-	for err := range errCh {
-		if err != nil && !errors.Is(err, context.Canceled) {
-			c.log.Error(err, "error in sending transactions")
-		}
+func WithTransactionAutoAccessList() TransactionOption {
+	return func(opts *TransactionOptions) {
+		opts.AccessList = nil
+		opts.AutoAccessList = true
 	}
+}
 
-	if hash == (common.Hash{}) {
-		return nil, fmt.Errorf("failed to send any transaction")
+func DefaultTransactionOptions() *TransactionOptions {
+	return &TransactionOptions{
+		WaitForConfirmation: false,
+		ConfirmationDelay:   eth.EthDefaultBlockTime,
+		NoSend:              false,
+		OffsetNonce:         0,
+		TxCount:             1,
+		GasPriceMultiplier:  1,
+		WalletAddresses:     nil,
+		BlobCount:           1,
+		RandomBlobs:         false,
+		AuthorizationCount:  1,
+		Data:                nil,
+		To:                  nil,
+		AccessList:          nil,
+		AutoAccessList:      false,
 	}
+}
 
-	return &hash, nil
+func (c *DefaultClient) SendTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
+	return c.send(vu, metrics, eth.TransactionTypeETH, options...)
 }
 
 func (c *DefaultClient) SendERC20Transaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
-	if c.erc20 == nil {
-		return nil, fmt.Errorf("erc20 contract is not initialized")
-	}
-
-	opts := DefaultTransactionOptions()
-	for _, opt := range options {
-		opt(opts)
-	}
-
-	if opts.WaitForConfirmation && opts.OffsetNonce > 0 {
-		return nil, fmt.Errorf("cannot use offset nonce with confirmation")
-	}
-
-	var wallet *eth.Wallet
-	for _, address := range opts.WalletAddresses {
-		if c.IsSharedWallet(*address) {
-			wallet = c.sharedWallets[address.Hex()]
-			break
-		}
-	}
-	if wallet == nil {
-		wallet = c.testers.GetAvailableWallet()
-		if wallet == nil {
-			return nil, fmt.Errorf("no available wallet")
-		}
-		defer c.testers.Unlock(wallet.Address)
-	}
-
-	var hash common.Hash
-
-	errCh := make(chan error, int(opts.TxCount))
-	ctx := vu.Context()
-	targetAddress := c.targetAddresses.Random()
-
-	retryForNonce := false
-	sendTx := func() {
-		if c.txPoolRateLimiter != nil {
-			if err := c.txPoolRateLimiter.Wait(ctx); err != nil {
-				errCh <- fmt.Errorf("rate limit: %w", err)
-			}
-		}
-
-		var nonce uint64
-		wallet.Lock()
-		if retryForNonce {
-			retryForNonce = false
-		} else {
-			nonce = wallet.Nonce
-			if opts.OffsetNonce > 0 {
-				if wallet.OffsetNonce <= wallet.Nonce {
-					wallet.OffsetNonce = wallet.Nonce + opts.OffsetNonce
-				}
-				nonce = wallet.OffsetNonce
-			}
-			if opts.OffsetNonce == 0 {
-				wallet.Nonce++
-			}
-			wallet.OffsetNonce++
-		}
-		wallet.Unlock()
-
-		signFn := func(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
-			sig := types.LatestSignerForChainID(c.ethClient.ChainID)
-			return types.SignTx(tx, sig, wallet.PrivateKey)
-		}
-
-		var (
-			tx  *types.Transaction
-			err error
-			t   time.Time
-		)
-		tops := bind.TransactOpts{
-			From:    wallet.Address,
-			Signer:  signFn,
-			Context: vu.Context(),
-			Nonce:   big.NewInt(int64(nonce)),
-		}
-
-		if !c.isLegacy {
-			head, err := c.ethClient.Ec.HeaderByNumber(vu.Context(), nil)
-			if err != nil {
-				errCh <- err
-				return
-			}
-
-			tipCap := new(big.Int).Mul(c.latestGasTip.Load(), big.NewInt(int64(opts.GasPriceMultiplier)))
-			feeCap := new(big.Int).Add(
-				tipCap,
-				new(big.Int).Mul(head.BaseFee, big.NewInt(2)),
-			)
-			tops.GasFeeCap = feeCap
-			tops.GasTipCap = tipCap
-		} else {
-			tops.GasPrice = new(big.Int).Mul(c.latestGasPrice.Load(), big.NewInt(int64(opts.GasPriceMultiplier)))
-		}
-
-		if opts.NoSend {
-			t = time.Now()
-			tops.NoSend = true
-			tx, err = c.erc20.Contract.Transfer(&tops, *targetAddress, big.NewInt(1))
-			if err != nil {
-				errCh <- err
-				return
-			}
-			cm := ethereum.CallMsg{
-				From:       wallet.Address,
-				To:         tx.To(),
-				Gas:        tx.Gas(),
-				Value:      tx.Value(),
-				Data:       tx.Data(),
-				AccessList: tx.AccessList(),
-			}
-			if !c.isLegacy {
-				cm.GasFeeCap = tx.GasFeeCap()
-				cm.GasTipCap = tx.GasTipCap()
-			} else {
-				cm.GasPrice = tx.GasPrice()
-			}
-
-			if _, err := c.ethClient.Ec.CallContract(vu.Context(), cm, nil); err != nil {
-				if strings.Contains(err.Error(), "fee cap less than block base fee") {
-					retryForNonce = true
-				}
-				errCh <- err
-				return
-			}
-		} else {
-			t = time.Now()
-			tx, err = c.erc20.Contract.Transfer(&tops, *targetAddress, big.NewInt(1))
-			if err != nil {
-				retryForNonce = true
-				if strings.Contains(err.Error(), "replacement transaction underpriced") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "transaction underpriced") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "nonce too low") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "already known") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "could not replace existing") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "fee cap less than block base fee") {
-					retryForNonce = true
-				}
-
-				errCh <- err
-				return
-			}
-		}
-
-		ReportEoaFromStats(vu, metrics, c.uid, 1, eth.TransactionTypeERC20)
-		ReportReqDurationFromStats(vu, metrics, c.uid, "sendERC20Transaction", time.Since(t))
-
-		hash = tx.Hash()
-		c.storeTransactionStartTime(hash, t)
-
-		if opts.WaitForConfirmation {
-			_, err := eth.WaitUntilMined(vu.Context(), c.ethClient.Ec, hash, opts.ConfirmationDelay, 10*time.Millisecond)
-			if err != nil {
-				errCh <- err
-				return
-			}
-
-			ReportReqDurationFromStats(vu, metrics, c.uid, "sendConfirmedERC20Transaction", time.Since(t))
-		}
-	}
-	for i := 0; i < int(opts.TxCount); i++ {
-		if ctx.Err() != nil {
-			break
-		}
-
-		sendTx()
-	}
+	return c.send(vu, metrics, eth.TransactionTypeERC20, options...)
+}
 
-	close(errCh)
-	for err := range errCh {
-		if err != nil && !errors.Is(err, context.Canceled) {
-			c.log.Error(err, "error in sending transactions")
-		}
-	}
+func (c *DefaultClient) SendERC721Transaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
+	return c.send(vu, metrics, eth.TransactionTypeERC721, options...)
+}
 
-	if hash == (common.Hash{}) {
-		return nil, fmt.Errorf("failed to send any transaction")
-	}
+func (c *DefaultClient) SendBlobTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
+	return c.send(vu, metrics, eth.TransactionTypeBlob, options...)
+}
 
-	return &hash, nil
+func (c *DefaultClient) SendSetCodeTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
+	return c.send(vu, metrics, eth.TransactionTypeSetCode, options...)
 }
 
-func (c *DefaultClient) SendERC721Transaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
-	if c.erc721 == nil {
-		return nil, fmt.Errorf("erc721 contract is not initialized")
-	}
+func (c *DefaultClient) SendDelegatedTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
+	return c.send(vu, metrics, eth.TransactionTypeDelegated, options...)
+}
 
+func (c *DefaultClient) send(vu modules.VU, metrics *EthMetrics, txType eth.TransactionType, options ...TransactionOption) (*common.Hash, error) {
 	opts := DefaultTransactionOptions()
 	for _, opt := range options {
 		opt(opts)
 	}
 
-	if opts.WaitForConfirmation && opts.OffsetNonce > 0 {
-		return nil, fmt.Errorf("cannot use offset nonce with confirmation")
-	}
-
-	var wallet *eth.Wallet
-	for _, address := range opts.WalletAddresses {
-		if c.IsSharedWallet(*address) {
-			wallet = c.sharedWallets[address.Hex()]
-			break
-		}
-	}
-	if wallet == nil {
-		wallet = c.testers.GetAvailableWallet()
-		if wallet == nil {
-			return nil, fmt.Errorf("no available wallet")
-		}
-		defer c.testers.Unlock(wallet.Address)
-	}
-
-	var hash common.Hash
-
-	errCh := make(chan error, int(opts.TxCount))
-	ctx := vu.Context()
-	targetAddress := c.targetAddresses.Random()
-
-	retryForNonce := false
-	sendTx := func() {
-		if c.txPoolRateLimiter != nil {
-			if err := c.txPoolRateLimiter.Wait(ctx); err != nil {
-				errCh <- fmt.Errorf("rate limit: %w", err)
-			}
-		}
-
-		var nonce uint64
-		wallet.Lock()
-		if retryForNonce {
-			retryForNonce = false
-		} else {
-			nonce = wallet.Nonce
-			if opts.OffsetNonce > 0 {
-				if wallet.OffsetNonce <= wallet.Nonce {
-					wallet.OffsetNonce = wallet.Nonce + opts.OffsetNonce
-				}
-				nonce = wallet.OffsetNonce
-			}
-			if opts.OffsetNonce == 0 {
-				wallet.Nonce++
-			}
-			wallet.OffsetNonce++
-		}
-		wallet.Unlock()
-
-		signFn := func(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
-			sig := types.LatestSignerForChainID(c.ethClient.ChainID)
-			return types.SignTx(tx, sig, wallet.PrivateKey)
-		}
-
-		var (
-			tx  *types.Transaction
-			err error
-			t   time.Time
-		)
-		tops := bind.TransactOpts{
-			From:    wallet.Address,
-			Signer:  signFn,
-			Context: vu.Context(),
-			Nonce:   big.NewInt(int64(nonce)),
-			Value:   big.NewInt(0),
-		}
-
-		if !c.isLegacy {
-			head, err := c.ethClient.Ec.HeaderByNumber(vu.Context(), nil)
-			if err != nil {
-				errCh <- err
-				return
-			}
-
-			tipCap := new(big.Int).Mul(c.latestGasTip.Load(), big.NewInt(int64(opts.GasPriceMultiplier)))
-			feeCap := new(big.Int).Add(
-				tipCap,
-				new(big.Int).Mul(head.BaseFee, big.NewInt(2)),
-			)
-			tops.GasFeeCap = feeCap
-			tops.GasTipCap = tipCap
-		} else {
-			tops.GasPrice = new(big.Int).Mul(c.latestGasPrice.Load(), big.NewInt(int64(opts.GasPriceMultiplier)))
-		}
-
-		if opts.NoSend {
-			t = time.Now()
-			tops.NoSend = true
-			tx, err = c.erc721.Contract.MintBatch(&tops, *targetAddress, big.NewInt(1))
-			if err != nil {
-				errCh <- err
-				return
-			}
-			cm := ethereum.CallMsg{
-				From:       wallet.Address,
-				To:         tx.To(),
-				Gas:        tx.Gas(),
-				Value:      tx.Value(),
-				Data:       tx.Data(),
-				AccessList: tx.AccessList(),
-			}
-			if !c.isLegacy {
-				cm.GasFeeCap = tx.GasFeeCap()
-				cm.GasTipCap = tx.GasTipCap()
-			} else {
-				cm.GasPrice = tx.GasPrice()
-			}
-			if _, err := c.ethClient.Ec.CallContract(vu.Context(), cm, nil); err != nil {
-				if strings.Contains(err.Error(), "fee cap less than block base fee") {
-					retryForNonce = true
-				}
-				errCh <- err
-				return
-			}
-		} else {
-			t = time.Now()
-			tx, err = c.erc721.Contract.MintBatch(&tops, *targetAddress, big.NewInt(1))
-			if err != nil {
-				retryForNonce = true
-				if strings.Contains(err.Error(), "replacement transaction underpriced") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "transaction underpriced") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "nonce too low") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "already known") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "could not replace existing") && retryForNonce {
-					retryForNonce = false
-				}
-				if strings.Contains(err.Error(), "fee cap less than block base fee") {
-					retryForNonce = true
-				}
-
-				errCh <- err
-				return
-			}
-		}
-
-		ReportEoaFromStats(vu, metrics, c.uid, 1, eth.TransactionTypeERC721)
-		ReportReqDurationFromStats(vu, metrics, c.uid, "sendERC721Transaction", time.Since(t))
-
-		hash = tx.Hash()
-		c.storeTransactionStartTime(hash, t)
-
-		if opts.WaitForConfirmation {
-			_, err := eth.WaitUntilMined(vu.Context(), c.ethClient.Ec, hash, opts.ConfirmationDelay, 10*time.Millisecond)
-			if err != nil {
-				errCh <- err
-				return
-			}
-
-			ReportReqDurationFromStats(vu, metrics, c.uid, "sendConfirmedERC721Transaction", time.Since(t))
-		}
-	}
-
-	for i := 0; i < int(opts.TxCount); i++ {
-		if ctx.Err() != nil {
-			break
-		}
-
-		sendTx()
+	builder, err := c.payloadBuilder(txType, opts)
+	if err != nil {
+		return nil, err
 	}
+	return c.sendPayloads(vu, metrics, builder, options...)
+}
 
-	close(errCh)
-	for err := range errCh {
-		if err != nil && !errors.Is(err, context.Canceled) {
-			c.log.Error(err, "error in sending transactions")
-		}
-	}
+func (c *DefaultClient) markDelegated(address common.Address) {
+	c.delegatedMux.Lock()
+	defer c.delegatedMux.Unlock()
 
-	if hash == (common.Hash{}) {
-		return nil, fmt.Errorf("failed to send any transaction")
+	if _, exists := c.delegatedSet[address]; exists {
+		return
 	}
-
-	return &hash, nil
+	c.delegatedSet[address] = struct{}{}
+	c.delegated.Add(&address)
 }
 
 func (c *DefaultClient) DeployContract(vu modules.VU, params *eth.DeployContractParams) (*common.Address, *common.Hash, *common.Address, error) {
@@ -1184,29 +1169,22 @@ func (c *DefaultClient) DeployContract(vu modules.VU, params *eth.DeployContract
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	tops.Nonce = big.NewInt(int64(wallet.Nonce))
-
-	if !c.isLegacy {
-		head, err := c.ethClient.Ec.HeaderByNumber(vu.Context(), nil)
-		if err != nil {
-			return nil, nil, nil, err
-		}
-
-		tipCap := new(big.Int).Mul(c.latestGasTip.Load(), big.NewInt(int64(params.GasPriceMultiplier)))
-		feeCap := new(big.Int).Add(
-			tipCap,
-			new(big.Int).Mul(head.BaseFee, big.NewInt(2)),
-		)
-		tops.GasFeeCap = feeCap
-		tops.GasTipCap = tipCap
-	} else {
-		tops.GasPrice = new(big.Int).Mul(c.latestGasPrice.Load(), big.NewInt(int64(params.GasPriceMultiplier)))
+	parsedAbi, err := abi.JSON(strings.NewReader(string(abiBytes)))
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	parsedAbi, err := abi.JSON(strings.NewReader(string(abiBytes)))
+	nonce := c.nonces.Next(wallet, 0)
+	tops.Nonce = new(big.Int).SetUint64(nonce)
+
+	fees, err := c.suggestFees(vu.Context(), params.GasPriceMultiplier)
 	if err != nil {
+		c.nonces.Done(wallet, nonce, false, nil)
 		return nil, nil, nil, err
 	}
+	tops.GasFeeCap = fees.GasFeeCap
+	tops.GasTipCap = fees.GasTipCap
+	tops.GasPrice = fees.GasPrice
 
 	addr, tx, contract, err := bind.DeployContract(
 		tops,
@@ -1215,17 +1193,12 @@ func (c *DefaultClient) DeployContract(vu modules.VU, params *eth.DeployContract
 		c.ethClient.Ec,
 		params.Args...,
 	)
+	c.nonces.Done(wallet, nonce, err == nil, err)
 	if err != nil {
-		if eth.DoIncreaseNonceWhenError(err) {
-			wallet.Nonce++
-			wallet.OffsetNonce++
-		}
 		return nil, nil, nil, err
 	}
 
 	hash := tx.Hash()
-	wallet.Nonce++
-	wallet.OffsetNonce++
 
 	if _, err := eth.WaitUntilMined(vu.Context(), c.ethClient.Ec, hash, eth.EthDefaultBlockTime, 10*time.Millisecond); err != nil {
 		return nil, nil, nil, err
@@ -1256,38 +1229,29 @@ func (c *DefaultClient) TxContract(vu modules.VU, metrics *EthMetrics, params *e
 		From:       wallet.Address,
 		Signer:     signFn,
 		Context:    vu.Context(),
-		Nonce:      big.NewInt(int64(wallet.Nonce)),
 		AccessList: params.AccessList,
 	}
 
-	if !c.isLegacy {
-		head, err := c.ethClient.Ec.HeaderByNumber(vu.Context(), nil)
-		if err != nil {
-			return nil, err
-		}
+	nonce := c.nonces.Next(wallet, 0)
+	tops.Nonce = new(big.Int).SetUint64(nonce)
 
-		tipCap := new(big.Int).Mul(c.latestGasTip.Load(), big.NewInt(int64(params.GasPriceMultiplier)))
-		feeCap := new(big.Int).Add(
-			tipCap,
-			new(big.Int).Mul(head.BaseFee, big.NewInt(2)),
-		)
-		tops.GasFeeCap = feeCap
-		tops.GasTipCap = tipCap
-	} else {
-		tops.GasPrice = new(big.Int).Mul(c.latestGasPrice.Load(), big.NewInt(int64(params.GasPriceMultiplier)))
+	fees, err := c.suggestFees(vu.Context(), params.GasPriceMultiplier)
+	if err != nil {
+		c.nonces.Done(wallet, nonce, false, nil)
+		return nil, err
 	}
+	tops.GasFeeCap = fees.GasFeeCap
+	tops.GasTipCap = fees.GasTipCap
+	tops.GasPrice = fees.GasPrice
 
 	t := time.Now()
 	tx, err := contract.Transact(tops, params.Method, params.Args...)
+	c.nonces.Done(wallet, nonce, err == nil, err)
+	ReportTxOutcomeFromStats(vu, metrics, c.uid, "callContract", eth.TransactionTypeContract, classifyTxOutcome(err))
 	if err != nil {
-		if eth.DoIncreaseNonceWhenError(err) {
-			wallet.Nonce++
-			wallet.OffsetNonce++
-		}
+		c.reportTxError(vu, metrics, err)
 		return nil, err
 	}
-	wallet.Nonce++
-	wallet.OffsetNonce++
 
 	ReportReqDurationFromStats(vu, metrics, c.uid, "callContract", time.Since(t))
 
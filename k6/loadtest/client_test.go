@@ -5,6 +5,7 @@ import (
 	"math/big"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/grafana/sobek"
 	"github.com/mysteryforge/gasper/k6/eth"
 	"github.com/mysteryforge/gasper/k6/mock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	k6common "go.k6.io/k6/js/common"
 	"go.k6.io/k6/lib"
@@ -449,3 +451,20 @@ func (m *mockVU) RegisterCallback() (enqueueCallback func(func() error)) {
 // 		PoolStatusQueued:  r.MustNewMetric("mock_gasper_pool_status_queued", metrics.Trend, metrics.Default),
 // 	}
 // }
+
+func TestDBTasks(t *testing.T) {
+	dt := newDBTasks()
+	var done atomic.Int32
+	for range 3 {
+		dt.run(func() {
+			time.Sleep(10 * time.Millisecond)
+			done.Add(1)
+		})
+	}
+	dt.close()
+	assert.Equal(t, int32(3), done.Load())
+
+	dt.run(func() { done.Add(1) })
+	dt.close()
+	assert.Equal(t, int32(3), done.Load())
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"time"
 
 	"strings"
 
@@ -97,7 +98,10 @@ func (cs *Clients) ReportBlockMetrics(vu modules.VU, metrics *EthMetrics) map[st
 
 func (cs *Clients) SendTransaction(vu modules.VU, metrics *EthMetrics, params map[string]interface{}) map[string]Result {
 	return executeOnAllClients(cs, func(c Client) (string, error) {
-		options := parseSendTransactionParams(params, c.UID())
+		options, err := parseSendTransactionParams(params, c.UID())
+		if err != nil {
+			return "", err
+		}
 		hash, err := c.SendTransaction(vu, metrics, options...)
 		if err != nil {
 			return "", err
@@ -108,7 +112,10 @@ func (cs *Clients) SendTransaction(vu modules.VU, metrics *EthMetrics, params ma
 
 func (cs *Clients) SendERC20Transaction(vu modules.VU, metrics *EthMetrics, params map[string]interface{}) map[string]Result {
 	return executeOnAllClients(cs, func(c Client) (string, error) {
-		options := parseSendTransactionParams(params, c.UID())
+		options, err := parseSendTransactionParams(params, c.UID())
+		if err != nil {
+			return "", err
+		}
 		hash, err := c.SendERC20Transaction(vu, metrics, options...)
 		if err != nil {
 			return "", err
@@ -119,7 +126,10 @@ func (cs *Clients) SendERC20Transaction(vu modules.VU, metrics *EthMetrics, para
 
 func (cs *Clients) SendOffsetTransaction(vu modules.VU, metrics *EthMetrics, params map[string]interface{}) map[string]Result {
 	return executeOnAllClients(cs, func(c Client) (string, error) {
-		options := parseSendTransactionParams(params, c.UID())
+		options, err := parseSendTransactionParams(params, c.UID())
+		if err != nil {
+			return "", err
+		}
 		hash, err := c.SendTransaction(vu, metrics, options...)
 		if err != nil {
 			return "", err
@@ -130,7 +140,10 @@ func (cs *Clients) SendOffsetTransaction(vu modules.VU, metrics *EthMetrics, par
 
 func (cs *Clients) SendERC721Transaction(vu modules.VU, metrics *EthMetrics, params map[string]interface{}) map[string]Result {
 	return executeOnAllClients(cs, func(c Client) (string, error) {
-		options := parseSendTransactionParams(params, c.UID())
+		options, err := parseSendTransactionParams(params, c.UID())
+		if err != nil {
+			return "", err
+		}
 		hash, err := c.SendERC721Transaction(vu, metrics, options...)
 		if err != nil {
 			return "", err
@@ -139,6 +152,48 @@ func (cs *Clients) SendERC721Transaction(vu modules.VU, metrics *EthMetrics, par
 	})
 }
 
+func (cs *Clients) SendBlobTransaction(vu modules.VU, metrics *EthMetrics, params map[string]interface{}) map[string]Result {
+	return executeOnAllClients(cs, func(c Client) (string, error) {
+		options, err := parseSendTransactionParams(params, c.UID())
+		if err != nil {
+			return "", err
+		}
+		hash, err := c.SendBlobTransaction(vu, metrics, options...)
+		if err != nil {
+			return "", err
+		}
+		return hash.Hex(), nil
+	})
+}
+
+func (cs *Clients) SendSetCodeTransaction(vu modules.VU, metrics *EthMetrics, params map[string]interface{}) map[string]Result {
+	return executeOnAllClients(cs, func(c Client) (string, error) {
+		options, err := parseSendTransactionParams(params, c.UID())
+		if err != nil {
+			return "", err
+		}
+		hash, err := c.SendSetCodeTransaction(vu, metrics, options...)
+		if err != nil {
+			return "", err
+		}
+		return hash.Hex(), nil
+	})
+}
+
+func (cs *Clients) SendDelegatedTransaction(vu modules.VU, metrics *EthMetrics, params map[string]interface{}) map[string]Result {
+	return executeOnAllClients(cs, func(c Client) (string, error) {
+		options, err := parseSendTransactionParams(params, c.UID())
+		if err != nil {
+			return "", err
+		}
+		hash, err := c.SendDelegatedTransaction(vu, metrics, options...)
+		if err != nil {
+			return "", err
+		}
+		return hash.Hex(), nil
+	})
+}
+
 type ContractDeploymentResponse struct {
 	TransactionHash string
 	ContractAddress string
@@ -188,11 +243,11 @@ func (cs *Clients) CallContract(vu modules.VU, metrics *EthMetrics, params map[s
 }
 
 func (cs *Clients) TxInfoByHash(vu modules.VU, metrics *EthMetrics, txHash string) map[string]Result {
+	txHash = strings.TrimPrefix(txHash, "0x")
 	return executeOnAllClients(cs, func(c Client) (*eth.TransactionInfo, error) {
 		if txHash == "" {
 			return nil, fmt.Errorf("tx_hash is empty")
 		}
-		txHash = strings.TrimPrefix(txHash, "0x")
 		if len(txHash) != 64 {
 			return nil, fmt.Errorf("tx_hash does not look like a valid hash")
 		}
@@ -215,6 +270,103 @@ func (cs *Clients) Call(vu modules.VU, metrics *EthMetrics, method string, param
 	})
 }
 
+func (cs *Clients) Dispatch(vu modules.VU, metrics *EthMetrics, params map[string]interface{}) map[string]Result {
+	txType := eth.TransactionTypeETH
+	if t, ok := params["tx_type"].(string); ok && t != "" {
+		txType = eth.TransactionType(t)
+	}
+
+	var (
+		duration    time.Duration
+		durationErr error
+	)
+	if d, ok := params["duration"].(string); ok && d != "" {
+		duration, durationErr = time.ParseDuration(d)
+	}
+
+	var targetTPS uint64
+	if t, ok := params["target_tps"].(int64); ok {
+		targetTPS = uint64(t)
+	}
+
+	return executeOnAllClientsConcurrently(cs, func(c Client) (*DispatchResult, error) {
+		if durationErr != nil {
+			return nil, fmt.Errorf("invalid duration: %w", durationErr)
+		}
+		options, err := parseSendTransactionParams(params, c.UID())
+		if err != nil {
+			return nil, err
+		}
+		return c.Dispatch(vu, metrics, txType, duration, targetTPS, options...)
+	})
+}
+
+func (cs *Clients) DispatchGas(vu modules.VU, metrics *EthMetrics, params map[string]interface{}) map[string]Result {
+	var (
+		duration    time.Duration
+		durationErr error
+	)
+	if d, ok := params["duration"].(string); ok && d != "" {
+		duration, durationErr = time.ParseDuration(d)
+	}
+
+	var targetMGas float64
+	switch t := params["target_mgas"].(type) {
+	case int64:
+		targetMGas = float64(t)
+	case float64:
+		targetMGas = t
+	}
+
+	return executeOnAllClientsConcurrently(cs, func(c Client) (*DispatchResult, error) {
+		if durationErr != nil {
+			return nil, fmt.Errorf("invalid duration: %w", durationErr)
+		}
+		options, err := parseSendTransactionParams(params, c.UID())
+		if err != nil {
+			return nil, err
+		}
+		return c.DispatchGas(vu, metrics, duration, targetMGas, options...)
+	})
+}
+
+func (cs *Clients) SweepWallets(vu modules.VU, metrics *EthMetrics) map[string]Result {
+	return executeOnAllClients(cs, func(c Client) (*SweepResult, error) {
+		return c.SweepWallets(vu, metrics)
+	})
+}
+
+func (cs *Clients) NonceStates(vu modules.VU, metrics *EthMetrics) map[string]Result {
+	return executeOnAllClients(cs, func(c Client) ([]*eth.NonceState, error) {
+		return c.NonceStates(vu, metrics)
+	})
+}
+
+// Close closes every client, sweeping their wallets first when sweep_on_close
+// is set.
+func (cs *Clients) Close() {
+	wg := sync.WaitGroup{}
+	for _, c := range cs.list {
+		wg.Add(1)
+		go func(client Client) {
+			defer wg.Done()
+			client.Close()
+		}(c)
+	}
+	wg.Wait()
+}
+
+// List returns the clients in the order of the config.
+func (cs *Clients) List() []Client {
+	return cs.list
+}
+
+// Execute runs fn on every client, one after the other, results are keyed by
+// client uid.
+func (cs *Clients) Execute(fn func(Client) (any, error)) map[string]Result {
+	return executeOnAllClients(cs, fn)
+}
+
 func executeOnAllClients[T any](cs *Clients, fn func(Client) (T, error)) map[string]Result {
 	res := make(map[string]Result)
 	wg := sync.WaitGroup{}
@@ -238,7 +390,33 @@ func executeOnAllClients[T any](cs *Clients, fn func(Client) (T, error)) map[str
 	return res
 }
 
-func parseSendTransactionParams(params map[string]interface{}, clientUid string) []TransactionOption {
+// executeOnAllClientsConcurrently runs fn on every client at the same time,
+// so long running calls like dispatch load every client at once. fn must not
+// share state between the clients.
+func executeOnAllClientsConcurrently[T any](cs *Clients, fn func(Client) (T, error)) map[string]Result {
+	res := make(map[string]Result)
+	mu := &sync.Mutex{}
+	wg := sync.WaitGroup{}
+	wg.Add(len(cs.list))
+
+	for _, c := range cs.list {
+		go func(client Client) {
+			defer wg.Done()
+			data, err := fn(client)
+			r := Result{Data: data}
+			if err != nil {
+				r = Result{Err: err}
+			}
+			mu.Lock()
+			res[client.UID()] = r
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	return res
+}
+
+func parseSendTransactionParams(params map[string]interface{}, clientUid string) ([]TransactionOption, error) {
 	var options []TransactionOption
 
 	if noSend, ok := params["no_send"].(bool); ok {
@@ -261,6 +439,40 @@ func parseSendTransactionParams(params map[string]interface{}, clientUid string)
 		options = append(options, WithTransactionGasPriceMultiplier(uint64(gasPriceMultiplier)))
 	}
 
+	if blobCount, ok := params["blob_count"].(int64); ok {
+		options = append(options, WithTransactionBlobCount(uint64(blobCount)))
+	}
+
+	if randomBlobs, ok := params["random_blobs"].(bool); ok {
+		options = append(options, WithTransactionRandomBlobs(randomBlobs))
+	}
+
+	if authorizationCount, ok := params["authorization_count"].(int64); ok {
+		options = append(options, WithTransactionAuthorizationCount(uint64(authorizationCount)))
+	}
+
+	if data, ok := params["data"].(string); ok {
+		options = append(options, WithTransactionData(common.FromHex(data)))
+	}
+
+	if to, ok := params["to"].(string); ok && common.IsHexAddress(to) {
+		options = append(options, WithTransactionTo(common.HexToAddress(to)))
+	}
+
+	switch accessList := params["access_list"].(type) {
+	case string:
+		if accessList != "auto" {
+			return nil, fmt.Errorf("unknown access list mode: %q, expected \"auto\" or a list", accessList)
+		}
+		options = append(options, WithTransactionAutoAccessList())
+	case []interface{}:
+		parsed, err := eth.ParseAccessList(accessList)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse access list: %w", err)
+		}
+		options = append(options, WithTransactionAccessList(parsed))
+	}
+
 	if wallets, ok := params["wallets"].([]interface{}); ok {
 		if len(wallets) > 0 {
 			addresses := make([]*common.Address, 0, len(wallets))
@@ -287,5 +499,5 @@ func parseSendTransactionParams(params map[string]interface{}, clientUid string)
 		}
 	}
 
-	return options
+	return options, nil
 }
@@ -1,9 +1,11 @@
 package loadtest
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/mysteryforge/gasper/k6/eth"
@@ -25,6 +27,18 @@ func (m *mockClient) UID() string {
 	return m.uid
 }
 
+func (m *mockClient) StateAccounts() *StateAccounts {
+	return &StateAccounts{}
+}
+
+func (m *mockClient) Ledger(ctx context.Context) (*LedgerState, error) {
+	return nil, fmt.Errorf("ledger is not enabled")
+}
+
+func (m *mockClient) EthClient() *eth.Client {
+	return nil
+}
+
 func (m *mockClient) ChainID(vu modules.VU, metrics *EthMetrics) (*big.Int, error) {
 	if m.ChainIDFunc != nil {
 		return m.ChainIDFunc(vu, metrics)
@@ -64,6 +78,37 @@ func (m *mockClient) SendERC721Transaction(vu modules.VU, metrics *EthMetrics, o
 	return &hash, nil
 }
 
+func (m *mockClient) SendBlobTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
+	hash := common.HexToHash("0x3ad06070b524694608f48556b19fab89d0a5b7b558ce8e753c8648c3e0ca6b38")
+	return &hash, nil
+}
+
+func (m *mockClient) SendSetCodeTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
+	hash := common.HexToHash("0x3ad06070b524694608f48556b19fab89d0a5b7b558ce8e753c8648c3e0ca6b38")
+	return &hash, nil
+}
+
+func (m *mockClient) SendDelegatedTransaction(vu modules.VU, metrics *EthMetrics, options ...TransactionOption) (*common.Hash, error) {
+	hash := common.HexToHash("0x3ad06070b524694608f48556b19fab89d0a5b7b558ce8e753c8648c3e0ca6b38")
+	return &hash, nil
+}
+
+func (m *mockClient) DispatchGas(vu modules.VU, metrics *EthMetrics, duration time.Duration, targetMGas float64, options ...TransactionOption) (*DispatchResult, error) {
+	return &DispatchResult{Duration: duration.Seconds()}, nil
+}
+
+func (m *mockClient) SweepWallets(vu modules.VU, metrics *EthMetrics) (*SweepResult, error) {
+	return &SweepResult{Wallets: 2, Swept: 2, Recovered: "1000"}, nil
+}
+
+func (m *mockClient) NonceStates(vu modules.VU, metrics *EthMetrics) ([]*eth.NonceState, error) {
+	return []*eth.NonceState{{Address: "0x653DB51224aBa51949534A895f522e50687f3C13", Next: 5, InFlight: []uint64{4}}}, nil
+}
+
+func (m *mockClient) Dispatch(vu modules.VU, metrics *EthMetrics, txType eth.TransactionType, duration time.Duration, targetTPS uint64, options ...TransactionOption) (*DispatchResult, error) {
+	return &DispatchResult{Duration: duration.Seconds(), TargetRate: float64(targetTPS), AchievedRate: float64(targetTPS)}, nil
+}
+
 func (m *mockClient) DeployContract(vu modules.VU, params *eth.DeployContractParams) (*common.Address, *common.Hash, *common.Address, error) {
 	addr := common.HexToAddress("0x2A71e39B76B99645FDaFDfa9d38c0a51815d0941")
 	hash := common.HexToHash("0x8bdde6587bb8f486bb71a605939bbdd19084e64ed8569bc21e92d4d279cb16c9")
@@ -187,6 +232,26 @@ func TestSendTransaction(t *testing.T) {
 		assert.Equal(t, "0x3ad06070b524694608f48556b19fab89d0a5b7b558ce8e753c8648c3e0ca6b38", results["0"].Data)
 		assert.Equal(t, assert.AnError, results["1"].Err)
 	})
+
+	t.Run("malformed access list", func(t *testing.T) {
+		clients := &Clients{
+			list: []Client{
+				&mockClient{uid: "0"},
+			},
+		}
+		results := clients.SendTransaction(nil, nil, map[string]interface{}{"access_list": []interface{}{"0x01"}})
+		assert.ErrorContains(t, results["0"].Err, "failed to parse access list")
+	})
+
+	t.Run("unknown access list mode", func(t *testing.T) {
+		clients := &Clients{
+			list: []Client{
+				&mockClient{uid: "0"},
+			},
+		}
+		results := clients.SendTransaction(nil, nil, map[string]interface{}{"access_list": "Auto"})
+		assert.ErrorContains(t, results["0"].Err, "unknown access list mode")
+	})
 }
 
 func TestSendERC20Transaction(t *testing.T) {
@@ -285,6 +350,93 @@ func TestTxContract(t *testing.T) {
 	})
 }
 
+func TestTxInfoByHash(t *testing.T) {
+	clients := &Clients{
+		list: []Client{
+			&mockClient{uid: "0"},
+			&mockClient{uid: "1"},
+		},
+	}
+	results := clients.TxInfoByHash(nil, nil, "0x3ad06070b524694608f48556b19fab89d0a5b7b558ce8e753c8648c3e0ca6b38")
+	assert.Equal(t, 2, len(results))
+	assert.NoError(t, results["0"].Err)
+	assert.NoError(t, results["1"].Err)
+
+	results = clients.TxInfoByHash(nil, nil, "0x3ad0")
+	assert.ErrorContains(t, results["1"].Err, "valid hash")
+}
+
+func TestDispatch(t *testing.T) {
+	t.Run("successful dispatch", func(t *testing.T) {
+		clients := &Clients{
+			list: []Client{
+				&mockClient{uid: "0"},
+				&mockClient{uid: "1"},
+			},
+		}
+		results := clients.Dispatch(nil, nil, map[string]interface{}{"duration": "10s", "target_tps": int64(100)})
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, &DispatchResult{Duration: 10, TargetRate: 100, AchievedRate: 100}, results["0"].Data)
+		assert.Equal(t, &DispatchResult{Duration: 10, TargetRate: 100, AchievedRate: 100}, results["1"].Data)
+	})
+
+	t.Run("invalid duration", func(t *testing.T) {
+		clients := &Clients{
+			list: []Client{
+				&mockClient{uid: "0"},
+			},
+		}
+		results := clients.Dispatch(nil, nil, map[string]interface{}{"duration": "ten seconds"})
+		assert.Equal(t, 1, len(results))
+		assert.ErrorContains(t, results["0"].Err, "invalid duration")
+	})
+}
+
+func TestDispatchGas(t *testing.T) {
+	t.Run("successful gas dispatch", func(t *testing.T) {
+		clients := &Clients{
+			list: []Client{
+				&mockClient{uid: "0"},
+				&mockClient{uid: "1"},
+			},
+		}
+		results := clients.DispatchGas(nil, nil, map[string]interface{}{"duration": "1m", "target_mgas": 1.5})
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, &DispatchResult{Duration: 60}, results["0"].Data)
+		assert.Nil(t, results["1"].Err)
+	})
+}
+
+func TestSweepWallets(t *testing.T) {
+	t.Run("successful sweep", func(t *testing.T) {
+		clients := &Clients{
+			list: []Client{
+				&mockClient{uid: "0"},
+				&mockClient{uid: "1"},
+			},
+		}
+		results := clients.SweepWallets(nil, nil)
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, &SweepResult{Wallets: 2, Swept: 2, Recovered: "1000"}, results["0"].Data)
+		assert.Nil(t, results["1"].Err)
+	})
+}
+
+func TestNonceStates(t *testing.T) {
+	clients := &Clients{
+		list: []Client{
+			&mockClient{uid: "0"},
+			&mockClient{uid: "1"},
+		},
+	}
+	results := clients.NonceStates(nil, nil)
+	assert.Equal(t, 2, len(results))
+	states, ok := results["0"].Data.([]*eth.NonceState)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(5), states[0].Next)
+	assert.Nil(t, results["1"].Err)
+}
+
 func TestReportBlockMetrics(t *testing.T) {
 	t.Run("successful metrics reporting", func(t *testing.T) {
 		clients := &Clients{
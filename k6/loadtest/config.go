@@ -6,7 +6,11 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteryforge/gasper/k6/eth"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,7 +19,7 @@ const (
 )
 
 type clientConfig struct {
-	HTTP string `yaml:"http" js:"http"`
+	HTTP string `yaml:"http" js:"http"` // http(s) or ws(s) URL, or IPC path, of the node
 
 	PrivateKeys        []string `yaml:"private_keys,omitempty" js:"privateKeys,omitempty"`                // private key of the account which will be used to fund new wallets
 	BatchFunderAddress string   `yaml:"batch_funder_address,omitempty" js:"batchFunderAddress,omitempty"` // address of the batch funder contract
@@ -24,6 +28,20 @@ type clientConfig struct {
 	FundAmount big.Int  `yaml:"fund_amount,omitempty" js:"fundAmount,omitempty"` // amount to fund new wallets with
 	Wallets    []string `yaml:"wallets,omitempty" js:"wallets,omitempty"`        // prefunded wallets
 
+	ReuseWallets     bool    `yaml:"reuse_wallets,omitempty" js:"reuseWallets,omitempty"`         // whether to load the new wallets stored in the database by previous runs
+	WalletPassphrase string  `yaml:"wallet_passphrase,omitempty" js:"walletPassphrase,omitempty"` // passphrase the stored wallets are encrypted with
+	TopUpThreshold   big.Int `yaml:"top_up_threshold,omitempty" js:"topUpThreshold,omitempty"`    // balance below which reused wallets are funded again, half of fund_amount by default
+
+	RefillThreshold big.Int       `yaml:"refill_threshold,omitempty" js:"refillThreshold,omitempty"` // balance below which testers are taken out of rotation and refilled with fund_amount while running, 0 disables refills
+	RefillInterval  time.Duration `yaml:"refill_interval,omitempty" js:"refillInterval,omitempty"`   // time between balance checks, 10s by default
+
+	NonceCheckInterval time.Duration `yaml:"nonce_check_interval,omitempty" js:"nonceCheckInterval,omitempty"` // time between checks of the tester nonces against the chain, gaps are filled with a replacement transaction, 10s by default and negative to disable
+
+	SweepOnClose bool `yaml:"sweep_on_close,omitempty" js:"sweepOnClose,omitempty"` // whether to send the balance of new wallets back to a sponsor on close
+	SweepERC20   bool `yaml:"sweep_erc20,omitempty" js:"sweepErc20,omitempty"`      // whether sweeps also send ERC20 tokens back
+
+	HDWallets *hdWalletConfig `yaml:"hd_wallets,omitempty" js:"hdWallets,omitempty"` // sponsor and prefunded tester wallets derived from a mnemonic
+
 	TargetAddresses    []string `yaml:"target_addresses,omitempty" js:"targetAddresses,omitempty"`        // target address for transactions
 	NumTargetAddresses uint64   `yaml:"num_target_addresses,omitempty" js:"numTargetAddresses,omitempty"` // number of new target addresses to use`
 
@@ -38,11 +56,76 @@ type clientConfig struct {
 	RateLimite        *uint64 `yaml:"rate_limit,omitempty" js:"rateLimit,omitempty"`                  // rate limit for transaction sending in tx/s
 	AdaptiveRateLimit bool    `yaml:"adaptive_rate_limit,omitempty" js:"adaptiveRateLimit,omitempty"` // whether to use adaptive rate limiting, using tx pool size
 
+	RateController *rateControllerConfig `yaml:"rate_controller,omitempty" js:"rateController,omitempty"` // controller of the adaptive rate limit, implies adaptive_rate_limit
+
+	ReceiptReconciler *receiptReconcilerConfig `yaml:"receipt_reconciler,omitempty" js:"receiptReconciler,omitempty"` // checks the receipts of transactions sent without confirmation in the background
+	JournalExport     *journalExportConfig     `yaml:"journal_export,omitempty" js:"journalExport,omitempty"`         // writes the journal of the sent transactions to a file on close
+	Ledger            bool                     `yaml:"ledger,omitempty" js:"ledger,omitempty"`                        // whether to track the expected balances of the target addresses and the ERC20 balances, for checkLedger
+
 	MinGasPrice uint64 `yaml:"min_gas_price,omitempty" js:"minGasPrice,omitempty"` // minimum gas price to use for transactions
 
+	TargetTPS uint64          `yaml:"target_tps,omitempty" js:"targetTps,omitempty"` // target rate of dispatch in tx/s, start rate when stages are set
+	Stages    []dispatchStage `yaml:"stages,omitempty" js:"stages,omitempty"`        // ramp schedule of dispatch
+
+	TargetMGas float64       `yaml:"target_mgas,omitempty" js:"targetMgas,omitempty"` // target gas throughput of gas dispatch in MGas/s
+	GasMix     []gasMixEntry `yaml:"gas_mix,omitempty" js:"gasMix,omitempty"`         // transaction kinds of gas dispatch and their share of the gas
+
+	DelegationAddress string `yaml:"delegation_address,omitempty" js:"delegationAddress,omitempty"` // contract tester wallets delegate to in EIP-7702 set code transactions
+
 	DBPath string `yaml:"db_path,omitempty" js:"dbPath,omitempty"` // path to the database where we store transaction hashes
 }
 
+type indexRange struct {
+	Start uint32 `yaml:"start" js:"start"` // first child index
+	Count uint32 `yaml:"count" js:"count"` // number of wallets
+}
+
+func (r indexRange) overlaps(o indexRange) bool {
+	return r.Count > 0 && o.Count > 0 && r.Start < o.Start+o.Count && o.Start < r.Start+r.Count
+}
+
+type hdWalletConfig struct {
+	Mnemonic       string     `yaml:"mnemonic" js:"mnemonic"`                                  // BIP-39 mnemonic
+	Passphrase     string     `yaml:"passphrase,omitempty" js:"passphrase,omitempty"`          // BIP-39 passphrase
+	DerivationPath string     `yaml:"derivation_path,omitempty" js:"derivationPath,omitempty"` // base path wallets are derived at, m/44'/60'/0'/0 by default
+	Sponsors       indexRange `yaml:"sponsors,omitempty" js:"sponsors,omitempty"`              // indexes of the sponsor wallets
+	Testers        indexRange `yaml:"testers,omitempty" js:"testers,omitempty"`                // indexes of the prefunded tester wallets
+}
+
+func (cfg *hdWalletConfig) numSponsors() int {
+	if cfg == nil {
+		return 0
+	}
+	return int(cfg.Sponsors.Count)
+}
+
+func (cfg *hdWalletConfig) numTesters() int {
+	if cfg == nil {
+		return 0
+	}
+	return int(cfg.Testers.Count)
+}
+
+func (cfg *hdWalletConfig) validate() error {
+	if cfg.Sponsors.Count == 0 && cfg.Testers.Count == 0 {
+		return fmt.Errorf("sponsors or testers count should be greater then 0")
+	}
+	if cfg.Sponsors.overlaps(cfg.Testers) {
+		return fmt.Errorf("sponsors and testers index ranges overlap")
+	}
+	if _, err := eth.MnemonicToSeed(cfg.Mnemonic, cfg.Passphrase); err != nil {
+		return err
+	}
+	path := cfg.DerivationPath
+	if path == "" {
+		path = eth.DefaultDerivationPath
+	}
+	if _, err := accounts.ParseDerivationPath(path); err != nil {
+		return fmt.Errorf("invalid derivation_path: %w", err)
+	}
+	return nil
+}
+
 func ReadConfigYML(pth string) ([]*clientConfig, error) {
 	if pth == "" {
 		return nil, fmt.Errorf("no config file provided")
@@ -78,22 +161,52 @@ func ReadConfigYML(pth string) ([]*clientConfig, error) {
 			cfg.RateLimite = new(uint64)
 			*cfg.RateLimite = 4
 		}
+		if cfg.TopUpThreshold.Sign() == 0 {
+			cfg.TopUpThreshold.Div(&cfg.FundAmount, big.NewInt(2))
+		}
 	}
 
 	return cfgs, nil
 }
 
 func validateClientConfig(cfg *clientConfig) error {
-	uri, err := url.Parse(cfg.HTTP)
-	if err != nil {
-		return fmt.Errorf("failed to parse Http URL: %w", err)
+	if !eth.IsIPCPath(cfg.HTTP) {
+		uri, err := url.Parse(cfg.HTTP)
+		if err != nil {
+			return fmt.Errorf("failed to parse Http URL: %w", err)
+		}
+
+		switch uri.Scheme {
+		case "http", "https", "ws", "wss":
+		default:
+			return fmt.Errorf("invalid Http URL scheme: %s", uri.Scheme)
+		}
+	}
+
+	if cfg.TopUpThreshold.Sign() < 0 {
+		return fmt.Errorf("top_up_threshold should not be negative: %v", &cfg.TopUpThreshold)
+	}
+
+	if cfg.RefillThreshold.Sign() < 0 {
+		return fmt.Errorf("refill_threshold should not be negative: %v", &cfg.RefillThreshold)
 	}
 
-	if uri.Scheme != "http" && uri.Scheme != "https" {
-		return fmt.Errorf("invalid Http URL scheme: %s", uri.Scheme)
+	if cfg.HDWallets != nil {
+		if err := cfg.HDWallets.validate(); err != nil {
+			return fmt.Errorf("invalid hd_wallets: %w", err)
+		}
+	}
+	numSponsors := len(cfg.PrivateKeys) + cfg.HDWallets.numSponsors()
+
+	if cfg.RefillThreshold.Sign() > 0 && numSponsors == 0 {
+		return fmt.Errorf("private keys is required when refill_threshold > 0")
+	}
+
+	if cfg.RefillThreshold.Sign() > 0 && cfg.FundAmount.Sign() <= 0 {
+		return fmt.Errorf("fund amount should be greater then 0 when refill_threshold > 0")
 	}
 
-	if cfg.NumWallets > 0 && len(cfg.PrivateKeys) == 0 {
+	if cfg.NumWallets > 0 && numSponsors == 0 {
 		return fmt.Errorf("private keys is required when num_wallets > 0")
 	}
 
@@ -101,12 +214,12 @@ func validateClientConfig(cfg *clientConfig) error {
 		return fmt.Errorf("fund amount should be greater then 0: %v when num_wallets > 0", cfg.FundAmount)
 	}
 
-	numWallets := len(cfg.Wallets) + int(cfg.NumWallets)
+	numWallets := len(cfg.Wallets) + cfg.HDWallets.numTesters() + int(cfg.NumWallets)
 	if numWallets > 0 && len(cfg.TargetAddresses) == 0 && cfg.NumTargetAddresses == 0 {
 		return fmt.Errorf("target address is required when num_wallets > 0 or wallets are provided")
 	}
 
-	if cfg.ERC20 && cfg.ERC20Address == "" && len(cfg.PrivateKeys) == 0 {
+	if cfg.ERC20 && cfg.ERC20Address == "" && numSponsors == 0 {
 		return fmt.Errorf("erc20_address is required when erc20_test is true and private_keys is not set")
 	}
 
@@ -118,7 +231,7 @@ func validateClientConfig(cfg *clientConfig) error {
 		return fmt.Errorf("erc20_mint_amount should be greater then 0 when num_wallets > 0")
 	}
 
-	if cfg.ERC721 && cfg.ERC721Address == "" && len(cfg.PrivateKeys) == 0 {
+	if cfg.ERC721 && cfg.ERC721Address == "" && numSponsors == 0 {
 		return fmt.Errorf("erc721_address is required when erc721_test is true and private_keys is not set")
 	}
 
@@ -134,6 +247,43 @@ func validateClientConfig(cfg *clientConfig) error {
 		return fmt.Errorf("num_wallets should be less then %d when erc721_test is true", MaxWalletsNumContract)
 	}
 
+	for i, stage := range cfg.Stages {
+		if stage.Duration <= 0 {
+			return fmt.Errorf("stage %d duration should be greater then 0", i)
+		}
+	}
+
+	if cfg.RateController != nil {
+		if err := cfg.RateController.validate(); err != nil {
+			return fmt.Errorf("invalid rate_controller: %w", err)
+		}
+	}
+
+	if cfg.ReceiptReconciler != nil {
+		if err := cfg.ReceiptReconciler.validate(); err != nil {
+			return fmt.Errorf("invalid receipt_reconciler: %w", err)
+		}
+	}
+
+	if cfg.JournalExport != nil {
+		if err := cfg.JournalExport.validate(); err != nil {
+			return fmt.Errorf("invalid journal_export: %w", err)
+		}
+	}
+
+	for i, entry := range cfg.GasMix {
+		if entry.Weight == 0 {
+			return fmt.Errorf("gas_mix entry %d weight should be greater then 0", i)
+		}
+		if entry.TxType == string(eth.TransactionTypeContract) && !common.IsHexAddress(entry.To) {
+			return fmt.Errorf("gas_mix entry %d requires a contract address: %s", i, entry.To)
+		}
+	}
+
+	if cfg.DelegationAddress != "" && !common.IsHexAddress(cfg.DelegationAddress) {
+		return fmt.Errorf("invalid delegation_address: %s", cfg.DelegationAddress)
+	}
+
 	if cfg.DBPath != "" && filepath.Clean(cfg.DBPath) != cfg.DBPath && filepath.Ext(cfg.DBPath) != ".db" {
 		return fmt.Errorf("invalid db path: %s", cfg.DBPath)
 	}
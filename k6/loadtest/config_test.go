@@ -5,7 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/mysteryforge/gasper/k6/eth"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -84,6 +86,112 @@ erc721_mint: true
 		assert.True(t, configs[1].ERC721)
 	})
 
+	t.Run("dispatch stages", func(t *testing.T) {
+		tmpFile := createTempConfigFile(t, `
+- http: http://localhost:8123
+  target_tps: 50
+  stages:
+    - duration: 30s
+      target: 500
+    - duration: 1m
+      target: 500
+`)
+		defer os.Remove(tmpFile) // nolint: errcheck
+
+		configs, err := ReadConfigYML(tmpFile)
+		require.NoError(t, err)
+		require.Len(t, configs, 1)
+		assert.Equal(t, uint64(50), configs[0].TargetTPS)
+		assert.Equal(t, []dispatchStage{
+			{Duration: 30 * time.Second, Target: 500},
+			{Duration: time.Minute, Target: 500},
+		}, configs[0].Stages)
+	})
+
+	t.Run("rate controller", func(t *testing.T) {
+		tmpFile := createTempConfigFile(t, `
+- http: http://localhost:8123
+  rate_limit: 100
+  rate_controller:
+    type: pid
+    interval: 5s
+    max_rate: 2000
+    target_tx_pool_size: 500
+    kp: 0.2
+`)
+		defer os.Remove(tmpFile) // nolint: errcheck
+
+		configs, err := ReadConfigYML(tmpFile)
+		require.NoError(t, err)
+		require.Len(t, configs, 1)
+
+		rc := configs[0].RateController
+		require.NotNil(t, rc)
+		assert.Equal(t, 5*time.Second, rc.interval())
+		assert.Equal(t, 2000.0, rc.maxRate())
+
+		controller, err := rc.controller()
+		require.NoError(t, err)
+		pid, ok := controller.(*eth.PIDController)
+		require.True(t, ok)
+		assert.Equal(t, uint64(500), pid.TargetTxPoolSize)
+		assert.Equal(t, 0.2, pid.Kp)
+		assert.Equal(t, 0.01, pid.Ki)
+	})
+
+	t.Run("hd wallets", func(t *testing.T) {
+		tmpFile := createTempConfigFile(t, `
+- http: http://localhost:8123
+  hd_wallets:
+    mnemonic: test test test test test test test test test test test junk
+    sponsors:
+      start: 0
+      count: 1
+    testers:
+      start: 1
+      count: 9
+  num_wallets: 10
+  fund_amount: 1000000
+  target_addresses: [0xc78260046895c358dE4bE97210Efca3900544905]
+`)
+		defer os.Remove(tmpFile) // nolint: errcheck
+
+		configs, err := ReadConfigYML(tmpFile)
+		require.NoError(t, err)
+		require.Len(t, configs, 1)
+		hd := configs[0].HDWallets
+		require.NotNil(t, hd)
+		assert.Equal(t, indexRange{Start: 0, Count: 1}, hd.Sponsors)
+		assert.Equal(t, indexRange{Start: 1, Count: 9}, hd.Testers)
+	})
+
+	t.Run("reuse wallets", func(t *testing.T) {
+		tmpFile := createTempConfigFile(t, `
+- http: http://localhost:8123
+  private_keys: [0x52fb3ff54731f7609d97b6b0195aa1fac56b95141c4b71eaa4f08af23558c63b]
+  num_wallets: 10
+  fund_amount: 1000000
+  target_addresses: [0xc78260046895c358dE4bE97210Efca3900544905]
+  reuse_wallets: true
+  wallet_passphrase: secret
+- http: http://localhost:8124
+  private_keys: [0x52fb3ff54731f7609d97b6b0195aa1fac56b95141c4b71eaa4f08af23558c63b]
+  num_wallets: 10
+  fund_amount: 1000000
+  target_addresses: [0xc78260046895c358dE4bE97210Efca3900544905]
+  top_up_threshold: 100
+`)
+		defer os.Remove(tmpFile) // nolint: errcheck
+
+		configs, err := ReadConfigYML(tmpFile)
+		require.NoError(t, err)
+		require.Len(t, configs, 2)
+		assert.True(t, configs[0].ReuseWallets)
+		assert.Equal(t, "secret", configs[0].WalletPassphrase)
+		assert.Equal(t, big.NewInt(500000), &configs[0].TopUpThreshold)
+		assert.Equal(t, big.NewInt(100), &configs[1].TopUpThreshold)
+	})
+
 	t.Run("invalid file path", func(t *testing.T) {
 		_, err := ReadConfigYML("")
 		assert.Error(t, err)
@@ -186,15 +294,106 @@ func TestValidateClientConfig(t *testing.T) {
 		assert.Contains(t, err.Error(), "invalid Http URL scheme")
 	})
 
+	t.Run("websocket and IPC endpoints", func(t *testing.T) {
+		for _, uri := range []string{"ws://localhost:8546", "wss://localhost:8546", "/tmp/geth.ipc"} {
+			cfg := &clientConfig{
+				HTTP: uri,
+			}
+			assert.NoError(t, validateClientConfig(cfg), uri)
+		}
+	})
+
 	t.Run("invalid HTTP scheme", func(t *testing.T) {
 		cfg := &clientConfig{
-			HTTP: "ws://localhost:8123",
+			HTTP: "ftp://localhost:8123",
 		}
 		err := validateClientConfig(cfg)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid Http URL scheme")
 	})
 
+	t.Run("invalid stage duration", func(t *testing.T) {
+		cfg := &clientConfig{
+			HTTP:   "http://localhost:8123",
+			Stages: []dispatchStage{{Duration: 0, Target: 100}},
+		}
+		err := validateClientConfig(cfg)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "stage 0 duration should be greater then 0")
+	})
+
+	t.Run("invalid rate controller", func(t *testing.T) {
+		cfg := &clientConfig{
+			HTTP:           "http://localhost:8123",
+			RateController: &rateControllerConfig{Type: "bbr"},
+		}
+		err := validateClientConfig(cfg)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown rate controller")
+	})
+
+	t.Run("invalid receipt reconciler sample rate", func(t *testing.T) {
+		rate := 1.5
+		cfg := &clientConfig{
+			HTTP:              "http://localhost:8123",
+			ReceiptReconciler: &receiptReconcilerConfig{SampleRate: &rate},
+		}
+		err := validateClientConfig(cfg)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "sample_rate should be in (0, 1]")
+	})
+
+	t.Run("invalid journal export format", func(t *testing.T) {
+		cfg := &clientConfig{
+			HTTP:          "http://localhost:8123",
+			JournalExport: &journalExportConfig{Path: "journal.parquet", Format: "parquet"},
+		}
+		err := validateClientConfig(cfg)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown format: parquet")
+	})
+
+	t.Run("overlapping hd wallet indexes", func(t *testing.T) {
+		cfg := &clientConfig{
+			HTTP: "http://localhost:8123",
+			HDWallets: &hdWalletConfig{
+				Mnemonic: "test test test test test test test test test test test junk",
+				Sponsors: indexRange{Start: 0, Count: 2},
+				Testers:  indexRange{Start: 1, Count: 10},
+			},
+			TargetAddresses: []string{"0xc78260046895c358dE4bE97210Efca3900544905"},
+		}
+		err := validateClientConfig(cfg)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "index ranges overlap")
+	})
+
+	t.Run("invalid mnemonic", func(t *testing.T) {
+		cfg := &clientConfig{
+			HTTP: "http://localhost:8123",
+			HDWallets: &hdWalletConfig{
+				Mnemonic: "test test test",
+				Sponsors: indexRange{Count: 1},
+			},
+		}
+		err := validateClientConfig(cfg)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid mnemonic")
+	})
+
+	t.Run("refill without sponsors", func(t *testing.T) {
+		cfg := &clientConfig{
+			HTTP:            "http://localhost:8123",
+			Wallets:         []string{"wallet1"},
+			TargetAddresses: []string{"0xc78260046895c358dE4bE97210Efca3900544905"},
+			FundAmount:      *big.NewInt(1000),
+			RefillThreshold: *big.NewInt(100),
+		}
+		err := validateClientConfig(cfg)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "private keys is required when refill_threshold > 0")
+	})
+
 	t.Run("missing private key with num_wallets", func(t *testing.T) {
 		cfg := &clientConfig{
 			HTTP:       "http://localhost:8123",
@@ -228,6 +427,16 @@ func TestValidateClientConfig(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "target address is required")
 	})
+
+	t.Run("invalid delegation address", func(t *testing.T) {
+		cfg := &clientConfig{
+			HTTP:              "http://localhost:8123",
+			DelegationAddress: "not-an-address",
+		}
+		err := validateClientConfig(cfg)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid delegation_address")
+	})
 }
 
 // Helper function to create temporary config file
@@ -0,0 +1,232 @@
+package loadtest
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"go.k6.io/k6/js/modules"
+)
+
+const (
+	dispatchTick           = 10 * time.Millisecond
+	dispatchReportInterval = time.Second
+)
+
+type dispatchStage struct {
+	Duration time.Duration `yaml:"duration" js:"duration"` // duration of the stage, e.g. 30s
+	Target   uint64        `yaml:"target" js:"target"`     // target tx/s at the end of the stage
+}
+
+// rateSchedule is the target rate of a dispatch over time. The rate ramps
+// linearly from the previous target to the target of each stage, like the
+// k6 ramping-arrival-rate executor.
+type rateSchedule struct {
+	start  float64
+	stages []dispatchStage
+}
+
+// newRateSchedule returns a schedule of stages starting at targetTPS, or a
+// constant targetTPS for duration when there are no stages.
+func newRateSchedule(targetTPS uint64, stages []dispatchStage, duration time.Duration) (*rateSchedule, error) {
+	if len(stages) == 0 {
+		if targetTPS == 0 {
+			return nil, fmt.Errorf("target_tps or stages are required to dispatch")
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("duration is required to dispatch a constant target_tps")
+		}
+		stages = []dispatchStage{{Duration: duration, Target: targetTPS}}
+	}
+	return &rateSchedule{start: float64(targetTPS), stages: stages}, nil
+}
+
+func (s *rateSchedule) Duration() time.Duration {
+	var d time.Duration
+	for _, stage := range s.stages {
+		d += stage.Duration
+	}
+	return d
+}
+
+// RateAt returns the target rate in tx/s at elapsed, and false once the
+// schedule is over.
+func (s *rateSchedule) RateAt(elapsed time.Duration) (float64, bool) {
+	from := s.start
+	for _, stage := range s.stages {
+		to := float64(stage.Target)
+		if elapsed < stage.Duration {
+			return from + (to-from)*float64(elapsed)/float64(stage.Duration), true
+		}
+		elapsed -= stage.Duration
+		from = to
+	}
+	return 0, false
+}
+
+type DispatchResult struct {
+	Duration     float64 `json:"duration"`      // seconds
+	TargetRate   float64 `json:"target_rate"`   // average target tx/s
+	AchievedRate float64 `json:"achieved_rate"` // average sent tx/s
+	Sent         uint64  `json:"sent"`
+	Failed       uint64  `json:"failed"`
+	Dropped      uint64  `json:"dropped"` // scheduled sends without an available wallet
+}
+
+// dispatcher sends transactions at the rate returned by rate, independent of
+// how long each send takes (open model). Every send locks an available tester
+// wallet, a send scheduled when all wallets are busy is dropped. next returns
+// the builder of each send, and an optional callback for its hash.
+type dispatcher struct {
+	client *DefaultClient
+	label  eth.TransactionType
+	rate   func(elapsed time.Duration) (float64, bool)
+	next   func() (PayloadBuilder, func(hash common.Hash))
+	opts   *TransactionOptions
+
+	sent    atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+	wg      *sync.WaitGroup
+}
+
+func (d *dispatcher) run(vu modules.VU, metrics *EthMetrics) *DispatchResult {
+	ctx := vu.Context()
+	ticker := time.NewTicker(dispatchTick)
+	defer ticker.Stop()
+
+	var (
+		start      = time.Now()
+		last       = start
+		due        float64 // sends due but not started yet
+		scheduled  float64 // sends scheduled in the current report interval
+		lastReport = start
+		lastSent   uint64
+		total      float64
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			d.wg.Wait()
+			return d.result(time.Since(start), total)
+		case now := <-ticker.C:
+			rate, ok := d.rate(now.Sub(start))
+			if !ok {
+				d.wg.Wait()
+				return d.result(time.Since(start), total)
+			}
+
+			step := rate * now.Sub(last).Seconds()
+			last = now
+			due += step
+			scheduled += step
+			total += step
+			for ; due >= 1; due-- {
+				d.wg.Add(1)
+				go d.send(vu, metrics)
+			}
+
+			if elapsed := now.Sub(lastReport); elapsed >= dispatchReportInterval {
+				sent := d.sent.Load() + d.failed.Load()
+				ReportDispatchRateFromStats(vu, metrics, d.client.uid, d.label,
+					scheduled/elapsed.Seconds(),
+					float64(sent-lastSent)/elapsed.Seconds(),
+					now,
+				)
+				lastReport, lastSent, scheduled = now, sent, 0
+			}
+		}
+	}
+}
+
+func (d *dispatcher) send(vu modules.VU, metrics *EthMetrics) {
+	defer d.wg.Done()
+
+	wallet, release, err := d.client.acquireWallet(nil)
+	if err != nil {
+		d.dropped.Add(1)
+		return
+	}
+	defer release()
+
+	target := d.client.targetAddresses.Random()
+	if target == nil {
+		d.dropped.Add(1)
+		return
+	}
+
+	builder, onSent := d.next()
+	nonce := d.client.nonces.Next(wallet, d.opts.OffsetNonce)
+	hash, err := d.client.sendPayload(vu, metrics, builder, wallet, *target, nonce, d.opts)
+	d.client.nonces.Done(wallet, nonce, hash != (common.Hash{}) && !d.opts.NoSend, err)
+	if err != nil {
+		d.failed.Add(1)
+		if vu.Context().Err() == nil {
+			d.client.log.Error(err, "error in dispatching transaction", "call", "dispatch"+builder.Call())
+		}
+		return
+	}
+	d.sent.Add(1)
+	if onSent != nil {
+		onSent(hash)
+	}
+}
+
+func (d *dispatcher) result(elapsed time.Duration, scheduled float64) *DispatchResult {
+	res := &DispatchResult{
+		Duration: elapsed.Seconds(),
+		Sent:     d.sent.Load(),
+		Failed:   d.failed.Load(),
+		Dropped:  d.dropped.Load(),
+	}
+	if res.Duration > 0 {
+		res.TargetRate = scheduled / res.Duration
+		res.AchievedRate = float64(res.Sent+res.Failed) / res.Duration
+	}
+	return res
+}
+
+// Dispatch sends transactions of txType at the target_tps or stages of the
+// client config, until the schedule is over. duration is only used with a
+// constant target_tps, targetTPS overrides the configured one when set.
+func (c *DefaultClient) Dispatch(vu modules.VU, metrics *EthMetrics, txType eth.TransactionType, duration time.Duration, targetTPS uint64, options ...TransactionOption) (*DispatchResult, error) {
+	opts := DefaultTransactionOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+	if opts.WaitForConfirmation && opts.OffsetNonce > 0 {
+		return nil, fmt.Errorf("cannot use offset nonce with confirmation")
+	}
+
+	builder, err := c.payloadBuilder(txType, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := c.dispatchStages
+	if targetTPS == 0 {
+		targetTPS = c.targetTPS
+	} else {
+		stages = nil
+	}
+	schedule, err := newRateSchedule(targetTPS, stages, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &dispatcher{
+		client: c,
+		label:  txType,
+		rate:   schedule.RateAt,
+		next: func() (PayloadBuilder, func(common.Hash)) {
+			return builder, nil
+		},
+		opts: opts,
+		wg:   &sync.WaitGroup{},
+	}
+	c.log.Info("dispatching transactions", "tx_type", txType, "target_tps", targetTPS, "stages", len(stages), "duration", schedule.Duration())
+	return d.run(vu, metrics), nil
+}
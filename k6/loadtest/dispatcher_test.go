@@ -0,0 +1,63 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateSchedule(t *testing.T) {
+	t.Run("constant target", func(t *testing.T) {
+		s, err := newRateSchedule(100, nil, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, s.Duration())
+
+		rate, ok := s.RateAt(0)
+		assert.True(t, ok)
+		assert.Equal(t, 100.0, rate)
+		rate, ok = s.RateAt(59 * time.Second)
+		assert.True(t, ok)
+		assert.Equal(t, 100.0, rate)
+		_, ok = s.RateAt(time.Minute)
+		assert.False(t, ok)
+	})
+
+	t.Run("ramping stages", func(t *testing.T) {
+		s, err := newRateSchedule(0, []dispatchStage{
+			{Duration: 10 * time.Second, Target: 100},
+			{Duration: 20 * time.Second, Target: 100},
+			{Duration: 10 * time.Second, Target: 0},
+		}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 40*time.Second, s.Duration())
+
+		for _, tt := range []struct {
+			elapsed  time.Duration
+			expected float64
+		}{
+			{0, 0},
+			{5 * time.Second, 50},
+			{10 * time.Second, 100},
+			{25 * time.Second, 100},
+			{35 * time.Second, 50},
+		} {
+			rate, ok := s.RateAt(tt.elapsed)
+			assert.True(t, ok)
+			assert.InDelta(t, tt.expected, rate, 0.001, tt.elapsed.String())
+		}
+		_, ok := s.RateAt(40 * time.Second)
+		assert.False(t, ok)
+	})
+
+	t.Run("missing target", func(t *testing.T) {
+		_, err := newRateSchedule(0, nil, time.Minute)
+		assert.ErrorContains(t, err, "target_tps or stages are required")
+	})
+
+	t.Run("missing duration", func(t *testing.T) {
+		_, err := newRateSchedule(100, nil, 0)
+		assert.ErrorContains(t, err, "duration is required")
+	})
+}
@@ -0,0 +1,266 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"go.k6.io/k6/js/modules"
+)
+
+const (
+	gasControlInterval = time.Second
+	gasControllerGain  = 0.5
+	minGasFactor       = 0.1
+	maxGasFactor       = 10
+
+	gasSampleFirst     = 3  // sends of a kind measured before sampling
+	gasSampleEvery     = 20 // then measure every Nth send of a kind
+	gasEstimateAlpha   = 0.2
+	observedGasAlpha   = 0.3
+	gasReceiptTimeout  = 2 * time.Minute
+	defaultEstimateGas = 50_000
+)
+
+type gasMixEntry struct {
+	TxType string `yaml:"tx_type" js:"txType"`                // transaction type, e.g. EIP155, ERC20, ERC721 or CONTRACT
+	Weight uint64 `yaml:"weight" js:"weight"`                 // share of the target gas used by this kind
+	To     string `yaml:"to,omitempty" js:"to,omitempty"`     // contract called by CONTRACT entries
+	Data   string `yaml:"data,omitempty" js:"data,omitempty"` // hex calldata of CONTRACT entries
+}
+
+type gasKind struct {
+	builder  PayloadBuilder
+	weight   float64
+	gas      float64 // gas per transaction, measured from sampled receipts
+	measured bool
+	sends    uint64
+}
+
+// gasController sets the dispatch rate to sustain a target gas per second.
+// Kinds are sent in proportion to weight/gas, so each kind uses its weight
+// share of the gas whatever it costs, and the rate is corrected by the gas
+// per second observed in blocks.
+type gasController struct {
+	target   float64 // gas/s
+	kinds    []*gasKind
+	factor   float64
+	observed float64 // gas/s observed in blocks
+	blocks   int     // blocks observed since the last adjustment
+	mu       *sync.Mutex
+}
+
+func newGasController(targetMGas float64, kinds []*gasKind) *gasController {
+	return &gasController{
+		target: targetMGas * 1_000_000,
+		kinds:  kinds,
+		factor: 1,
+		mu:     &sync.Mutex{},
+	}
+}
+
+// Rate returns the send rate in tx/s.
+func (gc *gasController) Rate() float64 {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	var weights, perGas float64
+	for _, k := range gc.kinds {
+		weights += k.weight
+		perGas += k.weight / k.gas
+	}
+	return gc.factor * gc.target * perGas / weights
+}
+
+// Pick returns the kind of the next send, r is uniform in [0, 1).
+func (gc *gasController) Pick(r float64) *gasKind {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	var total float64
+	for _, k := range gc.kinds {
+		total += k.weight / k.gas
+	}
+	x := r * total
+	for _, k := range gc.kinds {
+		x -= k.weight / k.gas
+		if x < 0 {
+			return k
+		}
+	}
+	return gc.kinds[len(gc.kinds)-1]
+}
+
+// sample reports whether the gas of the next send of k is measured.
+func (gc *gasController) sample(k *gasKind) bool {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	k.sends++
+	return k.sends <= gasSampleFirst || k.sends%gasSampleEvery == 0
+}
+
+func (gc *gasController) ObserveGas(k *gasKind, gasUsed uint64) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	if !k.measured {
+		k.gas = float64(gasUsed)
+		k.measured = true
+		return
+	}
+	k.gas += gasEstimateAlpha * (float64(gasUsed) - k.gas)
+}
+
+func (gc *gasController) ObserveBlock(tb *trackedBlock) {
+	if tb.IntervalMili == 0 {
+		return
+	}
+	gasPerSec := float64(tb.Block.GasUsed) * 1000 / float64(tb.IntervalMili)
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.observed == 0 {
+		gc.observed = gasPerSec
+	} else {
+		gc.observed += observedGasAlpha * (gasPerSec - gc.observed)
+	}
+	gc.blocks++
+}
+
+// Adjust corrects the rate by the error between the target and the observed
+// gas per second, once a block was observed since the last adjustment.
+func (gc *gasController) Adjust() {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	if gc.blocks == 0 {
+		return
+	}
+	gc.blocks = 0
+	gc.factor *= 1 + gasControllerGain*(gc.target-gc.observed)/gc.target
+	gc.factor = min(max(gc.factor, minGasFactor), maxGasFactor)
+}
+
+func (gc *gasController) Observed() float64 {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return gc.observed
+}
+
+func initialGas(txType eth.TransactionType) float64 {
+	switch txType {
+	case eth.TransactionTypeETH, eth.TransactionTypeBlob:
+		return 21_000
+	default:
+		return defaultEstimateGas
+	}
+}
+
+// gasKinds returns the kinds of the configured gas mix, only ETH transfers
+// when there is no mix.
+func (c *DefaultClient) gasKinds(opts *TransactionOptions) ([]*gasKind, error) {
+	mix := c.gasMix
+	if len(mix) == 0 {
+		mix = []gasMixEntry{{TxType: string(eth.TransactionTypeETH), Weight: 1}}
+	}
+
+	kinds := make([]*gasKind, 0, len(mix))
+	for _, entry := range mix {
+		entryOpts := *opts
+		if entry.To != "" {
+			to := common.HexToAddress(entry.To)
+			entryOpts.To = &to
+		}
+		if entry.Data != "" {
+			entryOpts.Data = common.FromHex(entry.Data)
+		}
+
+		txType := eth.TransactionType(entry.TxType)
+		builder, err := c.payloadBuilder(txType, &entryOpts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gas_mix entry %s: %w", entry.TxType, err)
+		}
+		kinds = append(kinds, &gasKind{
+			builder: builder,
+			weight:  float64(entry.Weight),
+			gas:     initialGas(txType),
+		})
+	}
+	return kinds, nil
+}
+
+// DispatchGas sends the configured gas mix for duration, adjusting the rate
+// to sustain targetMGas, or the configured target_mgas when it is 0.
+func (c *DefaultClient) DispatchGas(vu modules.VU, metrics *EthMetrics, duration time.Duration, targetMGas float64, options ...TransactionOption) (*DispatchResult, error) {
+	opts := DefaultTransactionOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+	if opts.WaitForConfirmation && opts.OffsetNonce > 0 {
+		return nil, fmt.Errorf("cannot use offset nonce with confirmation")
+	}
+
+	if targetMGas == 0 {
+		targetMGas = c.targetMGas
+	}
+	if targetMGas <= 0 {
+		return nil, fmt.Errorf("target_mgas is required to dispatch gas")
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("duration is required to dispatch gas")
+	}
+
+	kinds, err := c.gasKinds(opts)
+	if err != nil {
+		return nil, err
+	}
+	gc := newGasController(targetMGas, kinds)
+	removeListener := c.blocks.onBlock(gc.ObserveBlock)
+	defer removeListener()
+
+	ctx, cancel := context.WithCancel(vu.Context())
+	defer cancel()
+
+	lastAdjust := time.Now()
+	d := &dispatcher{
+		client: c,
+		label:  eth.TransactionTypeMix,
+		rate: func(elapsed time.Duration) (float64, bool) {
+			if elapsed >= duration {
+				return 0, false
+			}
+			if now := time.Now(); now.Sub(lastAdjust) >= gasControlInterval {
+				gc.Adjust()
+				ReportGasControllerFromStats(vu, metrics, c.uid, gc.target, gc.Observed(), now)
+				lastAdjust = now
+			}
+			return gc.Rate(), true
+		},
+		next: func() (PayloadBuilder, func(common.Hash)) {
+			k := gc.Pick(rand.Float64())
+			if !gc.sample(k) {
+				return k.builder, nil
+			}
+			return k.builder, func(hash common.Hash) {
+				go c.measureGas(ctx, gc, k, hash)
+			}
+		},
+		opts: opts,
+		wg:   &sync.WaitGroup{},
+	}
+	c.log.Info("dispatching gas", "target_mgas", targetMGas, "kinds", len(kinds), "duration", duration)
+	return d.run(vu, metrics), nil
+}
+
+func (c *DefaultClient) measureGas(ctx context.Context, gc *gasController, k *gasKind, hash common.Hash) {
+	receipt, err := eth.WaitForReceipt(ctx, c.ethClient.Ec, hash, gasReceiptTimeout, 500*time.Millisecond)
+	if err != nil {
+		return
+	}
+	gc.ObserveGas(k, receipt.GasUsed)
+}
@@ -0,0 +1,90 @@
+package loadtest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/mysteryforge/gasper/k6/eth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGasController(t *testing.T) {
+	newKinds := func() []*gasKind {
+		return []*gasKind{
+			{builder: ethTransferPayload{}, weight: 1, gas: 20_000},
+			{builder: erc20TransferPayload{}, weight: 1, gas: 50_000},
+		}
+	}
+	block := func(gasUsed uint64, intervalMili uint64) *trackedBlock {
+		return &trackedBlock{
+			Block:        &eth.SlimBlock{Number: big.NewInt(1), GasUsed: gasUsed},
+			IntervalMili: intervalMili,
+		}
+	}
+
+	t.Run("rate splits the gas by weight", func(t *testing.T) {
+		gc := newGasController(1, newKinds())
+		// 500k gas/s per kind: 25 transfers and 10 erc20 transfers
+		assert.InDelta(t, 35.0, gc.Rate(), 0.001)
+	})
+
+	t.Run("pick in proportion to weight over gas", func(t *testing.T) {
+		gc := newGasController(1, newKinds())
+		kinds := gc.kinds
+		assert.Equal(t, kinds[0], gc.Pick(0))
+		assert.Equal(t, kinds[0], gc.Pick(0.7))
+		assert.Equal(t, kinds[1], gc.Pick(0.72))
+		assert.Equal(t, kinds[1], gc.Pick(0.999))
+	})
+
+	t.Run("measured gas", func(t *testing.T) {
+		gc := newGasController(1, newKinds())
+		k := gc.kinds[1]
+		gc.ObserveGas(k, 40_000)
+		assert.Equal(t, 40_000.0, k.gas)
+		gc.ObserveGas(k, 50_000)
+		assert.Equal(t, 42_000.0, k.gas)
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		gc := newGasController(1, newKinds())
+		k := gc.kinds[0]
+		sampled := 0
+		for i := 0; i < 100; i++ {
+			if gc.sample(k) {
+				sampled++
+			}
+		}
+		assert.Equal(t, gasSampleFirst+100/gasSampleEvery, sampled)
+	})
+
+	t.Run("adjust towards the target", func(t *testing.T) {
+		gc := newGasController(1, newKinds())
+		gc.Adjust()
+		assert.Equal(t, 1.0, gc.factor, "no block observed")
+
+		gc.ObserveBlock(block(1_000_000, 2000)) // 0.5 MGas/s
+		assert.Equal(t, 500_000.0, gc.Observed())
+		gc.Adjust()
+		assert.InDelta(t, 1.25, gc.factor, 0.001)
+
+		gc.ObserveBlock(block(4_000_000, 1000)) // smoothed to 1.55 MGas/s
+		gc.Adjust()
+		assert.Less(t, gc.factor, 1.25)
+	})
+
+	t.Run("factor is clamped", func(t *testing.T) {
+		gc := newGasController(1, newKinds())
+		for i := 0; i < 100; i++ {
+			gc.ObserveBlock(block(100_000_000, 1000))
+			gc.Adjust()
+		}
+		assert.Equal(t, minGasFactor, gc.factor)
+	})
+
+	t.Run("blocks without interval are ignored", func(t *testing.T) {
+		gc := newGasController(1, newKinds())
+		gc.ObserveBlock(block(1_000_000, 0))
+		assert.Equal(t, 0.0, gc.Observed())
+	})
+}
@@ -0,0 +1,104 @@
+package loadtest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-logr/logr"
+	"github.com/mysteryforge/gasper/k6/eth"
+)
+
+const (
+	maxPendingHeads      = 1024
+	headResubscribeDelay = time.Second
+)
+
+type arrivedHead struct {
+	Header  *types.Header
+	Arrival time.Time
+}
+
+// headSubscriber buffers newHeads notifications with their arrival time and
+// signals notify, so the block tracker walks to a new head as soon as it
+// arrives.
+type headSubscriber struct {
+	ethClient *eth.Client
+	log       logr.Logger
+	heads     []*arrivedHead
+	notify    chan struct{}
+	mu        *sync.Mutex
+}
+
+func newHeadSubscriber(ethClient *eth.Client, log logr.Logger) *headSubscriber {
+	return &headSubscriber{
+		ethClient: ethClient,
+		log:       log,
+		heads:     make([]*arrivedHead, 0),
+		notify:    make(chan struct{}, 1),
+		mu:        &sync.Mutex{},
+	}
+}
+
+// run subscribes to newHeads and resubscribes when the subscription fails,
+// until ctx is done.
+func (hs *headSubscriber) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := hs.subscribe(ctx); err != nil {
+			hs.log.Error(err, "newHeads subscription failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(headResubscribeDelay):
+		}
+	}
+}
+
+func (hs *headSubscriber) subscribe(ctx context.Context) error {
+	ch := make(chan *types.Header, 64)
+	sub, err := hs.ethClient.Ec.SubscribeNewHead(ctx, ch)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case header := <-ch:
+			hs.push(&arrivedHead{Header: header, Arrival: time.Now()})
+		}
+	}
+}
+
+func (hs *headSubscriber) push(head *arrivedHead) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if len(hs.heads) >= maxPendingHeads {
+		hs.log.Info("dropping oldest buffered head", "number", hs.heads[0].Header.Number)
+		hs.heads = hs.heads[1:]
+	}
+	hs.heads = append(hs.heads, head)
+
+	select {
+	case hs.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain returns the buffered heads in arrival order and clears the buffer.
+func (hs *headSubscriber) drain() []*arrivedHead {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	heads := hs.heads
+	hs.heads = make([]*arrivedHead, 0)
+	return heads
+}
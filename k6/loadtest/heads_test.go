@@ -0,0 +1,49 @@
+package loadtest
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadSubscriberBuffer(t *testing.T) {
+	head := func(number int64) *arrivedHead {
+		return &arrivedHead{Header: &types.Header{Number: big.NewInt(number)}, Arrival: time.Now()}
+	}
+	numbers := func(heads []*arrivedHead) []int64 {
+		res := make([]int64, 0, len(heads))
+		for _, h := range heads {
+			res = append(res, h.Header.Number.Int64())
+		}
+		return res
+	}
+
+	t.Run("drain in arrival order", func(t *testing.T) {
+		hs := newHeadSubscriber(nil, logr.Discard())
+		hs.push(head(1))
+		hs.push(head(2))
+		assert.Equal(t, []int64{1, 2}, numbers(hs.drain()))
+		assert.Empty(t, hs.drain())
+	})
+
+	t.Run("notify on push", func(t *testing.T) {
+		hs := newHeadSubscriber(nil, logr.Discard())
+		hs.push(head(1))
+		hs.push(head(2))
+		assert.Len(t, hs.notify, 1)
+	})
+
+	t.Run("drop oldest when full", func(t *testing.T) {
+		hs := newHeadSubscriber(nil, logr.Discard())
+		for i := int64(0); i <= maxPendingHeads; i++ {
+			hs.push(head(i))
+		}
+		heads := hs.drain()
+		assert.Len(t, heads, maxPendingHeads)
+		assert.Equal(t, int64(1), heads[0].Header.Number.Int64())
+	})
+}
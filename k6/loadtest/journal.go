@@ -0,0 +1,110 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteryforge/gasper/k6/eth"
+)
+
+const journalExportTimeout = 30 * time.Second
+
+type journalExportConfig struct {
+	Path   string `yaml:"path" js:"path"`                         // file the journal is written to on close
+	Format string `yaml:"format,omitempty" js:"format,omitempty"` // csv or jsonl, from the extension of path by default
+}
+
+func (cfg *journalExportConfig) validate() error {
+	if cfg.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	switch cfg.format() {
+	case eth.JournalFormatCSV, eth.JournalFormatJSONL:
+		return nil
+	}
+	return fmt.Errorf("unknown format: %s", cfg.format())
+}
+
+func (cfg *journalExportConfig) format() eth.JournalFormat {
+	if cfg.Format != "" {
+		return eth.JournalFormat(strings.ToLower(cfg.Format))
+	}
+	if strings.EqualFold(filepath.Ext(cfg.Path), ".csv") {
+		return eth.JournalFormatCSV
+	}
+	return eth.JournalFormatJSONL
+}
+
+// journalSent records a sent transaction in the journal.
+func (c *DefaultClient) journalSent(hash common.Hash, wallet common.Address, nonce uint64, txType eth.TransactionType, t time.Time) {
+	if c.journal == nil {
+		return
+	}
+	c.dbTasks.run(func() {
+		if err := c.journal.Sent(c.uid, hash, wallet, nonce, txType, t); err != nil {
+			c.log.Error(err, "failed to journal tx", "hash", hash.Hex())
+		}
+	})
+}
+
+// journalReceipt records the receipt of a confirmed transaction in the
+// journal.
+func (c *DefaultClient) journalReceipt(hash common.Hash, receipt *types.Receipt) {
+	if c.journal == nil {
+		return
+	}
+	record := newReceiptRecord(receipt)
+	c.dbTasks.run(func() {
+		if err := c.journal.Receipt(hash, record); err != nil {
+			c.log.Error(err, "failed to journal tx receipt", "hash", hash.Hex())
+		}
+	})
+}
+
+// journalInclusions records the block of the transactions sent by this test.
+func (c *DefaultClient) journalInclusions(tb *trackedBlock) {
+	for _, hash := range tb.Block.Transactions {
+		if _, ok := c.txStartTime(hash); !ok {
+			continue
+		}
+		if err := c.journal.Included(hash, tb.Block.Number.Uint64(), tb.Arrival); err != nil {
+			c.log.Error(err, "failed to journal tx inclusion", "hash", hash)
+		}
+	}
+}
+
+// exportJournal writes the transactions sent by the client to the export
+// file, after a last check of the pending receipts.
+func (c *DefaultClient) exportJournal() {
+	if c.receipts != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), journalExportTimeout)
+		c.receipts.tick(ctx)
+		cancel()
+	}
+
+	pth := c.journalExport.Path
+	if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+		c.log.Error(err, "failed to create journal export directory", "path", pth)
+		return
+	}
+	f, err := os.Create(pth)
+	if err != nil {
+		c.log.Error(err, "failed to create journal export", "path", pth)
+		return
+	}
+	n, err := c.journal.Export(f, c.uid, c.journalExport.format())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.log.Error(err, "failed to export journal", "path", pth)
+		return
+	}
+	c.log.Info("Exported journal", "path", pth, "num_txs", n)
+}
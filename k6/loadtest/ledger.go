@@ -0,0 +1,294 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	maxLedgerBalancesAtOnce = 64
+	ledgerResolveTimeout    = 30 * time.Second
+)
+
+// LedgerAccount is the expected balance of an account.
+type LedgerAccount struct {
+	Address  common.Address
+	Token    bool     // ERC20 balance, wei balance otherwise
+	Expected *big.Int // after the confirmed transfers
+	Pending  int64    // change of the transfers without a receipt yet, e.g. dropped ones
+}
+
+// LedgerState is the expected state of the accounts changed by the transfers
+// of a client.
+type LedgerState struct {
+	Block    uint64 // block of the balances before the first transfer
+	ERC20    *common.Address
+	Accounts []*LedgerAccount
+}
+
+// ledgerTx is a sent transfer whose outcome is not known yet.
+type ledgerTx struct {
+	from   common.Address
+	to     common.Address
+	amount *big.Int
+	token  bool
+}
+
+// ledger tracks the expected balances of the target addresses and the ERC20
+// balances of the targets and testers: a successful transfer adds its amount
+// to the target, ERC20 transfers take it from the sender as well. The balances
+// of the senders are not tracked, since they pay for gas. Outcomes come from
+// the receipts of confirmed transactions and of the receipt reconciler, the
+// transfers still pending are checked by resolve.
+type ledger struct {
+	receipts func(ctx context.Context, hashes []common.Hash) ([]*types.Receipt, error)
+
+	block    uint64
+	erc20    *common.Address
+	balances map[common.Address]*big.Int
+	tokens   map[common.Address]*big.Int
+	pending  map[common.Hash]*ledgerTx
+	mu       *sync.Mutex
+}
+
+func newLedger(block uint64, erc20 *common.Address) *ledger {
+	return &ledger{
+		block:    block,
+		erc20:    erc20,
+		balances: make(map[common.Address]*big.Int),
+		tokens:   make(map[common.Address]*big.Int),
+		pending:  make(map[common.Hash]*ledgerTx),
+		mu:       &sync.Mutex{},
+	}
+}
+
+// sent records a transfer to a tracked target.
+func (l *ledger) sent(hash common.Hash, tx *ledgerTx) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if tx.token {
+		if _, ok := l.tokens[tx.to]; !ok {
+			return
+		}
+	} else if _, ok := l.balances[tx.to]; !ok {
+		return
+	}
+	l.pending[hash] = tx
+}
+
+// receipt applies the outcome of a transfer, once. Receipts of untracked
+// transactions and of transfers already applied are ignored.
+func (l *ledger) receipt(hash common.Hash, receipt *types.Receipt) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resolved(hash, receipt)
+}
+
+func (l *ledger) resolved(hash common.Hash, receipt *types.Receipt) {
+	tx, ok := l.pending[hash]
+	if !ok {
+		return
+	}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		l.apply(tx)
+	}
+	delete(l.pending, hash)
+}
+
+// resolve applies the pending transfers with a receipt.
+func (l *ledger) resolve(ctx context.Context) error {
+	l.mu.Lock()
+	hashes := make([]common.Hash, 0, len(l.pending))
+	for hash := range l.pending {
+		hashes = append(hashes, hash)
+	}
+	l.mu.Unlock()
+
+	for batch := range slices.Chunk(hashes, maxReceiptBatch) {
+		receipts, err := l.receipts(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to get receipts: %w", err)
+		}
+		l.mu.Lock()
+		for i, hash := range batch {
+			if receipts[i] != nil {
+				l.resolved(hash, receipts[i])
+			}
+		}
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+func (l *ledger) apply(tx *ledgerTx) {
+	if !tx.token {
+		l.balances[tx.to].Add(l.balances[tx.to], tx.amount)
+		return
+	}
+	l.tokens[tx.to].Add(l.tokens[tx.to], tx.amount)
+	if balance, ok := l.tokens[tx.from]; ok {
+		balance.Sub(balance, tx.amount)
+	}
+}
+
+// state returns the expected balances, sorted by address.
+func (l *ledger) state() *LedgerState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pending := make(map[common.Address]int64)
+	pendingTokens := make(map[common.Address]int64)
+	for _, tx := range l.pending {
+		if !tx.token {
+			pending[tx.to] += tx.amount.Int64()
+			continue
+		}
+		pendingTokens[tx.to] += tx.amount.Int64()
+		if _, ok := l.tokens[tx.from]; ok {
+			pendingTokens[tx.from] -= tx.amount.Int64()
+		}
+	}
+
+	state := &LedgerState{Block: l.block, ERC20: l.erc20, Accounts: make([]*LedgerAccount, 0, len(l.balances)+len(l.tokens))}
+	for addr, balance := range l.balances {
+		state.Accounts = append(state.Accounts, &LedgerAccount{Address: addr, Expected: new(big.Int).Set(balance), Pending: pending[addr]})
+	}
+	for addr, balance := range l.tokens {
+		state.Accounts = append(state.Accounts, &LedgerAccount{Address: addr, Token: true, Expected: new(big.Int).Set(balance), Pending: pendingTokens[addr]})
+	}
+	slices.SortFunc(state.Accounts, func(a, b *LedgerAccount) int {
+		if a.Token != b.Token {
+			if a.Token {
+				return 1
+			}
+			return -1
+		}
+		return a.Address.Cmp(b.Address)
+	})
+	return state
+}
+
+// setupLedger records the balances of the target addresses, and the ERC20
+// balances of the targets and testers, before the first transfer and starts
+// tracking the transfers.
+func (c *DefaultClient) setupLedger(ctx context.Context) error {
+	block, err := c.ethClient.Ec.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get ledger block: %w", err)
+	}
+	var erc20 *common.Address
+	if c.erc20 != nil {
+		erc20 = c.erc20.Address
+	}
+	l := newLedger(block, erc20)
+	l.receipts = func(ctx context.Context, hashes []common.Hash) ([]*types.Receipt, error) {
+		return fetchReceipts(ctx, c.ethClient.Rc, hashes)
+	}
+
+	targets := make([]common.Address, 0)
+	for _, addr := range c.targetAddresses.All() {
+		targets = append(targets, *addr)
+	}
+	tokenHolders := slices.Clone(targets)
+	if c.testers != nil {
+		for addr := range c.testers.All() {
+			tokenHolders = append(tokenHolders, addr)
+		}
+	}
+
+	number := new(big.Int).SetUint64(block)
+	mu := &sync.Mutex{}
+	fetch := func(addrs []common.Address, balances map[common.Address]*big.Int, balanceAt func(ctx context.Context, addr common.Address) (*big.Int, error)) error {
+		sem := semaphore.NewWeighted(maxLedgerBalancesAtOnce)
+		var firstErr error
+		for _, addr := range addrs {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			go func() {
+				defer sem.Release(1)
+				balance, err := balanceAt(ctx, addr)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("failed to get balance of %s: %w", addr.Hex(), err)
+					}
+					return
+				}
+				balances[addr] = balance
+			}()
+		}
+		if err := sem.Acquire(ctx, maxLedgerBalancesAtOnce); err != nil {
+			return err
+		}
+		return firstErr
+	}
+
+	if err := fetch(targets, l.balances, func(ctx context.Context, addr common.Address) (*big.Int, error) {
+		return c.ethClient.Ec.BalanceAt(ctx, addr, number)
+	}); err != nil {
+		return err
+	}
+	if c.erc20 != nil {
+		if err := fetch(tokenHolders, l.tokens, func(ctx context.Context, addr common.Address) (*big.Int, error) {
+			return c.erc20.Contract.BalanceOf(&bind.CallOpts{Context: ctx, BlockNumber: number}, addr)
+		}); err != nil {
+			return err
+		}
+	}
+
+	c.ledger = l
+	if c.receipts != nil {
+		c.receipts.onReceipt(l.receipt)
+	}
+	c.log.Info("Ledger started", "block", block, "num_targets", len(l.balances), "num_token_holders", len(l.tokens))
+	return nil
+}
+
+// ledgerSent records a transfer of value or of 1 ERC20 token to the target.
+func (c *DefaultClient) ledgerSent(hash common.Hash, from, target common.Address, txType eth.TransactionType, value *big.Int) {
+	if c.ledger == nil {
+		return
+	}
+	switch txType {
+	case eth.TransactionTypeETH, eth.TransactionTypeBlob:
+		if value.Sign() > 0 {
+			c.ledger.sent(hash, &ledgerTx{from: from, to: target, amount: value})
+		}
+	case eth.TransactionTypeERC20:
+		c.ledger.sent(hash, &ledgerTx{from: from, to: target, amount: big.NewInt(1), token: true})
+	}
+}
+
+// ledgerReceipt applies the outcome of a confirmed transfer.
+func (c *DefaultClient) ledgerReceipt(hash common.Hash, receipt *types.Receipt) {
+	if c.ledger == nil {
+		return
+	}
+	c.ledger.receipt(hash, receipt)
+}
+
+// Ledger returns the expected balances of the target addresses and the ERC20
+// balances, after checking the receipts of every pending transfer.
+func (c *DefaultClient) Ledger(ctx context.Context) (*LedgerState, error) {
+	if c.ledger == nil {
+		return nil, fmt.Errorf("ledger is not enabled")
+	}
+	ctx, cancel := context.WithTimeout(ctx, ledgerResolveTimeout)
+	defer cancel()
+	if err := c.ledger.resolve(ctx); err != nil {
+		return nil, err
+	}
+	return c.ledger.state(), nil
+}
@@ -0,0 +1,97 @@
+package loadtest
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedger(t *testing.T) {
+	tester, other := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	target, untracked := common.HexToAddress("0x10"), common.HexToAddress("0x11")
+	erc20 := common.HexToAddress("0xe20")
+
+	receipts := make(map[common.Hash]*types.Receipt)
+	l := newLedger(100, &erc20)
+	l.receipts = func(_ context.Context, hashes []common.Hash) ([]*types.Receipt, error) {
+		res := make([]*types.Receipt, len(hashes))
+		for i, hash := range hashes {
+			res[i] = receipts[hash]
+		}
+		return res, nil
+	}
+	l.balances[target] = big.NewInt(1000)
+	l.tokens[target] = big.NewInt(0)
+	l.tokens[tester] = big.NewInt(50)
+
+	transfer, token, reverted, pending := common.HexToHash("0xa1"), common.HexToHash("0xa2"), common.HexToHash("0xa3"), common.HexToHash("0xa4")
+	l.sent(transfer, &ledgerTx{from: tester, to: target, amount: big.NewInt(1)})
+	l.sent(token, &ledgerTx{from: tester, to: target, amount: big.NewInt(1), token: true})
+	l.sent(reverted, &ledgerTx{from: tester, to: target, amount: big.NewInt(1), token: true})
+	// sent by a wallet without a tracked token balance
+	l.sent(pending, &ledgerTx{from: other, to: target, amount: big.NewInt(1), token: true})
+	l.sent(common.HexToHash("0xa5"), &ledgerTx{from: tester, to: untracked, amount: big.NewInt(1)})
+	assert.Len(t, l.pending, 4)
+
+	receipts[transfer] = &types.Receipt{Status: types.ReceiptStatusSuccessful}
+	receipts[token] = &types.Receipt{Status: types.ReceiptStatusSuccessful}
+	receipts[reverted] = &types.Receipt{Status: types.ReceiptStatusFailed}
+
+	// receipts of the reconciler are applied once
+	l.receipt(transfer, receipts[transfer])
+	l.receipt(transfer, receipts[transfer])
+	l.receipt(common.HexToHash("0xa6"), receipts[transfer])
+	assert.Len(t, l.pending, 3)
+	assert.Equal(t, big.NewInt(1001), l.balances[target])
+
+	require.NoError(t, l.resolve(context.Background()))
+	assert.Equal(t, &LedgerState{
+		Block: 100,
+		ERC20: &erc20,
+		Accounts: []*LedgerAccount{
+			{Address: target, Expected: big.NewInt(1001)},
+			{Address: tester, Token: true, Expected: big.NewInt(49)},
+			{Address: target, Token: true, Expected: big.NewInt(1), Pending: 1},
+		},
+	}, l.state())
+}
+
+func TestLedgerConcurrentResolve(t *testing.T) {
+	target := common.HexToAddress("0x10")
+	l := newLedger(100, nil)
+	l.receipts = func(_ context.Context, hashes []common.Hash) ([]*types.Receipt, error) {
+		res := make([]*types.Receipt, len(hashes))
+		for i := range hashes {
+			res[i] = &types.Receipt{Status: types.ReceiptStatusSuccessful}
+		}
+		return res, nil
+	}
+	l.balances[target] = big.NewInt(0)
+	for i := range 100 {
+		l.sent(common.BigToHash(big.NewInt(int64(i))), &ledgerTx{to: target, amount: big.NewInt(1)})
+	}
+
+	wg := &sync.WaitGroup{}
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, l.resolve(context.Background()))
+		}()
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				l.receipt(common.BigToHash(big.NewInt(int64(i))), &types.Receipt{Status: types.ReceiptStatusSuccessful})
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Empty(t, l.pending)
+	assert.Equal(t, big.NewInt(100), l.balances[target])
+}
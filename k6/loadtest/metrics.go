@@ -25,6 +25,27 @@ type EthMetrics struct {
 	BlockPerSec       *metrics.Metric
 	PoolStatusPending *metrics.Metric
 	PoolStatusQueued  *metrics.Metric
+	BlockBlobGasUsed  *metrics.Metric
+	BlockBlobs        *metrics.Metric
+	AuthGasUsed       *metrics.Metric
+	ExecutionGasUsed  *metrics.Metric
+	ReorgDepth        *metrics.Metric
+	DispatchTarget    *metrics.Metric
+	DispatchRate      *metrics.Metric
+	TargetMgas        *metrics.Metric
+	ObservedMgas      *metrics.Metric
+	RateLimit         *metrics.Metric
+	WalletRefills     *metrics.Metric
+	RefillDuration    *metrics.Metric
+	NonceGaps         *metrics.Metric
+	TxErrors          *metrics.Metric
+	TxFailed          *metrics.Metric // rate of transactions that failed to send, reverted or timed out waiting for confirmation
+	SendFailures      *metrics.Metric
+	ReceiptFailures   *metrics.Metric
+	ConfirmTimeouts   *metrics.Metric
+	TransportErrors   *metrics.Metric
+	TxReverted        *metrics.Metric
+	TxDropped         *metrics.Metric
 }
 
 func RegisterMetrics(vu modules.VU) *EthMetrics {
@@ -44,6 +65,27 @@ func RegisterMetrics(vu modules.VU) *EthMetrics {
 		BlockPerSec:       r.MustNewMetric("gasper_block_per_sec", metrics.Trend, metrics.Default),
 		PoolStatusPending: r.MustNewMetric("gasper_pool_status_pending", metrics.Trend, metrics.Default),
 		PoolStatusQueued:  r.MustNewMetric("gasper_pool_status_queued", metrics.Trend, metrics.Default),
+		BlockBlobGasUsed:  r.MustNewMetric("gasper_block_blob_gas_used", metrics.Trend, metrics.Default),
+		BlockBlobs:        r.MustNewMetric("gasper_block_blobs", metrics.Trend, metrics.Default),
+		AuthGasUsed:       r.MustNewMetric("gasper_auth_gas_used", metrics.Trend, metrics.Default),
+		ExecutionGasUsed:  r.MustNewMetric("gasper_execution_gas_used", metrics.Trend, metrics.Default),
+		ReorgDepth:        r.MustNewMetric("gasper_reorg_depth", metrics.Trend, metrics.Default),
+		DispatchTarget:    r.MustNewMetric("gasper_dispatch_target_rate", metrics.Trend, metrics.Default),
+		DispatchRate:      r.MustNewMetric("gasper_dispatch_rate", metrics.Trend, metrics.Default),
+		TargetMgas:        r.MustNewMetric("gasper_target_mgas", metrics.Trend, metrics.Default),
+		ObservedMgas:      r.MustNewMetric("gasper_observed_mgas", metrics.Trend, metrics.Default),
+		RateLimit:         r.MustNewMetric("gasper_rate_limit", metrics.Trend, metrics.Default),
+		WalletRefills:     r.MustNewMetric("gasper_wallet_refills", metrics.Counter, metrics.Default),
+		RefillDuration:    r.MustNewMetric("gasper_wallet_refill_duration", metrics.Trend, metrics.Time),
+		NonceGaps:         r.MustNewMetric("gasper_nonce_gaps", metrics.Counter, metrics.Default),
+		TxErrors:          r.MustNewMetric("gasper_tx_errors", metrics.Counter, metrics.Default),
+		TxFailed:          r.MustNewMetric("gasper_tx_failed", metrics.Rate, metrics.Default),
+		SendFailures:      r.MustNewMetric("gasper_tx_send_failures", metrics.Counter, metrics.Default),
+		ReceiptFailures:   r.MustNewMetric("gasper_tx_receipt_failures", metrics.Counter, metrics.Default),
+		ConfirmTimeouts:   r.MustNewMetric("gasper_tx_confirmation_timeouts", metrics.Counter, metrics.Default),
+		TransportErrors:   r.MustNewMetric("gasper_rpc_transport_errors", metrics.Counter, metrics.Default),
+		TxReverted:        r.MustNewMetric("gasper_tx_reverted", metrics.Counter, metrics.Default),
+		TxDropped:         r.MustNewMetric("gasper_tx_dropped", metrics.Counter, metrics.Default),
 	}
 }
 
@@ -77,7 +119,9 @@ func ReportEoaFromStats(vu modules.VU, m *EthMetrics, clientUID string, eoa uint
 	})
 }
 
-func ReportTimeToMineFromStats(vu modules.VU, m *EthMetrics, clientUID string, dur time.Duration) {
+// ReportTimeToMineFromStats reports the time to mine of a transaction, reorged
+// is set when the transaction was mined again after its block was reorged out.
+func ReportTimeToMineFromStats(vu modules.VU, m *EthMetrics, clientUID string, dur time.Duration, reorged bool) {
 	if vu.State() == nil {
 		return
 	}
@@ -85,13 +129,300 @@ func ReportTimeToMineFromStats(vu modules.VU, m *EthMetrics, clientUID string, d
 	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.Sample{
 		TimeSeries: metrics.TimeSeries{
 			Metric: m.TimeToMine,
-			Tags:   metrics.NewRegistry().RootTagSet().With("client_uid", clientUID).With("test_uid", TestUID),
+			Tags: metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+				"client_uid": clientUID,
+				"test_uid":   TestUID,
+				"reorged":    strconv.FormatBool(reorged),
+			}),
 		},
 		Value: float64(dur / time.Millisecond),
 		Time:  time.Now(),
 	})
 }
 
+func ReportReorgFromStats(vu modules.VU, m *EthMetrics, clientUID string, number uint64, depth uint64, t time.Time) {
+	if vu.State() == nil {
+		return
+	}
+
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: m.ReorgDepth,
+			Tags: metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+				"client_uid": clientUID,
+				"test_uid":   TestUID,
+				"block":      strconv.FormatUint(number, 10),
+			}),
+		},
+		Value: float64(depth),
+		Time:  t,
+	})
+}
+
+// ReportRefillFromStats reports the number of wallets refilled in one batch
+// and how long the refill took, tagged failed when the refill failed.
+func ReportRefillFromStats(vu modules.VU, m *EthMetrics, clientUID string, wallets int, dur time.Duration, failed bool, t time.Time) {
+	if vu.State() == nil {
+		return
+	}
+
+	tags := metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+		"client_uid": clientUID,
+		"test_uid":   TestUID,
+		"failed":     strconv.FormatBool(failed),
+	})
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.Samples{
+		{
+			TimeSeries: metrics.TimeSeries{Metric: m.WalletRefills, Tags: tags},
+			Value:      float64(wallets),
+			Time:       t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{Metric: m.RefillDuration, Tags: tags},
+			Value:      metrics.D(dur),
+			Time:       t,
+		},
+	})
+}
+
+// ReportNonceGapFromStats reports a nonce gap filled with a replacement
+// transaction, tagged failed when the replacement could not be sent.
+func ReportNonceGapFromStats(vu modules.VU, m *EthMetrics, clientUID string, failed bool, t time.Time) {
+	if vu.State() == nil {
+		return
+	}
+
+	tags := metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+		"client_uid": clientUID,
+		"test_uid":   TestUID,
+		"failed":     strconv.FormatBool(failed),
+	})
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.Samples{
+		{
+			TimeSeries: metrics.TimeSeries{Metric: m.NonceGaps, Tags: tags},
+			Value:      1,
+			Time:       t,
+		},
+	})
+}
+
+// ReportTxErrorFromStats reports an error returned by the node for a
+// transaction, tagged with its category.
+func ReportTxErrorFromStats(vu modules.VU, m *EthMetrics, clientUID string, category eth.TxErrorCategory) {
+	if vu.State() == nil {
+		return
+	}
+
+	tags := metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+		"client_uid": clientUID,
+		"test_uid":   TestUID,
+		"category":   string(category),
+	})
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.Samples{
+		{
+			TimeSeries: metrics.TimeSeries{Metric: m.TxErrors, Tags: tags},
+			Value:      1,
+			Time:       time.Now(),
+		},
+	})
+}
+
+// ReportTxOutcomeFromStats reports whether a transaction failed to
+// gasper_tx_failed, and counts the failure by its outcome.
+func ReportTxOutcomeFromStats(vu modules.VU, m *EthMetrics, clientUID string, call string, txType eth.TransactionType, outcome txOutcome) {
+	if vu.State() == nil || outcome == txCanceled {
+		return
+	}
+
+	tags := metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+		"client_uid": clientUID,
+		"test_uid":   TestUID,
+		"call":       call,
+		"tx_type":    string(txType),
+	})
+	now := time.Now()
+	samples := metrics.Samples{
+		{
+			TimeSeries: metrics.TimeSeries{Metric: m.TxFailed, Tags: tags},
+			Value:      metrics.B(outcome != txSucceeded),
+			Time:       now,
+		},
+	}
+
+	var counter *metrics.Metric
+	switch outcome {
+	case txSendFailed:
+		counter = m.SendFailures
+	case txReceiptFailed:
+		counter = m.ReceiptFailures
+	case txConfirmationTimeout:
+		counter = m.ConfirmTimeouts
+	case txTransportFailed:
+		counter = m.TransportErrors
+	}
+	if counter != nil {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: counter, Tags: tags},
+			Value:      1,
+			Time:       now,
+		})
+	}
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, samples)
+}
+
+// ReportReceiptsFromStats reports the transactions sent without confirmation
+// found reverted or dropped by the receipt reconciler, and the gas used by the
+// ones with a receipt.
+func ReportReceiptsFromStats(vu modules.VU, m *EthMetrics, clientUID string, counts receiptCounts, t time.Time) {
+	if vu.State() == nil {
+		return
+	}
+
+	samples := make(metrics.Samples, 0, len(counts.GasUsed)+2)
+	for _, tx := range counts.GasUsed {
+		samples = append(samples, txGasUsedSample(m, clientUID, tx, t))
+	}
+	if counts.Reverted > 0 || counts.Dropped > 0 {
+		tags := metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+			"client_uid": clientUID,
+			"test_uid":   TestUID,
+		})
+		samples = append(samples,
+			metrics.Sample{
+				TimeSeries: metrics.TimeSeries{Metric: m.TxReverted, Tags: tags},
+				Value:      float64(counts.Reverted),
+				Time:       t,
+			},
+			metrics.Sample{
+				TimeSeries: metrics.TimeSeries{Metric: m.TxDropped, Tags: tags},
+				Value:      float64(counts.Dropped),
+				Time:       t,
+			},
+		)
+	}
+	if len(samples) == 0 {
+		return
+	}
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, samples)
+}
+
+func ReportDispatchRateFromStats(vu modules.VU, m *EthMetrics, clientUID string, txType eth.TransactionType, target float64, achieved float64, t time.Time) {
+	if vu.State() == nil {
+		return
+	}
+
+	tags := metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+		"client_uid": clientUID,
+		"test_uid":   TestUID,
+		"tx_type":    string(txType),
+	})
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.Samples{
+		{
+			TimeSeries: metrics.TimeSeries{Metric: m.DispatchTarget, Tags: tags},
+			Value:      target,
+			Time:       t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{Metric: m.DispatchRate, Tags: tags},
+			Value:      achieved,
+			Time:       t,
+		},
+	})
+}
+
+// ReportGasControllerFromStats reports the target and the smoothed observed gas
+// throughput of gas dispatch, both given in gas/s and reported in MGas/s.
+func ReportGasControllerFromStats(vu modules.VU, m *EthMetrics, clientUID string, target float64, observed float64, t time.Time) {
+	if vu.State() == nil {
+		return
+	}
+
+	tags := metrics.NewRegistry().RootTagSet().With("client_uid", clientUID).With("test_uid", TestUID)
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.Samples{
+		{
+			TimeSeries: metrics.TimeSeries{Metric: m.TargetMgas, Tags: tags},
+			Value:      target / 1_000_000,
+			Time:       t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{Metric: m.ObservedMgas, Tags: tags},
+			Value:      observed / 1_000_000,
+			Time:       t,
+		},
+	})
+}
+
+// ReportRateLimitFromStats reports the send rate limit in tx/s set by the rate
+// controller at t.
+func ReportRateLimitFromStats(vu modules.VU, m *EthMetrics, clientUID string, rate float64, t time.Time) {
+	if vu.State() == nil {
+		return
+	}
+
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: m.RateLimit,
+			Tags:   metrics.NewRegistry().RootTagSet().With("client_uid", clientUID).With("test_uid", TestUID),
+		},
+		Value: rate,
+		Time:  t,
+	})
+}
+
+func ReportTxGasUsedFromStats(vu modules.VU, m *EthMetrics, clientUID string, txType eth.TransactionType, accessList bool, gasUsed uint64) {
+	if vu.State() == nil {
+		return
+	}
+
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, txGasUsedSample(m, clientUID, txGasUsed{TxType: txType, AccessList: accessList, GasUsed: gasUsed}, time.Now()))
+}
+
+// txGasUsedSample is the gasper_gas_used sample of a transaction, tagged with
+// its type and whether it has an access list, unlike the samples of blocks.
+func txGasUsedSample(m *EthMetrics, clientUID string, tx txGasUsed, t time.Time) metrics.Sample {
+	return metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: m.GasUsed,
+			Tags: metrics.NewRegistry().RootTagSet().WithTagsFromMap(map[string]string{
+				"client_uid":  clientUID,
+				"test_uid":    TestUID,
+				"tx_type":     string(tx.TxType),
+				"access_list": strconv.FormatBool(tx.AccessList),
+			}),
+		},
+		Value: float64(tx.GasUsed),
+		Time:  t,
+	}
+}
+
+func ReportSplitGasUsedFromStats(vu modules.VU, m *EthMetrics, clientUID string, txType eth.TransactionType, authGas uint64, execGas uint64) {
+	if vu.State() == nil {
+		return
+	}
+
+	tags := metrics.NewRegistry().RootTagSet().With("client_uid", clientUID).With("test_uid", TestUID).With("tx_type", string(txType))
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.ConnectedSamples{
+		Samples: []metrics.Sample{
+			{
+				TimeSeries: metrics.TimeSeries{
+					Metric: m.AuthGasUsed,
+					Tags:   tags,
+				},
+				Value: float64(authGas),
+				Time:  time.Now(),
+			},
+			{
+				TimeSeries: metrics.TimeSeries{
+					Metric: m.ExecutionGasUsed,
+					Tags:   tags,
+				},
+				Value: float64(execGas),
+				Time:  time.Now(),
+			},
+		},
+	})
+}
+
 func ReportTxPoolStatusFromStats(vu modules.VU, m *EthMetrics, clientUID string, status *eth.PoolStatus) {
 	if vu.State() == nil {
 		return
@@ -137,116 +468,146 @@ func ReportBlockMetrics(
 	blockTime := time.Unix(int64(block.Timestamp), 0)
 	blockNumStr := strconv.FormatUint(block.Number.Uint64(), 10)
 	txsLn := strconv.Itoa(len(block.Transactions))
-	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.ConnectedSamples{
-		Samples: []metrics.Sample{
-			{
-				TimeSeries: metrics.TimeSeries{
-					Metric: m.Txs,
-					Tags: rootTS.WithTagsFromMap(map[string]string{
-						"client_uid": clientUID,
-						"test_uid":   TestUID,
-						"block":      blockNumStr,
-					}),
-				},
-				Value: float64(len(block.Transactions)),
-				Time:  t,
+	samples := []metrics.Sample{
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: m.Txs,
+				Tags: rootTS.WithTagsFromMap(map[string]string{
+					"client_uid": clientUID,
+					"test_uid":   TestUID,
+					"block":      blockNumStr,
+				}),
 			},
-			{
-				TimeSeries: metrics.TimeSeries{
-					Metric: m.BlockTxs,
-					Tags: rootTS.WithTagsFromMap(map[string]string{
-						"client_uid": clientUID,
-						"test_uid":   TestUID,
-						"txs":        txsLn,
-					}),
-				},
-				Value: float64(block.Number.Uint64()),
-				Time:  t,
+			Value: float64(len(block.Transactions)),
+			Time:  t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: m.BlockTxs,
+				Tags: rootTS.WithTagsFromMap(map[string]string{
+					"client_uid": clientUID,
+					"test_uid":   TestUID,
+					"txs":        txsLn,
+				}),
 			},
-			{
-				TimeSeries: metrics.TimeSeries{
-					Metric: m.GasUsed,
-					Tags: rootTS.WithTagsFromMap(map[string]string{
-						"client_uid": clientUID,
-						"test_uid":   TestUID,
-						"block":      blockNumStr,
-					}),
-				},
-				Value: float64(block.GasUsed),
-				Time:  t,
+			Value: float64(block.Number.Uint64()),
+			Time:  t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: m.GasUsed,
+				Tags: rootTS.WithTagsFromMap(map[string]string{
+					"client_uid": clientUID,
+					"test_uid":   TestUID,
+					"block":      blockNumStr,
+				}),
 			},
-			{
-				TimeSeries: metrics.TimeSeries{
-					Metric: m.BlockGasUsed,
-					Tags: rootTS.WithTagsFromMap(map[string]string{
-						"client_uid": clientUID,
-						"test_uid":   TestUID,
-						"gas_used":   strconv.FormatUint(block.GasUsed, 10),
-					}),
-				},
-				Value: float64(block.Number.Uint64()),
-				Time:  t,
+			Value: float64(block.GasUsed),
+			Time:  t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: m.BlockGasUsed,
+				Tags: rootTS.WithTagsFromMap(map[string]string{
+					"client_uid": clientUID,
+					"test_uid":   TestUID,
+					"gas_used":   strconv.FormatUint(block.GasUsed, 10),
+				}),
 			},
-			{
-				TimeSeries: metrics.TimeSeries{
-					Metric: m.TPS,
-					Tags: rootTS.WithTagsFromMap(map[string]string{
-						"client_uid": clientUID,
-						"test_uid":   TestUID,
-						"block":      blockNumStr,
-					}),
-				},
-				Value: tps,
-				Time:  blockTime,
+			Value: float64(block.Number.Uint64()),
+			Time:  t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: m.TPS,
+				Tags: rootTS.WithTagsFromMap(map[string]string{
+					"client_uid": clientUID,
+					"test_uid":   TestUID,
+					"block":      blockNumStr,
+				}),
 			},
-			{
-				TimeSeries: metrics.TimeSeries{
-					Metric: m.Mgas,
-					Tags: rootTS.WithTagsFromMap(map[string]string{
-						"client_uid": clientUID,
-						"test_uid":   TestUID,
-						"block":      blockNumStr,
-					}),
-				},
-				Value: mgas,
-				Time:  t,
+			Value: tps,
+			Time:  blockTime,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: m.Mgas,
+				Tags: rootTS.WithTagsFromMap(map[string]string{
+					"client_uid": clientUID,
+					"test_uid":   TestUID,
+					"block":      blockNumStr,
+				}),
 			},
-			{
-				TimeSeries: metrics.TimeSeries{
-					Metric: m.BlockMgas,
-					Tags: rootTS.WithTagsFromMap(map[string]string{
-						"client_uid": clientUID,
-						"test_uid":   TestUID,
-						"mgas":       strconv.FormatFloat(mgas, 'f', 2, 64),
-					}),
-				},
-				Value: float64(block.Number.Uint64()),
-				Time:  t,
+			Value: mgas,
+			Time:  t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: m.BlockMgas,
+				Tags: rootTS.WithTagsFromMap(map[string]string{
+					"client_uid": clientUID,
+					"test_uid":   TestUID,
+					"mgas":       strconv.FormatFloat(mgas, 'f', 2, 64),
+				}),
 			},
-			{
+			Value: float64(block.Number.Uint64()),
+			Time:  t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: m.BlockTime,
+				Tags: rootTS.WithTagsFromMap(map[string]string{
+					"client_uid": clientUID,
+					"test_uid":   TestUID,
+					"block":      blockNumStr,
+				}),
+			},
+			Value: float64(blockTimestampDiffMili),
+			Time:  t,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: m.BlockPerSec,
+				Tags: rootTS.WithTagsFromMap(map[string]string{
+					"client_uid": clientUID,
+					"test_uid":   TestUID,
+					"block":      blockNumStr,
+				}),
+			},
+			Value: 1.0,
+			Time:  blockTime,
+		},
+	}
+
+	// blob gas is only reported by post Cancun blocks
+	if block.BlobGasUsed != nil {
+		samples = append(samples,
+			metrics.Sample{
 				TimeSeries: metrics.TimeSeries{
-					Metric: m.BlockTime,
+					Metric: m.BlockBlobGasUsed,
 					Tags: rootTS.WithTagsFromMap(map[string]string{
 						"client_uid": clientUID,
 						"test_uid":   TestUID,
 						"block":      blockNumStr,
 					}),
 				},
-				Value: float64(blockTimestampDiffMili),
+				Value: float64(*block.BlobGasUsed),
 				Time:  t,
 			},
-			{
+			metrics.Sample{
 				TimeSeries: metrics.TimeSeries{
-					Metric: m.BlockPerSec,
+					Metric: m.BlockBlobs,
 					Tags: rootTS.WithTagsFromMap(map[string]string{
 						"client_uid": clientUID,
 						"test_uid":   TestUID,
 						"block":      blockNumStr,
 					}),
 				},
-				Value: 1.0,
-				Time:  blockTime,
+				Value: float64(block.BlobCount()),
+				Time:  t,
 			},
-		},
-	})
+		)
+	}
+
+	metrics.PushIfNotDone(vu.Context(), vu.State().Samples, metrics.ConnectedSamples{Samples: samples})
 }
@@ -17,6 +17,7 @@ import (
 
 var (
 	sharedClients    map[string]*Clients
+	sharedClientsMux *sync.RWMutex
 	initOnceClients  map[string]*sync.Once
 	sharedDBs        map[string]*eth.PebbleDb
 	initOnceDBs      map[string]*sync.Once
@@ -34,6 +35,7 @@ func init() {
 	}))
 	sharedLog = sharedLog.WithValues("test_uid", TestUID)
 	sharedClients = make(map[string]*Clients)
+	sharedClientsMux = &sync.RWMutex{}
 	initOnceClients = make(map[string]*sync.Once)
 	sharedDBs = make(map[string]*eth.PebbleDb)
 	initOnceDBs = make(map[string]*sync.Once)
@@ -77,12 +79,16 @@ func (mi *ModuleInstance) Exports() modules.Exports {
 				createSharedClients(pth, uid) // create shared clients
 				return nil
 			},
+			"closeSharedClients": func(uid string) interface{} {
+				closeSharedClients(uid)
+				return nil
+			},
 			"requestSharedWallet": func(uid string) interface{} {
-				panicIfNotInitialized(uid)
+				clients := SharedClients(uid)
 				sharedWalletsMux.Lock()
 				defer sharedWalletsMux.Unlock()
 
-				res := sharedClients[uid].RequestSharedWallet()
+				res := clients.RequestSharedWallet()
 				for _, r := range res {
 					if r.Err != nil {
 						panic(r.Err)
@@ -98,61 +104,93 @@ func (mi *ModuleInstance) Exports() modules.Exports {
 				return res
 			},
 			"releaseSharedWallet": func(uid string, address string) interface{} {
-				panicIfNotInitialized(uid)
+				clients := SharedClients(uid)
 				if address == "" {
 					panic("address is not set")
 				}
-				sharedClients[uid].ReleaseSharedWallet(common.HexToAddress(address))
+				clients.ReleaseSharedWallet(common.HexToAddress(address))
 				return nil
 			},
 
+			"sweepWallets": func(uid string) interface{} {
+				return SharedClients(uid).SweepWallets(mi.vu, mi.metrics)
+			},
+
+			"nonceStates": func(uid string) interface{} {
+				return SharedClients(uid).NonceStates(mi.vu, mi.metrics)
+			},
+
+			"pastRuns": func(pth string) interface{} {
+				runs, err := eth.PastRuns(pth)
+				return &Result{Err: err, Data: runs}
+			},
+			"analyzeRun": func(pth string) interface{} {
+				analysis, err := eth.AnalyzeRun(pth)
+				return &Result{Err: err, Data: analysis}
+			},
+			"diffRuns": func(basePth, otherPth string, threshold float64) interface{} {
+				base, err := eth.AnalyzeRun(basePth)
+				if err != nil {
+					return &Result{Err: err}
+				}
+				other, err := eth.AnalyzeRun(otherPth)
+				if err != nil {
+					return &Result{Err: err}
+				}
+				return &Result{Data: eth.DiffRuns(base, other, threshold)}
+			},
+
 			"chainID": func(uid string) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].ChainID(mi.vu, mi.metrics)
+				return SharedClients(uid).ChainID(mi.vu, mi.metrics)
 			},
 			"txPoolStatus": func(uid string) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].TxPoolStatus(mi.vu, mi.metrics)
+				return SharedClients(uid).TxPoolStatus(mi.vu, mi.metrics)
 			},
 			"reportBlockMetrics": func(uid string) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].ReportBlockMetrics(mi.vu, mi.metrics)
+				return SharedClients(uid).ReportBlockMetrics(mi.vu, mi.metrics)
 			},
 
 			"sendTransaction": func(uid string, params map[string]interface{}) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].SendTransaction(mi.vu, mi.metrics, params)
+				return SharedClients(uid).SendTransaction(mi.vu, mi.metrics, params)
 			},
 			"sendERC20Transaction": func(uid string, params map[string]interface{}) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].SendERC20Transaction(mi.vu, mi.metrics, params)
+				return SharedClients(uid).SendERC20Transaction(mi.vu, mi.metrics, params)
 			},
 			"sendERC721Transaction": func(uid string, params map[string]interface{}) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].SendERC721Transaction(mi.vu, mi.metrics, params)
+				return SharedClients(uid).SendERC721Transaction(mi.vu, mi.metrics, params)
+			},
+			"sendBlobTransaction": func(uid string, params map[string]interface{}) interface{} {
+				return SharedClients(uid).SendBlobTransaction(mi.vu, mi.metrics, params)
+			},
+			"sendSetCodeTransaction": func(uid string, params map[string]interface{}) interface{} {
+				return SharedClients(uid).SendSetCodeTransaction(mi.vu, mi.metrics, params)
+			},
+			"sendDelegatedTransaction": func(uid string, params map[string]interface{}) interface{} {
+				return SharedClients(uid).SendDelegatedTransaction(mi.vu, mi.metrics, params)
+			},
+
+			"dispatch": func(uid string, params map[string]interface{}) interface{} {
+				return SharedClients(uid).Dispatch(mi.vu, mi.metrics, params)
+			},
+			"dispatchGas": func(uid string, params map[string]interface{}) interface{} {
+				return SharedClients(uid).DispatchGas(mi.vu, mi.metrics, params)
 			},
 
 			"deployContract": func(uid string, params map[string]interface{}) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].DeployContract(mi.vu, params)
+				return SharedClients(uid).DeployContract(mi.vu, params)
 			},
 			"txContract": func(uid string, params map[string]interface{}) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].TxContract(mi.vu, mi.metrics, params)
+				return SharedClients(uid).TxContract(mi.vu, mi.metrics, params)
 			},
 			"callContract": func(uid string, params map[string]interface{}) interface{} {
-				panicIfNotInitialized(uid)
-
-				return sharedClients[uid].CallContract(mi.vu, mi.metrics, params)
+				return SharedClients(uid).CallContract(mi.vu, mi.metrics, params)
 			},
 			"txInfoByHash": func(uid string, hash string) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].TxInfoByHash(mi.vu, mi.metrics, hash)
+				return SharedClients(uid).TxInfoByHash(mi.vu, mi.metrics, hash)
 			},
 
 			"call": func(uid string, method string, params []interface{}) interface{} {
-				panicIfNotInitialized(uid)
-				return sharedClients[uid].Call(mi.vu, mi.metrics, method, params)
+				return SharedClients(uid).Call(mi.vu, mi.metrics, method, params)
 			},
 		},
 	}
@@ -190,18 +228,36 @@ func createSharedClients(pth, uid string) {
 			})
 		}
 
-		sharedClients[uid], err = NewClients(context.Background(), cfgs, uid, sharedDBs, sharedLog)
+		clients, err := NewClients(context.Background(), cfgs, uid, sharedDBs, sharedLog)
 		if err != nil {
 			panic(err)
 		}
+		sharedClientsMux.Lock()
+		sharedClients[uid] = clients
+		sharedClientsMux.Unlock()
 	})
 }
 
-func panicIfNotInitialized(uid string) {
+// SharedClients returns the clients created by createSharedClients for uid,
+// for the other gasper modules.
+func SharedClients(uid string) *Clients {
 	if uid == "" {
 		panic("uid is not set")
 	}
+	sharedClientsMux.RLock()
+	defer sharedClientsMux.RUnlock()
 	if sharedClients[uid] == nil {
 		panic("sharedClients is not initialized")
 	}
+	return sharedClients[uid]
+}
+
+// closeSharedClients closes the clients of uid and forgets them, later calls
+// with uid fail as if they were never created.
+func closeSharedClients(uid string) {
+	clients := SharedClients(uid)
+	sharedClientsMux.Lock()
+	delete(sharedClients, uid)
+	sharedClientsMux.Unlock()
+	clients.Close()
 }
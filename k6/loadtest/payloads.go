@@ -0,0 +1,289 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"go.k6.io/k6/js/modules"
+)
+
+// payloadBuilder returns the builder of the transactions of txType.
+func (c *DefaultClient) payloadBuilder(txType eth.TransactionType, opts *TransactionOptions) (PayloadBuilder, error) {
+	switch txType {
+	case eth.TransactionTypeETH:
+		return ethTransferPayload{}, nil
+	case eth.TransactionTypeERC20:
+		if c.erc20 == nil {
+			return nil, fmt.Errorf("erc20 contract is not initialized")
+		}
+		return erc20TransferPayload{erc20: c.erc20}, nil
+	case eth.TransactionTypeERC721:
+		if c.erc721 == nil {
+			return nil, fmt.Errorf("erc721 contract is not initialized")
+		}
+		return erc721MintPayload{erc721: c.erc721}, nil
+	case eth.TransactionTypeBlob:
+		if c.isLegacy {
+			return nil, fmt.Errorf("blob transactions are not supported on legacy chains")
+		}
+		if opts.BlobCount == 0 || opts.BlobCount > eth.MaxBlobsPerTransaction {
+			return nil, fmt.Errorf("blob count should be between 1 and %d: %d", eth.MaxBlobsPerTransaction, opts.BlobCount)
+		}
+		return blobPayload{
+			count:    int(opts.BlobCount),
+			random:   opts.RandomBlobs,
+			sidecars: c.blobSidecars,
+		}, nil
+	case eth.TransactionTypeSetCode:
+		if c.delegationAddress == nil {
+			return nil, fmt.Errorf("delegation_address is not configured")
+		}
+		if c.isLegacy {
+			return nil, fmt.Errorf("set code transactions are not supported on legacy chains")
+		}
+		if opts.AuthorizationCount == 0 {
+			return nil, fmt.Errorf("authorization count should be greater then 0")
+		}
+		return setCodePayload{
+			chainID:     c.ethClient.ChainID,
+			delegate:    *c.delegationAddress,
+			count:       int(opts.AuthorizationCount),
+			data:        opts.Data,
+			testers:     c.testers,
+			nonces:      c.nonces,
+			code:        c.ethClient.Ec.CodeAt,
+			onDelegated: c.markDelegated,
+		}, nil
+	case eth.TransactionTypeDelegated:
+		return delegatedCallPayload{
+			data:      opts.Data,
+			delegated: c.delegated,
+		}, nil
+	case eth.TransactionTypeContract:
+		if opts.To == nil {
+			return nil, fmt.Errorf("contract address is required for contract transactions")
+		}
+		return contractCallPayload{to: *opts.To, data: opts.Data}, nil
+	default:
+		return nil, fmt.Errorf("unsupported transaction type: %s", txType)
+	}
+}
+
+// ethTransferPayload sends 1 wei to the target address.
+type ethTransferPayload struct{}
+
+func (ethTransferPayload) Call() string { return "Transaction" }
+
+func (ethTransferPayload) TxType() eth.TransactionType { return eth.TransactionTypeETH }
+
+func (ethTransferPayload) Build(_ context.Context, _ common.Address, target common.Address) (*Payload, error) {
+	return &Payload{
+		To:    &target,
+		Value: big.NewInt(1),
+		Gas:   21000,
+	}, nil
+}
+
+// erc20TransferPayload transfers 1 token to the target address.
+type erc20TransferPayload struct {
+	erc20 *eth.ERC20
+}
+
+func (erc20TransferPayload) Call() string { return "ERC20Transaction" }
+
+func (erc20TransferPayload) TxType() eth.TransactionType { return eth.TransactionTypeERC20 }
+
+func (p erc20TransferPayload) Build(_ context.Context, _ common.Address, target common.Address) (*Payload, error) {
+	data, err := p.erc20.TransferData(target, big.NewInt(1))
+	if err != nil {
+		return nil, err
+	}
+	return &Payload{
+		To:   p.erc20.Address,
+		Data: data,
+	}, nil
+}
+
+// erc721MintPayload mints 1 token to the target address.
+type erc721MintPayload struct {
+	erc721 *eth.ERC721
+}
+
+func (erc721MintPayload) Call() string { return "ERC721Transaction" }
+
+func (erc721MintPayload) TxType() eth.TransactionType { return eth.TransactionTypeERC721 }
+
+func (p erc721MintPayload) Build(_ context.Context, _ common.Address, target common.Address) (*Payload, error) {
+	data, err := p.erc721.MintBatchData(target, big.NewInt(1))
+	if err != nil {
+		return nil, err
+	}
+	return &Payload{
+		To:   p.erc721.Address,
+		Data: data,
+	}, nil
+}
+
+// contractCallPayload calls a contract with data, the gas limit is estimated.
+type contractCallPayload struct {
+	to   common.Address
+	data []byte
+}
+
+func (contractCallPayload) Call() string { return "ContractTransaction" }
+
+func (contractCallPayload) TxType() eth.TransactionType { return eth.TransactionTypeContract }
+
+func (p contractCallPayload) Build(_ context.Context, _ common.Address, _ common.Address) (*Payload, error) {
+	to := p.to
+	return &Payload{
+		To:   &to,
+		Data: p.data,
+	}, nil
+}
+
+// blobPayload sends a blob transaction with 1 wei to the target address.
+type blobPayload struct {
+	count    int
+	random   bool
+	sidecars *eth.BlobSidecarCache
+}
+
+func (blobPayload) Call() string { return "BlobTransaction" }
+
+func (blobPayload) TxType() eth.TransactionType { return eth.TransactionTypeBlob }
+
+func (p blobPayload) Build(_ context.Context, _ common.Address, target common.Address) (*Payload, error) {
+	sidecar, err := p.sidecars.Get(p.count, p.random)
+	if err != nil {
+		return nil, err
+	}
+	return &Payload{
+		To:      &target,
+		Value:   big.NewInt(1),
+		Gas:     21000,
+		Sidecar: sidecar,
+	}, nil
+}
+
+// setCodePayload delegates available tester wallets to the delegate contract
+// and calls the first delegated wallet with data. The authorization nonces are
+// reserved from the nonce manager and the wallets stay locked until the
+// transaction is done. Wallets are only marked delegated once the transaction
+// is confirmed and their code points to the delegate, an authorization can be
+// skipped by the node, e.g. for a stale nonce.
+type setCodePayload struct {
+	chainID     *big.Int
+	delegate    common.Address
+	count       int
+	data        []byte
+	testers     *eth.WalletRegistry
+	nonces      *eth.NonceManager
+	code        func(ctx context.Context, addr common.Address, block *big.Int) ([]byte, error)
+	onDelegated func(common.Address)
+}
+
+func (setCodePayload) Call() string { return "SetCodeTransaction" }
+
+func (setCodePayload) TxType() eth.TransactionType { return eth.TransactionTypeSetCode }
+
+func (p setCodePayload) Build(_ context.Context, _ common.Address, _ common.Address) (*Payload, error) {
+	authorities := make([]*eth.Wallet, 0, p.count)
+	unlock := func() {
+		for _, w := range authorities {
+			p.testers.Unlock(w.Address)
+		}
+	}
+	for i := 0; i < p.count; i++ {
+		w := p.testers.GetAvailableWallet()
+		if w == nil {
+			break
+		}
+		authorities = append(authorities, w)
+	}
+	if len(authorities) == 0 {
+		return nil, fmt.Errorf("no available wallet for authorization")
+	}
+
+	nonces := make([]uint64, 0, len(authorities))
+	done := func(sent bool, _ error) {
+		for i, nonce := range nonces {
+			p.nonces.Done(authorities[i], nonce, sent, nil)
+		}
+		unlock()
+	}
+	confirmed := func(ctx context.Context, receipt *types.Receipt) {
+		delegation := types.AddressToDelegation(p.delegate)
+		for _, w := range authorities {
+			code, err := p.code(ctx, w.Address, receipt.BlockNumber)
+			if err != nil || !bytes.Equal(code, delegation) {
+				continue
+			}
+			p.onDelegated(w.Address)
+		}
+	}
+	authList := make([]types.SetCodeAuthorization, 0, len(authorities))
+	for _, w := range authorities {
+		nonce := p.nonces.Next(w, 0)
+		nonces = append(nonces, nonce)
+		auth, err := w.SignAuthorization(p.chainID, p.delegate, nonce)
+		if err != nil {
+			done(false, err)
+			return nil, err
+		}
+		authList = append(authList, *auth)
+	}
+
+	to := authorities[0].Address
+	return &Payload{
+		To:        &to,
+		Data:      p.data,
+		AuthList:  authList,
+		Confirmed: confirmed,
+		Done:      done,
+	}, nil
+}
+
+func (setCodePayload) ReportReceipt(vu modules.VU, metrics *EthMetrics, clientUID string, tx *types.Transaction, receipt *types.Receipt) {
+	// the authorities are funded tester wallets, so they all exist
+	reportSplitGasUsed(vu, metrics, clientUID, eth.TransactionTypeSetCode, tx, receipt, len(tx.SetCodeAuthorizations()))
+}
+
+// delegatedCallPayload calls a wallet which was delegated by a set code
+// transaction, executing the delegate contract code with data.
+type delegatedCallPayload struct {
+	data      []byte
+	delegated *eth.TargetAddresses
+}
+
+func (delegatedCallPayload) Call() string { return "DelegatedTransaction" }
+
+func (delegatedCallPayload) TxType() eth.TransactionType { return eth.TransactionTypeDelegated }
+
+func (p delegatedCallPayload) Build(_ context.Context, _ common.Address, _ common.Address) (*Payload, error) {
+	to := p.delegated.Random()
+	if to == nil {
+		return nil, fmt.Errorf("no delegated wallets, send a set code transaction first")
+	}
+	return &Payload{
+		To:   to,
+		Data: p.data,
+	}, nil
+}
+
+func (delegatedCallPayload) ReportReceipt(vu modules.VU, metrics *EthMetrics, clientUID string, tx *types.Transaction, receipt *types.Receipt) {
+	reportSplitGasUsed(vu, metrics, clientUID, eth.TransactionTypeDelegated, tx, receipt, 0)
+}
+
+func reportSplitGasUsed(vu modules.VU, metrics *EthMetrics, clientUID string, txType eth.TransactionType, tx *types.Transaction, receipt *types.Receipt, existing int) {
+	authGas, execGas, err := eth.SplitGasUsed(tx, receipt, existing)
+	if err != nil {
+		return
+	}
+	ReportSplitGasUsedFromStats(vu, metrics, clientUID, txType, authGas, execGas)
+}
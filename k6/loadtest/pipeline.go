@@ -0,0 +1,458 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"go.k6.io/k6/js/modules"
+)
+
+// Payload is the transaction kind specific part of a transaction.
+// Gas 0 means the gas limit is estimated before signing, a non nil Sidecar
+// turns the transaction into a blob transaction and a non empty AuthList into
+// a set code transaction. Confirmed, when set, is called with the receipt of a
+// successful transaction sent with confirmation. Done, when set, is called
+// once with the outcome of the transaction: whether it reached the node and
+// the error of the send or of the confirmation.
+type Payload struct {
+	To         *common.Address
+	Value      *big.Int
+	Gas        uint64
+	Data       []byte
+	AccessList types.AccessList
+	Sidecar    *types.BlobTxSidecar
+	AuthList   []types.SetCodeAuthorization
+	Confirmed  func(ctx context.Context, receipt *types.Receipt)
+	Done       func(sent bool, err error)
+}
+
+// PayloadBuilder builds the payload for one kind of transaction. Everything
+// else (wallet selection, nonce, fees, signing, sending, confirmation and
+// metrics) is handled by the shared send pipeline.
+type PayloadBuilder interface {
+	// Call is the name used in gasper_req_duration, it is prefixed with
+	// "send" and "sendConfirmed".
+	Call() string
+	TxType() eth.TransactionType
+	Build(ctx context.Context, from common.Address, target common.Address) (*Payload, error)
+}
+
+// ReceiptReporter is implemented by payload builders which report metrics
+// from the receipt, it is only called for confirmed transactions.
+type ReceiptReporter interface {
+	ReportReceipt(vu modules.VU, metrics *EthMetrics, clientUID string, tx *types.Transaction, receipt *types.Receipt)
+}
+
+type txFees struct {
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	GasPrice   *big.Int
+	BlobFeeCap *big.Int
+}
+
+func (c *DefaultClient) suggestFees(ctx context.Context, multiplier uint64) (*txFees, error) {
+	if c.isLegacy {
+		return &txFees{
+			GasPrice: new(big.Int).Mul(c.latestGasPrice.Load(), big.NewInt(int64(multiplier))),
+		}, nil
+	}
+
+	head, err := c.ethClient.Ec.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tipCap := new(big.Int).Mul(c.latestGasTip.Load(), big.NewInt(int64(multiplier)))
+	feeCap := new(big.Int).Add(
+		tipCap,
+		new(big.Int).Mul(head.BaseFee, big.NewInt(2)),
+	)
+	return &txFees{GasTipCap: tipCap, GasFeeCap: feeCap}, nil
+}
+
+func (c *DefaultClient) suggestBlobFeeCap(ctx context.Context) (*big.Int, error) {
+	blobBaseFee, err := c.ethClient.Ec.BlobBaseFee(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob base fee: %w", err)
+	}
+	return new(big.Int).Mul(blobBaseFee, big.NewInt(2)), nil
+}
+
+func (c *DefaultClient) callMsg(from common.Address, tx *types.Transaction) ethereum.CallMsg {
+	cm := ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+	if !c.isLegacy {
+		cm.GasFeeCap = tx.GasFeeCap()
+		cm.GasTipCap = tx.GasTipCap()
+	} else {
+		cm.GasPrice = tx.GasPrice()
+	}
+	if tx.Type() == types.BlobTxType {
+		cm.BlobGasFeeCap = tx.BlobGasFeeCap()
+		cm.BlobHashes = tx.BlobHashes()
+	}
+	if tx.Type() == types.SetCodeTxType {
+		cm.AuthorizationList = tx.SetCodeAuthorizations()
+	}
+	return cm
+}
+
+func (c *DefaultClient) newTx(nonce uint64, payload *Payload, fees *txFees) *types.Transaction {
+	if payload.Sidecar != nil {
+		return types.NewTx(&types.BlobTx{
+			ChainID:    uint256.MustFromBig(c.ethClient.ChainID),
+			Nonce:      nonce,
+			To:         *payload.To,
+			Value:      uint256.MustFromBig(payload.Value),
+			Gas:        payload.Gas,
+			GasTipCap:  uint256.MustFromBig(fees.GasTipCap),
+			GasFeeCap:  uint256.MustFromBig(fees.GasFeeCap),
+			Data:       payload.Data,
+			AccessList: payload.AccessList,
+			BlobFeeCap: uint256.MustFromBig(fees.BlobFeeCap),
+			BlobHashes: payload.Sidecar.BlobHashes(),
+			Sidecar:    payload.Sidecar,
+		})
+	}
+	if len(payload.AuthList) > 0 {
+		return types.NewTx(&types.SetCodeTx{
+			ChainID:    uint256.MustFromBig(c.ethClient.ChainID),
+			Nonce:      nonce,
+			To:         *payload.To,
+			Value:      uint256.MustFromBig(payload.Value),
+			Gas:        payload.Gas,
+			GasTipCap:  uint256.MustFromBig(fees.GasTipCap),
+			GasFeeCap:  uint256.MustFromBig(fees.GasFeeCap),
+			Data:       payload.Data,
+			AccessList: payload.AccessList,
+			AuthList:   payload.AuthList,
+		})
+	}
+	if !c.isLegacy {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    c.ethClient.ChainID,
+			Nonce:      nonce,
+			To:         payload.To,
+			Value:      payload.Value,
+			Gas:        payload.Gas,
+			GasTipCap:  fees.GasTipCap,
+			GasFeeCap:  fees.GasFeeCap,
+			Data:       payload.Data,
+			AccessList: payload.AccessList,
+		})
+	}
+	if payload.AccessList != nil {
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    c.ethClient.ChainID,
+			Nonce:      nonce,
+			To:         payload.To,
+			Value:      payload.Value,
+			Gas:        payload.Gas,
+			GasPrice:   fees.GasPrice,
+			Data:       payload.Data,
+			AccessList: payload.AccessList,
+		})
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       payload.To,
+		Value:    payload.Value,
+		Gas:      payload.Gas,
+		GasPrice: fees.GasPrice,
+		Data:     payload.Data,
+	})
+}
+
+// acquireWallet returns one of the requested shared wallets, or locks an
+// available tester wallet. The returned release func must always be called.
+func (c *DefaultClient) acquireWallet(addresses []*common.Address) (*eth.Wallet, func(), error) {
+	for _, address := range addresses {
+		if c.IsSharedWallet(*address) {
+			return c.sharedWallets[address.Hex()], func() {}, nil
+		}
+	}
+	if c.testers == nil {
+		return nil, nil, fmt.Errorf("no available wallet")
+	}
+	wallet := c.testers.GetAvailableWallet()
+	if wallet == nil {
+		return nil, nil, fmt.Errorf("no available wallet")
+	}
+	return wallet, func() { c.testers.Unlock(wallet.Address) }, nil
+}
+
+func (c *DefaultClient) sendPayloads(vu modules.VU, metrics *EthMetrics, builder PayloadBuilder, options ...TransactionOption) (*common.Hash, error) {
+	opts := DefaultTransactionOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	if opts.WaitForConfirmation && opts.OffsetNonce > 0 {
+		return nil, fmt.Errorf("cannot use offset nonce with confirmation")
+	}
+
+	wallet, release, err := c.acquireWallet(opts.WalletAddresses)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx := vu.Context()
+	targetAddress := c.targetAddresses.Random()
+	if targetAddress == nil {
+		return nil, fmt.Errorf("no target address")
+	}
+
+	var (
+		hash common.Hash
+		errs []error
+		sent bool
+	)
+	for i := 0; i < int(opts.TxCount); i++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if c.txPoolRateLimiter != nil {
+			if err := c.txPoolRateLimiter.Wait(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("rate limit: %w", err))
+				continue
+			}
+		}
+
+		nonce := c.nonces.Next(wallet, opts.OffsetNonce)
+		h, err := c.sendPayload(vu, metrics, builder, wallet, *targetAddress, nonce, opts)
+		c.nonces.Done(wallet, nonce, h != (common.Hash{}) && !opts.NoSend, err)
+		if h != (common.Hash{}) {
+			hash = h
+			sent = true
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			c.log.Error(err, "error in sending transactions", "call", "send"+builder.Call())
+		}
+	}
+
+	if !sent {
+		return nil, fmt.Errorf("failed to send any transaction")
+	}
+
+	return &hash, nil
+}
+
+// sendPayload builds, signs and sends (or simulates) a single transaction
+// and reports its outcome. A failed confirmation still returns the hash, since
+// the transaction was sent.
+func (c *DefaultClient) sendPayload(
+	vu modules.VU,
+	metrics *EthMetrics,
+	builder PayloadBuilder,
+	wallet *eth.Wallet,
+	target common.Address,
+	nonce uint64,
+	opts *TransactionOptions,
+) (common.Hash, error) {
+	hash, err := c.buildAndSend(vu, metrics, builder, wallet, target, nonce, opts)
+	ReportTxOutcomeFromStats(vu, metrics, c.uid, "send"+builder.Call(), builder.TxType(), classifyTxOutcome(err))
+	return hash, err
+}
+
+func (c *DefaultClient) buildAndSend(
+	vu modules.VU,
+	metrics *EthMetrics,
+	builder PayloadBuilder,
+	wallet *eth.Wallet,
+	target common.Address,
+	nonce uint64,
+	opts *TransactionOptions,
+) (_ common.Hash, err error) {
+	ctx := vu.Context()
+
+	payload, err := builder.Build(ctx, wallet.Address, target)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	sent := false
+	if payload.Done != nil {
+		defer func() { payload.Done(sent, err) }()
+	}
+	if payload.Value == nil {
+		payload.Value = big.NewInt(0)
+	}
+
+	fees, err := c.suggestFees(ctx, opts.GasPriceMultiplier)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	if payload.Sidecar != nil {
+		if c.isLegacy {
+			return common.Hash{}, fmt.Errorf("blob transactions are not supported on legacy chains")
+		}
+		if payload.To == nil {
+			return common.Hash{}, fmt.Errorf("blob transactions require a recipient")
+		}
+		fees.BlobFeeCap, err = c.suggestBlobFeeCap(ctx)
+		if err != nil {
+			return common.Hash{}, err
+		}
+	}
+
+	if len(payload.AuthList) > 0 {
+		if c.isLegacy {
+			return common.Hash{}, fmt.Errorf("set code transactions are not supported on legacy chains")
+		}
+		if payload.To == nil {
+			return common.Hash{}, fmt.Errorf("set code transactions require a recipient")
+		}
+	}
+
+	if opts.AutoAccessList || opts.AccessList != nil {
+		if err := c.applyAccessList(ctx, wallet.Address, payload, fees, opts); err != nil {
+			return common.Hash{}, err
+		}
+	}
+
+	if payload.Gas == 0 {
+		gas, err := c.ethClient.Ec.EstimateGas(ctx, ethereum.CallMsg{
+			From:       wallet.Address,
+			To:         payload.To,
+			Value:      payload.Value,
+			Data:       payload.Data,
+			GasPrice:   fees.GasPrice,
+			GasTipCap:  fees.GasTipCap,
+			GasFeeCap:  fees.GasFeeCap,
+			AccessList: payload.AccessList,
+
+			AuthorizationList: payload.AuthList,
+		})
+		if err != nil {
+			c.reportTxError(vu, metrics, err)
+			return common.Hash{}, fmt.Errorf("failed to estimate gas: %w", err)
+		}
+		payload.Gas = gas
+	}
+
+	signedTx, err := types.SignTx(c.newTx(nonce, payload, fees), types.LatestSignerForChainID(c.ethClient.ChainID), wallet.PrivateKey)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	t := time.Now()
+	if opts.NoSend {
+		if _, err := c.ethClient.Ec.CallContract(ctx, c.callMsg(wallet.Address, signedTx), nil); err != nil {
+			c.reportTxError(vu, metrics, err)
+			return common.Hash{}, err
+		}
+	} else {
+		if err := c.ethClient.Ec.SendTransaction(ctx, signedTx); err != nil {
+			c.reportTxError(vu, metrics, err)
+			return common.Hash{}, err
+		}
+		sent = true
+		if c.balances != nil {
+			c.balances.spend(wallet.Address, signedTx.Cost())
+		}
+	}
+
+	ReportEoaFromStats(vu, metrics, c.uid, 1, builder.TxType())
+	ReportReqDurationFromStats(vu, metrics, c.uid, "send"+builder.Call(), time.Since(t))
+
+	hash := signedTx.Hash()
+	c.storeTransactionStartTime(hash, t)
+	if c.mineLatency != nil && !opts.NoSend {
+		c.mineLatency.sent(hash, t)
+	}
+	if !opts.NoSend {
+		c.journalSent(hash, wallet.Address, nonce, builder.TxType(), t)
+		c.ledgerSent(hash, wallet.Address, target, builder.TxType(), signedTx.Value())
+	}
+	if c.receipts != nil && !opts.NoSend && !opts.WaitForConfirmation {
+		c.receipts.track(hash, builder.TxType(), len(payload.AccessList) > 0)
+	}
+
+	if opts.WaitForConfirmation {
+		receipt, err := eth.WaitForReceipt(ctx, c.ethClient.Ec, hash, opts.ConfirmationDelay, 10*time.Millisecond)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return hash, fmt.Errorf("%w: %w", errConfirmationTimeout, err)
+			}
+			return hash, fmt.Errorf("failed to get receipt: %w", err)
+		}
+		ReportReqDurationFromStats(vu, metrics, c.uid, "sendConfirmed"+builder.Call(), time.Since(t))
+		ReportTxGasUsedFromStats(vu, metrics, c.uid, builder.TxType(), len(payload.AccessList) > 0, receipt.GasUsed)
+		c.journalReceipt(hash, receipt)
+		c.ledgerReceipt(hash, receipt)
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return hash, fmt.Errorf("%w: %s in block %d", errTxReverted, hash.Hex(), receipt.BlockNumber)
+		}
+
+		if reporter, ok := builder.(ReceiptReporter); ok {
+			reporter.ReportReceipt(vu, metrics, c.uid, signedTx, receipt)
+		}
+		if payload.Confirmed != nil {
+			payload.Confirmed(ctx, receipt)
+		}
+	}
+
+	return hash, nil
+}
+
+// reportTxError reports the category of an error returned by the node,
+// errors of canceled requests are not reported.
+func (c *DefaultClient) reportTxError(vu modules.VU, metrics *EthMetrics, err error) {
+	category := eth.ClassifyTxError(err)
+	if category == eth.TxErrCanceled {
+		return
+	}
+	ReportTxErrorFromStats(vu, metrics, c.uid, category)
+}
+
+// applyAccessList sets the static access list from the options, or generates
+// one with eth_createAccessList. A fixed gas limit is raised by the intrinsic
+// cost of the list.
+func (c *DefaultClient) applyAccessList(ctx context.Context, from common.Address, payload *Payload, fees *txFees, opts *TransactionOptions) error {
+	accessList := opts.AccessList
+	if opts.AutoAccessList {
+		var err error
+		accessList, _, err = c.ethClient.CreateAccessList(ctx, ethereum.CallMsg{
+			From:      from,
+			To:        payload.To,
+			Value:     payload.Value,
+			Data:      payload.Data,
+			GasPrice:  fees.GasPrice,
+			GasTipCap: fees.GasTipCap,
+			GasFeeCap: fees.GasFeeCap,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create access list: %w", err)
+		}
+	}
+
+	payload.AccessList = accessList
+	if payload.Gas != 0 {
+		payload.Gas += uint64(len(accessList))*params.TxAccessListAddressGas +
+			uint64(accessList.StorageKeys())*params.TxAccessListStorageKeyGas
+	}
+	return nil
+}
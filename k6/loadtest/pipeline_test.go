@@ -0,0 +1,94 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyAccessList(t *testing.T) {
+	accessList := types.AccessList{
+		{Address: common.HexToAddress("0x01"), StorageKeys: []common.Hash{{0x01}, {0x02}}},
+	}
+	opts := DefaultTransactionOptions()
+	WithTransactionAccessList(accessList)(opts)
+
+	t.Run("fixed gas", func(t *testing.T) {
+		payload := &Payload{Gas: 21000}
+		assert.NoError(t, (&DefaultClient{}).applyAccessList(context.Background(), common.Address{}, payload, &txFees{}, opts))
+		assert.Equal(t, accessList, payload.AccessList)
+		assert.Equal(t, uint64(21000+2400+2*1900), payload.Gas)
+	})
+
+	t.Run("estimated gas", func(t *testing.T) {
+		payload := &Payload{}
+		assert.NoError(t, (&DefaultClient{}).applyAccessList(context.Background(), common.Address{}, payload, &txFees{}, opts))
+		assert.Equal(t, accessList, payload.AccessList)
+		assert.Equal(t, uint64(0), payload.Gas)
+	})
+}
+
+func TestSetCodePayload(t *testing.T) {
+	authority, err := eth.NewWallet()
+	require.NoError(t, err)
+	authority.Nonce, authority.OffsetNonce = 5, 5
+	testers := eth.NewEmptyWalletRegistry()
+	testers.Register(authority)
+
+	delegate := common.HexToAddress("0xde1e")
+	code := []byte{}
+	delegated := make([]common.Address, 0)
+	p := setCodePayload{
+		chainID:  big.NewInt(1337),
+		delegate: delegate,
+		count:    1,
+		testers:  testers,
+		nonces:   eth.NewNonceManager(nil, nil),
+		code: func(context.Context, common.Address, *big.Int) ([]byte, error) {
+			return code, nil
+		},
+		onDelegated: func(addr common.Address) { delegated = append(delegated, addr) },
+	}
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(1)}
+
+	// a failed send gives the authorization nonce back
+	payload, err := p.Build(context.Background(), common.Address{}, common.Address{})
+	require.NoError(t, err)
+	require.Len(t, payload.AuthList, 1)
+	assert.Equal(t, uint64(5), payload.AuthList[0].Nonce)
+	assert.Equal(t, uint64(6), authority.Nonce)
+	payload.Done(false, errors.New("send failed"))
+	assert.Equal(t, uint64(5), authority.Nonce)
+	assert.Empty(t, delegated)
+
+	// the authority stays locked until the transaction is done
+	payload, err = p.Build(context.Background(), common.Address{}, common.Address{})
+	require.NoError(t, err)
+	_, err = p.Build(context.Background(), common.Address{}, common.Address{})
+	assert.Error(t, err)
+	payload.Done(true, nil)
+	assert.Equal(t, uint64(6), authority.Nonce)
+	assert.Empty(t, delegated)
+
+	// a skipped authorization leaves the code unchanged
+	payload, err = p.Build(context.Background(), common.Address{}, common.Address{})
+	require.NoError(t, err)
+	payload.Confirmed(context.Background(), receipt)
+	payload.Done(true, nil)
+	assert.Empty(t, delegated)
+
+	code = types.AddressToDelegation(delegate)
+	payload, err = p.Build(context.Background(), common.Address{}, common.Address{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), payload.AuthList[0].Nonce)
+	payload.Confirmed(context.Background(), receipt)
+	payload.Done(true, nil)
+	assert.Equal(t, []common.Address{authority.Address}, delegated)
+}
@@ -0,0 +1,170 @@
+package loadtest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteryforge/gasper/k6/eth"
+)
+
+const (
+	defaultRateControlInterval = 2 * time.Second
+	maxMineLatencyAge          = 10 * time.Minute
+)
+
+type rateControllerConfig struct {
+	Type     string        `yaml:"type,omitempty" js:"type,omitempty"`         // aimd (default), pid or latency
+	Interval time.Duration `yaml:"interval,omitempty" js:"interval,omitempty"` // time between rate updates, 2s by default
+	MaxRate  float64       `yaml:"max_rate,omitempty" js:"maxRate,omitempty"`  // upper bound of the rate in tx/s, unbounded by default
+
+	SteadyStateTxPoolSize *uint64  `yaml:"steady_state_tx_pool_size,omitempty" js:"steadyStateTxPoolSize,omitempty"` // aimd: tx pool size kept by the controller
+	Increase              *float64 `yaml:"increase,omitempty" js:"increase,omitempty"`                               // aimd, latency: additive increase in tx/s
+	BackoffFactor         *float64 `yaml:"backoff_factor,omitempty" js:"backoffFactor,omitempty"`                    // aimd, latency: multiplicative decrease
+
+	TargetTxPoolSize *uint64  `yaml:"target_tx_pool_size,omitempty" js:"targetTxPoolSize,omitempty"` // pid: tx pool size steered to
+	Kp               *float64 `yaml:"kp,omitempty" js:"kp,omitempty"`                                // pid: proportional gain
+	Ki               *float64 `yaml:"ki,omitempty" js:"ki,omitempty"`                                // pid: integral gain
+	Kd               *float64 `yaml:"kd,omitempty" js:"kd,omitempty"`                                // pid: derivative gain
+
+	TargetTimeToMine time.Duration `yaml:"target_time_to_mine,omitempty" js:"targetTimeToMine,omitempty"` // latency: time to mine steered to
+}
+
+func (cfg *rateControllerConfig) validate() error {
+	if _, err := cfg.controller(); err != nil {
+		return err
+	}
+	if cfg.Interval < 0 {
+		return fmt.Errorf("interval should not be negative: %s", cfg.Interval)
+	}
+	if cfg.MaxRate < 0 {
+		return fmt.Errorf("max_rate should not be negative: %v", cfg.MaxRate)
+	}
+	if cfg.BackoffFactor != nil && *cfg.BackoffFactor <= 1 {
+		return fmt.Errorf("backoff_factor should be greater then 1: %v", *cfg.BackoffFactor)
+	}
+	if cfg.TargetTimeToMine < 0 {
+		return fmt.Errorf("target_time_to_mine should not be negative: %s", cfg.TargetTimeToMine)
+	}
+	return nil
+}
+
+// controller returns the configured controller, AIMD with its defaults when
+// cfg is nil.
+func (cfg *rateControllerConfig) controller() (eth.RateController, error) {
+	if cfg == nil {
+		return eth.NewAIMDController(), nil
+	}
+
+	controller, err := eth.NewRateController(cfg.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c := controller.(type) {
+	case *eth.AIMDController:
+		if cfg.SteadyStateTxPoolSize != nil {
+			c.SteadyStateTxPoolSize = *cfg.SteadyStateTxPoolSize
+		}
+		if cfg.Increase != nil {
+			c.Increase = *cfg.Increase
+		}
+		if cfg.BackoffFactor != nil {
+			c.BackoffFactor = *cfg.BackoffFactor
+		}
+	case *eth.PIDController:
+		if cfg.TargetTxPoolSize != nil {
+			c.TargetTxPoolSize = *cfg.TargetTxPoolSize
+		}
+		if cfg.Kp != nil {
+			c.Kp = *cfg.Kp
+		}
+		if cfg.Ki != nil {
+			c.Ki = *cfg.Ki
+		}
+		if cfg.Kd != nil {
+			c.Kd = *cfg.Kd
+		}
+	case *eth.LatencyController:
+		if cfg.TargetTimeToMine > 0 {
+			c.TargetTimeToMine = cfg.TargetTimeToMine
+		}
+		if cfg.Increase != nil {
+			c.Increase = *cfg.Increase
+		}
+		if cfg.BackoffFactor != nil {
+			c.BackoffFactor = *cfg.BackoffFactor
+		}
+	}
+	return controller, nil
+}
+
+func (cfg *rateControllerConfig) interval() time.Duration {
+	if cfg == nil || cfg.Interval == 0 {
+		return defaultRateControlInterval
+	}
+	return cfg.Interval
+}
+
+func (cfg *rateControllerConfig) maxRate() float64 {
+	if cfg == nil {
+		return 0
+	}
+	return cfg.MaxRate
+}
+
+// mineLatency accumulates the time to mine of the transactions sent by the
+// client once they are included in a block, the latency controller reads the
+// average on every update. Transactions not included after
+// maxMineLatencyAge are forgotten.
+type mineLatency struct {
+	sentAt map[string]time.Time
+	sum    time.Duration
+	count  int
+	mu     *sync.Mutex
+}
+
+func newMineLatency() *mineLatency {
+	return &mineLatency{sentAt: make(map[string]time.Time), mu: &sync.Mutex{}}
+}
+
+func (ml *mineLatency) sent(hash common.Hash, t time.Time) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	ml.sentAt[hash.Hex()] = t
+}
+
+// included adds the time to mine of the sent transactions among txs.
+func (ml *mineLatency) included(txs []string, arrival time.Time) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	for _, hash := range txs {
+		if t, ok := ml.sentAt[hash]; ok {
+			ml.sum += arrival.Sub(t)
+			ml.count++
+			delete(ml.sentAt, hash)
+		}
+	}
+}
+
+// average returns the average time to mine since the last call, 0 when
+// nothing was mined.
+func (ml *mineLatency) average() time.Duration {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxMineLatencyAge)
+	for hash, t := range ml.sentAt {
+		if t.Before(cutoff) {
+			delete(ml.sentAt, hash)
+		}
+	}
+
+	if ml.count == 0 {
+		return 0
+	}
+	avg := ml.sum / time.Duration(ml.count)
+	ml.sum, ml.count = 0, 0
+	return avg
+}
@@ -0,0 +1,26 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMineLatency(t *testing.T) {
+	ml := newMineLatency()
+	start := time.Now()
+	first, second, stale := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+	ml.sent(first, start)
+	ml.sent(second, start.Add(time.Second))
+	ml.sent(stale, start.Add(-2*maxMineLatencyAge))
+
+	assert.Equal(t, time.Duration(0), ml.average())
+	assert.NotContains(t, ml.sentAt, stale.Hex())
+
+	ml.included([]string{first.Hex(), second.Hex(), common.HexToHash("0x04").Hex()}, start.Add(4*time.Second))
+	assert.Equal(t, 3500*time.Millisecond, ml.average())
+	assert.Equal(t, time.Duration(0), ml.average())
+	assert.Empty(t, ml.sentAt)
+}
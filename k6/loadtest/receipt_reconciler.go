@@ -0,0 +1,293 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/go-logr/logr"
+	"github.com/mysteryforge/gasper/k6/eth"
+)
+
+const (
+	defaultReceiptInterval  = 2 * time.Second
+	defaultReceiptDropAfter = 64 // blocks
+	maxReceiptBatch         = 256
+)
+
+type receiptReconcilerConfig struct {
+	SampleRate *float64      `yaml:"sample_rate,omitempty" js:"sampleRate,omitempty"` // share of the sent transactions checked, 1 (every transaction) by default
+	DropAfter  uint64        `yaml:"drop_after,omitempty" js:"dropAfter,omitempty"`   // blocks after which a transaction not included is dropped, 64 by default
+	Interval   time.Duration `yaml:"interval,omitempty" js:"interval,omitempty"`      // time between receipt checks, 2s by default
+}
+
+func (cfg *receiptReconcilerConfig) validate() error {
+	if cfg.SampleRate != nil && (*cfg.SampleRate <= 0 || *cfg.SampleRate > 1) {
+		return fmt.Errorf("sample_rate should be in (0, 1]: %v", *cfg.SampleRate)
+	}
+	if cfg.Interval < 0 {
+		return fmt.Errorf("interval should not be negative: %s", cfg.Interval)
+	}
+	return nil
+}
+
+func (cfg *receiptReconcilerConfig) sampleRate() float64 {
+	if cfg.SampleRate == nil {
+		return 1
+	}
+	return *cfg.SampleRate
+}
+
+func (cfg *receiptReconcilerConfig) dropAfter() uint64 {
+	if cfg.DropAfter == 0 {
+		return defaultReceiptDropAfter
+	}
+	return cfg.DropAfter
+}
+
+func (cfg *receiptReconcilerConfig) interval() time.Duration {
+	if cfg.Interval == 0 {
+		return defaultReceiptInterval
+	}
+	return cfg.Interval
+}
+
+// receiptCounts are the outcomes reconciled since the last drain.
+type receiptCounts struct {
+	Succeeded int
+	Reverted  int
+	Dropped   int
+	GasUsed   []txGasUsed // of the transactions with a receipt
+}
+
+// txGasUsed is the gas used by a transaction, with the tags of gasper_gas_used.
+type txGasUsed struct {
+	TxType     eth.TransactionType
+	AccessList bool
+	GasUsed    uint64
+}
+
+// receiptTx is a sampled transaction waiting for its receipt.
+type receiptTx struct {
+	sent       uint64 // head when sent
+	txType     eth.TransactionType
+	accessList bool
+}
+
+// receiptReconciler learns the outcome of the transactions sent without
+// waiting for confirmation. Sent transactions are sampled, their inclusion is
+// seen in the tracked blocks and their receipts fetched in batches. A sampled
+// transaction without a receipt dropAfter blocks after it was sent, not
+// included or included in a block reorged out since, is checked one last time
+// and counted as dropped when the node has no receipt for it.
+type receiptReconciler struct {
+	log        logr.Logger
+	sampleRate float64
+	dropAfter  uint64
+	interval   time.Duration
+	receipts   func(ctx context.Context, hashes []common.Hash) ([]*types.Receipt, error)
+	store      func(hash common.Hash, record *eth.ReceiptRecord) error
+
+	head      uint64
+	pending   map[common.Hash]receiptTx
+	included  map[common.Hash]receiptTx // transactions seen in a block
+	counts    receiptCounts
+	listeners []func(hash common.Hash, receipt *types.Receipt)
+	mu        *sync.Mutex
+}
+
+func newReceiptReconciler(log logr.Logger, cfg *receiptReconcilerConfig, head uint64) *receiptReconciler {
+	return &receiptReconciler{
+		log:        log,
+		sampleRate: cfg.sampleRate(),
+		dropAfter:  cfg.dropAfter(),
+		interval:   cfg.interval(),
+		head:       head,
+		pending:    make(map[common.Hash]receiptTx),
+		included:   make(map[common.Hash]receiptTx),
+		mu:         &sync.Mutex{},
+	}
+}
+
+func (rr *receiptReconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(rr.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rr.tick(ctx)
+		}
+	}
+}
+
+// track samples a sent transaction.
+func (rr *receiptReconciler) track(hash common.Hash, txType eth.TransactionType, accessList bool) {
+	if rr.sampleRate < 1 && rand.Float64() >= rr.sampleRate {
+		return
+	}
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.pending[hash] = receiptTx{sent: rr.head, txType: txType, accessList: accessList}
+}
+
+// onReceipt registers fn to be called with every receipt found.
+func (rr *receiptReconciler) onReceipt(fn func(hash common.Hash, receipt *types.Receipt)) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.listeners = append(rr.listeners, fn)
+}
+
+// onBlock marks the tracked transactions of the block as included.
+func (rr *receiptReconciler) onBlock(number uint64, txs []string) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if number > rr.head {
+		rr.head = number
+	}
+	for _, tx := range txs {
+		hash := common.HexToHash(tx)
+		if tx, ok := rr.pending[hash]; ok {
+			delete(rr.pending, hash)
+			rr.included[hash] = tx
+		}
+	}
+}
+
+// batch returns the transactions waiting for more than dropAfter blocks and
+// the included ones, at most maxReceiptBatch of them. Included transactions
+// are expired after dropAfter blocks as well, so the ones of reorged blocks do
+// not stay in the batches.
+func (rr *receiptReconciler) batch() ([]common.Hash, map[common.Hash]bool) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	hashes := make([]common.Hash, 0)
+	expired := make(map[common.Hash]bool)
+	for hash, tx := range rr.pending {
+		if len(hashes) == maxReceiptBatch {
+			return hashes, expired
+		}
+		if rr.head >= tx.sent+rr.dropAfter {
+			hashes = append(hashes, hash)
+			expired[hash] = true
+		}
+	}
+	for hash, tx := range rr.included {
+		if len(hashes) == maxReceiptBatch {
+			break
+		}
+		hashes = append(hashes, hash)
+		if rr.head >= tx.sent+rr.dropAfter {
+			expired[hash] = true
+		}
+	}
+	return hashes, expired
+}
+
+func (rr *receiptReconciler) tick(ctx context.Context) {
+	hashes, expired := rr.batch()
+	if len(hashes) == 0 {
+		return
+	}
+	receipts, err := rr.receipts(ctx, hashes)
+	if err != nil {
+		rr.log.Error(err, "failed to get receipts", "num_txs", len(hashes))
+		return
+	}
+
+	for i, hash := range hashes {
+		receipt := receipts[i]
+		var record *eth.ReceiptRecord
+		switch {
+		case receipt != nil:
+			record = newReceiptRecord(receipt)
+		case expired[hash]:
+			record = &eth.ReceiptRecord{Dropped: true}
+		default:
+			// included in a block the node has no receipts for yet, or
+			// waiting to be included again after a reorg
+			continue
+		}
+		if err := rr.store(hash, record); err != nil {
+			rr.log.Error(err, "failed to store receipt", "hash", hash)
+		}
+
+		rr.mu.Lock()
+		tx, ok := rr.pending[hash]
+		if !ok {
+			tx = rr.included[hash]
+		}
+		delete(rr.pending, hash)
+		delete(rr.included, hash)
+		if receipt != nil {
+			rr.counts.GasUsed = append(rr.counts.GasUsed, txGasUsed{TxType: tx.txType, AccessList: tx.accessList, GasUsed: receipt.GasUsed})
+		}
+		switch {
+		case record.Dropped:
+			rr.counts.Dropped++
+		case record.Status == types.ReceiptStatusSuccessful:
+			rr.counts.Succeeded++
+		default:
+			rr.counts.Reverted++
+		}
+		listeners := rr.listeners
+		rr.mu.Unlock()
+
+		if receipt != nil {
+			for _, fn := range listeners {
+				fn(hash, receipt)
+			}
+		}
+	}
+}
+
+// drain returns the outcomes reconciled since the last call.
+func (rr *receiptReconciler) drain() receiptCounts {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	counts := rr.counts
+	rr.counts = receiptCounts{}
+	return counts
+}
+
+func newReceiptRecord(receipt *types.Receipt) *eth.ReceiptRecord {
+	record := &eth.ReceiptRecord{
+		Status:      receipt.Status,
+		GasUsed:     receipt.GasUsed,
+		BlockNumber: receipt.BlockNumber.Uint64(),
+	}
+	if receipt.EffectiveGasPrice != nil {
+		record.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+	}
+	return record
+}
+
+// fetchReceipts returns the receipts of the hashes in one batch request, nil
+// for the transactions the node has no receipt for.
+func fetchReceipts(ctx context.Context, rc *rpc.Client, hashes []common.Hash) ([]*types.Receipt, error) {
+	receipts := make([]*types.Receipt, len(hashes))
+	batch := make([]rpc.BatchElem, len(hashes))
+	for i, hash := range hashes {
+		batch[i] = rpc.BatchElem{
+			Method: "eth_getTransactionReceipt",
+			Args:   []interface{}{hash},
+			Result: &receipts[i],
+		}
+	}
+	if err := rc.BatchCallContext(ctx, batch); err != nil {
+		return nil, err
+	}
+	for i, elem := range batch {
+		if elem.Error != nil {
+			return nil, fmt.Errorf("failed to get receipt %s: %w", hashes[i], elem.Error)
+		}
+	}
+	return receipts, nil
+}
@@ -0,0 +1,126 @@
+package loadtest
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-logr/logr"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReceipts is the node and database side of the receipt reconciler.
+type fakeReceipts struct {
+	receipts map[common.Hash]*types.Receipt
+	stored   map[common.Hash]*eth.ReceiptRecord
+	mu       *sync.Mutex
+}
+
+func newTestReceiptReconciler(cfg *receiptReconcilerConfig) (*receiptReconciler, *fakeReceipts) {
+	fr := &fakeReceipts{
+		receipts: make(map[common.Hash]*types.Receipt),
+		stored:   make(map[common.Hash]*eth.ReceiptRecord),
+		mu:       &sync.Mutex{},
+	}
+	rr := newReceiptReconciler(logr.Discard(), cfg, 100)
+	rr.receipts = func(_ context.Context, hashes []common.Hash) ([]*types.Receipt, error) {
+		receipts := make([]*types.Receipt, len(hashes))
+		for i, hash := range hashes {
+			receipts[i] = fr.receipts[hash]
+		}
+		return receipts, nil
+	}
+	rr.store = func(hash common.Hash, record *eth.ReceiptRecord) error {
+		fr.mu.Lock()
+		defer fr.mu.Unlock()
+		fr.stored[hash] = record
+		return nil
+	}
+	return rr, fr
+}
+
+func TestReceiptReconciler(t *testing.T) {
+	succeeded, reverted, dropped := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+
+	t.Run("records included transactions", func(t *testing.T) {
+		rr, fr := newTestReceiptReconciler(&receiptReconcilerConfig{})
+		rr.track(succeeded, eth.TransactionTypeETH, false)
+		rr.track(reverted, eth.TransactionTypeERC20, true)
+		rr.onBlock(101, []string{succeeded.Hex(), reverted.Hex(), "0x04"})
+
+		// receipts are not served yet
+		rr.tick(context.Background())
+		assert.Empty(t, fr.stored)
+
+		fr.receipts[succeeded] = &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 21000, BlockNumber: big.NewInt(101), EffectiveGasPrice: big.NewInt(7)}
+		fr.receipts[reverted] = &types.Receipt{Status: types.ReceiptStatusFailed, GasUsed: 30000, BlockNumber: big.NewInt(101)}
+		rr.tick(context.Background())
+
+		require.Len(t, fr.stored, 2)
+		assert.Equal(t, &eth.ReceiptRecord{Status: 1, GasUsed: 21000, EffectiveGasPrice: "7", BlockNumber: 101}, fr.stored[succeeded])
+		assert.Equal(t, uint64(0), fr.stored[reverted].Status)
+		counts := rr.drain()
+		assert.ElementsMatch(t, []txGasUsed{
+			{TxType: eth.TransactionTypeETH, GasUsed: 21000},
+			{TxType: eth.TransactionTypeERC20, AccessList: true, GasUsed: 30000},
+		}, counts.GasUsed)
+		counts.GasUsed = nil
+		assert.Equal(t, receiptCounts{Succeeded: 1, Reverted: 1}, counts)
+		assert.Equal(t, receiptCounts{}, rr.drain())
+	})
+
+	t.Run("drops transactions not included", func(t *testing.T) {
+		rr, fr := newTestReceiptReconciler(&receiptReconcilerConfig{DropAfter: 2})
+		received := make([]common.Hash, 0)
+		rr.onReceipt(func(hash common.Hash, _ *types.Receipt) {
+			received = append(received, hash)
+		})
+		rr.track(dropped, eth.TransactionTypeETH, false)
+		rr.track(succeeded, eth.TransactionTypeETH, false)
+
+		rr.onBlock(101, nil)
+		rr.tick(context.Background())
+		assert.Empty(t, fr.stored)
+
+		// included in a block the tracker missed
+		fr.receipts[succeeded] = &types.Receipt{Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(101)}
+		rr.onBlock(102, nil)
+		rr.tick(context.Background())
+		assert.Equal(t, &eth.ReceiptRecord{Dropped: true}, fr.stored[dropped])
+		assert.Equal(t, uint64(101), fr.stored[succeeded].BlockNumber)
+		assert.Equal(t, receiptCounts{Succeeded: 1, Dropped: 1, GasUsed: []txGasUsed{{TxType: eth.TransactionTypeETH}}}, rr.drain())
+		assert.Equal(t, []common.Hash{succeeded}, received)
+		assert.Empty(t, rr.pending)
+	})
+
+	t.Run("drops transactions of reorged blocks", func(t *testing.T) {
+		rr, fr := newTestReceiptReconciler(&receiptReconcilerConfig{DropAfter: 2})
+		rr.track(dropped, eth.TransactionTypeETH, false)
+		rr.onBlock(101, []string{dropped.Hex()})
+
+		// the block was reorged out, the node has no receipt
+		rr.tick(context.Background())
+		assert.Empty(t, fr.stored)
+		assert.Len(t, rr.included, 1)
+
+		rr.onBlock(102, nil)
+		rr.tick(context.Background())
+		assert.Equal(t, &eth.ReceiptRecord{Dropped: true}, fr.stored[dropped])
+		assert.Equal(t, receiptCounts{Dropped: 1}, rr.drain())
+		assert.Empty(t, rr.included)
+	})
+
+	t.Run("samples transactions", func(t *testing.T) {
+		rate := 0.5
+		rr, _ := newTestReceiptReconciler(&receiptReconcilerConfig{SampleRate: &rate})
+		for i := range 1000 {
+			rr.track(common.BigToHash(big.NewInt(int64(i))), eth.TransactionTypeETH, false)
+		}
+		assert.InDelta(t, 500, len(rr.pending), 100)
+	})
+}
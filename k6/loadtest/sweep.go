@@ -0,0 +1,175 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteryforge/gasper/k6/eth"
+	"go.k6.io/k6/js/modules"
+	"golang.org/x/sync/semaphore"
+)
+
+const transferGas = 21_000
+
+type SweepResult struct {
+	Wallets        int    `json:"wallets"`                  // generated tester wallets
+	Swept          int    `json:"swept"`                    // wallets with a balance sent back
+	Failed         int    `json:"failed"`                   // wallets that failed or were in use
+	To             string `json:"to"`                       // sponsor the funds were sent to
+	Recovered      string `json:"recovered"`                // wei sent back
+	ERC20Recovered string `json:"erc20Recovered,omitempty"` // ERC20 tokens sent back
+}
+
+// SweepWallets sends the balance of the generated tester wallets, minus the
+// fees, back to a sponsor. ERC20 tokens are sent back first when sweep_erc20
+// is set.
+func (c *DefaultClient) SweepWallets(vu modules.VU, metrics *EthMetrics) (*SweepResult, error) {
+	return c.sweepWallets(vu.Context())
+}
+
+func (c *DefaultClient) sweepWallets(ctx context.Context) (*SweepResult, error) {
+	if c.generatedWallets == nil || c.generatedWallets.Ln() == 0 {
+		return &SweepResult{Recovered: "0"}, nil
+	}
+	if c.sponsors == nil || c.sponsors.Ln() == 0 {
+		return nil, fmt.Errorf("no sponsor wallet to sweep to")
+	}
+
+	var to common.Address
+	for addr := range c.sponsors.All() {
+		to = addr
+		break
+	}
+
+	wallets := c.generatedWallets.All()
+	res := &SweepResult{Wallets: len(wallets), To: to.Hex()}
+	recovered, tokens := big.NewInt(0), big.NewInt(0)
+	mu := &sync.Mutex{}
+
+	sem := semaphore.NewWeighted(eth.MaxNumberOfCreatingWalletsAtOnce)
+	for _, wallet := range wallets {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+
+		go func() {
+			defer sem.Release(1)
+
+			value, amount, err := c.sweepWallet(ctx, wallet, to)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				c.log.Error(err, "failed to sweep wallet", "address", wallet.Address)
+				res.Failed++
+			}
+			if value.Sign() > 0 || amount.Sign() > 0 {
+				res.Swept++
+			}
+			recovered.Add(recovered, value)
+			tokens.Add(tokens, amount)
+		}()
+	}
+
+	if err := sem.Acquire(ctx, eth.MaxNumberOfCreatingWalletsAtOnce); err != nil {
+		return nil, err
+	}
+
+	res.Recovered = recovered.String()
+	if c.sweepERC20 && c.erc20 != nil {
+		res.ERC20Recovered = tokens.String()
+	}
+	c.log.Info("Swept wallets", "to", res.To, "swept", res.Swept, "failed", res.Failed, "recovered", res.Recovered, "erc20_recovered", res.ERC20Recovered)
+	return res, nil
+}
+
+// sweepWallet returns the wei and ERC20 tokens sent back from wallet to to,
+// only confirmed transfers are counted.
+func (c *DefaultClient) sweepWallet(ctx context.Context, wallet *eth.Wallet, to common.Address) (*big.Int, *big.Int, error) {
+	value, tokens := big.NewInt(0), big.NewInt(0)
+	if !c.testers.Lock(wallet.Address) {
+		return value, tokens, fmt.Errorf("wallet is in use")
+	}
+	defer c.testers.Unlock(wallet.Address)
+
+	if c.sweepERC20 && c.erc20 != nil {
+		balance, err := c.erc20.Balance(ctx, wallet.Address)
+		if err != nil {
+			return value, tokens, err
+		}
+		if balance.Sign() > 0 {
+			data, err := c.erc20.TransferData(to, balance)
+			if err != nil {
+				return value, tokens, err
+			}
+			fees, err := c.suggestFees(ctx, 1)
+			if err != nil {
+				return value, tokens, err
+			}
+			if err := c.sweepTransfer(ctx, wallet, &Payload{To: c.erc20.Address, Value: big.NewInt(0), Data: data}, fees); err != nil {
+				return value, tokens, fmt.Errorf("failed to sweep ERC20 tokens: %w", err)
+			}
+			tokens = balance
+		}
+	}
+
+	balance, err := c.ethClient.Ec.BalanceAt(ctx, wallet.Address, nil)
+	if err != nil {
+		return value, tokens, fmt.Errorf("failed to get balance: %w", err)
+	}
+	fees, err := c.suggestFees(ctx, 1)
+	if err != nil {
+		return value, tokens, err
+	}
+	price := fees.GasPrice
+	if price == nil {
+		price = fees.GasFeeCap
+	}
+	amount := new(big.Int).Sub(balance, new(big.Int).Mul(price, big.NewInt(transferGas)))
+	if amount.Sign() <= 0 {
+		return value, tokens, nil
+	}
+
+	if err := c.sweepTransfer(ctx, wallet, &Payload{To: &to, Value: amount, Gas: transferGas}, fees); err != nil {
+		return value, tokens, fmt.Errorf("failed to sweep balance: %w", err)
+	}
+	return amount, tokens, nil
+}
+
+// sweepTransfer sends payload from wallet and waits until it is mined. The
+// nonce is taken from the nonce manager, since wallets can be swept during the
+// run.
+func (c *DefaultClient) sweepTransfer(ctx context.Context, wallet *eth.Wallet, payload *Payload, fees *txFees) error {
+	var err error
+	if payload.Gas == 0 {
+		payload.Gas, err = c.ethClient.Ec.EstimateGas(ctx, ethereum.CallMsg{
+			From:  wallet.Address,
+			To:    payload.To,
+			Value: payload.Value,
+			Data:  payload.Data,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to estimate gas: %w", err)
+		}
+	}
+
+	nonce := c.nonces.Next(wallet, 0)
+	signedTx, err := types.SignTx(c.newTx(nonce, payload, fees), types.LatestSignerForChainID(c.ethClient.ChainID), wallet.PrivateKey)
+	if err != nil {
+		c.nonces.Done(wallet, nonce, false, nil)
+		return err
+	}
+	err = c.ethClient.Ec.SendTransaction(ctx, signedTx)
+	c.nonces.Done(wallet, nonce, err == nil, err)
+	if err != nil {
+		return err
+	}
+
+	_, err = eth.WaitUntilMined(ctx, c.ethClient.Ec, signedTx.Hash(), eth.WalletsTimeout, eth.WalletsInterval)
+	return err
+}
@@ -0,0 +1,46 @@
+package loadtest
+
+import (
+	"errors"
+
+	"github.com/mysteryforge/gasper/k6/eth"
+)
+
+var (
+	errTxReverted          = errors.New("transaction reverted")
+	errConfirmationTimeout = errors.New("confirmation timed out")
+)
+
+// txOutcome is how sending a transaction ended, failed outcomes are counted
+// by their own metric and all of them make up gasper_tx_failed.
+type txOutcome string
+
+const (
+	txSucceeded           txOutcome = ""
+	txSendFailed          txOutcome = "send"
+	txReceiptFailed       txOutcome = "receipt"
+	txConfirmationTimeout txOutcome = "timeout"
+	txTransportFailed     txOutcome = "transport"
+	txCanceled            txOutcome = "canceled" // not reported
+)
+
+// classifyTxOutcome returns the outcome of a transaction from the error of
+// its send. A receipt not found in time wraps errConfirmationTimeout, other
+// errors waiting for the receipt are classified like the errors of the send.
+func classifyTxOutcome(err error) txOutcome {
+	if err == nil {
+		return txSucceeded
+	}
+	category := eth.ClassifyTxError(err)
+	switch {
+	case category == eth.TxErrCanceled:
+		return txCanceled
+	case errors.Is(err, errTxReverted):
+		return txReceiptFailed
+	case errors.Is(err, errConfirmationTimeout):
+		return txConfirmationTimeout
+	case category == eth.TxErrTransport || category == eth.TxErrTimeout:
+		return txTransportFailed
+	}
+	return txSendFailed
+}
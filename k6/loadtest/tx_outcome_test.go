@@ -0,0 +1,36 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyTxOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected txOutcome
+	}{
+		{"succeeded", nil, txSucceeded},
+		{"refused by the node", errors.New("insufficient funds for gas * price + value"), txSendFailed},
+		{"estimate reverted", fmt.Errorf("failed to estimate gas: %w", errors.New("execution reverted")), txSendFailed},
+		{"reverted", fmt.Errorf("%w: 0x01 in block 2", errTxReverted), txReceiptFailed},
+		{"not confirmed", fmt.Errorf("%w: %w", errConfirmationTimeout, context.DeadlineExceeded), txConfirmationTimeout},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, txTransportFailed},
+		{"request timed out", fmt.Errorf("post: %w", context.DeadlineExceeded), txTransportFailed},
+		{"canceled", fmt.Errorf("%w: %w", errConfirmationTimeout, context.Canceled), txCanceled},
+		{"receipt unreachable", fmt.Errorf("failed to get receipt: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), txTransportFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, classifyTxOutcome(tt.err))
+		})
+	}
+}